@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,8 +17,15 @@ import (
 
 	"github.com/alejoacosta74/qproxy/pkg/log"
 	"github.com/alejoacosta74/qproxy/pkg/qtum"
+	"github.com/alejoacosta74/qproxy/pkg/rpc"
 	"github.com/alejoacosta74/qproxy/pkg/server"
+	"github.com/alejoacosta74/qproxy/pkg/store"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/qtumproject/btcd/chaincfg"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -47,6 +56,29 @@ var (
 	qtumUser        string
 	qtumPass        string
 	network         string
+	chainID         uint64
+
+	minFeeRate      float64
+	maxFeeRate      float64
+	fallbackFeeRate float64
+	feeConfTarget   int64
+
+	coinSelection    string
+	minConfirmations int64
+	dustThreshold    float64
+
+	consolidateInterval   time.Duration
+	consolidateMaxFeeRate float64
+	consolidateMinUTXOs   int
+	consolidateMaxInputs  int
+
+	dataDir        string
+	keystoreDir    string
+	passwordFile   string
+	signerEndpoint string
+
+	gasPriceCacheTTL time.Duration
+	wsOrigins        []string
 
 	logger   *gologger.Logger
 	cfgFile  string
@@ -61,7 +93,27 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&qtumUser, "user", "u", "qtum", "Qtum user")
 	rootCmd.PersistentFlags().StringVarP(&qtumPass, "pass", "p", "qtum", "Qtum password")
 	rootCmd.PersistentFlags().StringVarP(&network, "network", "n", "regtest", "Qtum network")
+	rootCmd.PersistentFlags().Uint64Var(&chainID, "chainid", 0, "Chain id of the eth transactions (default is the chain id of the Qtum network)")
+	rootCmd.PersistentFlags().Float64Var(&minFeeRate, "minfeerate", qtum.DefaultMinFeeRate.ToBTC(), "Minimum fee rate in QTUM/kB")
+	rootCmd.PersistentFlags().Float64Var(&maxFeeRate, "maxfeerate", qtum.DefaultMaxFeeRate.ToBTC(), "Maximum fee rate in QTUM/kB")
+	rootCmd.PersistentFlags().Float64Var(&fallbackFeeRate, "fallbackfeerate", qtum.DefaultFallbackFeeRate.ToBTC(), "Fee rate in QTUM/kB used when the Qtum node returns no fee estimate")
+	rootCmd.PersistentFlags().Int64Var(&feeConfTarget, "feeconftarget", qtum.DefaultFeeConfTarget, "Confirmation target in blocks used to estimate the fee rate")
+	rootCmd.PersistentFlags().StringVar(&coinSelection, "coinselection", qtum.DefaultCoinSelection, "UTXO selection strategy (largest-first, smallest-first, bnb, random-improve)")
+	rootCmd.PersistentFlags().Int64Var(&minConfirmations, "minconf", qtum.DefaultMinConfirmations, "Minimum number of confirmations of the UTXOs to spend")
+	rootCmd.PersistentFlags().Float64Var(&dustThreshold, "dustthreshold", qtum.DefaultDustThreshold.ToBTC(), "Lowest change output in QTUM, lower change is paid as fee")
 
+	rootCmd.Flags().DurationVar(&consolidateInterval, "consolidateinterval", 0, "Time between consolidations of the UTXOs of the unlocked wallets (0 disables them)")
+	rootCmd.Flags().Float64Var(&consolidateMaxFeeRate, "consolidatemaxfeerate", qtum.DefaultMinFeeRate.ToBTC(), "Maximum fee rate in QTUM/kB to consolidate UTXOs at")
+	rootCmd.Flags().IntVar(&consolidateMinUTXOs, "consolidateminutxos", rpc.DefaultConsolidationMinUTXOs, "Minimum number of UTXOs of a wallet to consolidate them")
+	rootCmd.Flags().IntVar(&consolidateMaxInputs, "consolidatemaxinputs", rpc.DefaultConsolidationMaxInputs, "Maximum number of UTXOs merged by a consolidation tx")
+
+	rootCmd.PersistentFlags().DurationVar(&gasPriceCacheTTL, "gaspricecache", rpc.DefaultGasPriceCacheTTL, "Time the gas price is cached before asking the Qtum node again")
+
+	rootCmd.PersistentFlags().StringVar(&dataDir, "datadir", "", "data directory (default is $HOME/.qproxy)")
+	rootCmd.PersistentFlags().StringVar(&keystoreDir, "keystore", "", "directory of the encrypted wallet keys (default is <datadir>/keystore)")
+	rootCmd.PersistentFlags().StringVar(&passwordFile, "password", "", "file with the passphrase used to unlock the keystore wallets on startup")
+	rootCmd.Flags().StringSliceVar(&wsOrigins, "wsorigins", nil, "Origins allowed to connect to the websocket endpoint from a browser (default is localhost, * allows any)")
+	rootCmd.Flags().StringVar(&signerEndpoint, "signer", "", "URL or unix socket path of an external signer holding the private keys of the wallets (see qproxy signer)")
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.qproxy/config.yaml)")
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "loglevel", "l", "", "log level (trace, debug, info, warn, error, fatal, panic")
 
@@ -69,6 +121,7 @@ func init() {
 	viper.BindPFlag("loglevel", rootCmd.PersistentFlags().Lookup("loglevel"))
 	viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
 	viper.BindPFlag("nework", rootCmd.PersistentFlags().Lookup("network"))
+	viper.BindPFlag("chainid", rootCmd.PersistentFlags().Lookup("chainid"))
 
 }
 
@@ -93,8 +146,74 @@ func runQtumProxy(cmd *cobra.Command, args []string) {
 		logger.Error(err)
 		os.Exit(1)
 	}
+	feeCfg, err := getFeeConfig()
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	err = qclient.SetFeeConfig(feeCfg)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	selector, err := qtum.NewCoinSelector(coinSelection)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	qclient.SetCoinSelector(selector)
+	err = qclient.SetMinConfirmations(minConfirmations)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	dust, err := btcutil.NewAmount(dustThreshold)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "invalid dust threshold: %v", dustThreshold))
+		os.Exit(1)
+	}
+	err = qclient.SetDustThreshold(dust)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	consolidation, err := getConsolidationConfig()
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	// Open the store of broadcast transactions
+	txStore, err := openTxStore()
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	// Open the keystore and load its wallets
+	err = openKeystore(qclient.NetworkParams())
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	// Add the wallets of the keys held by the external signer
+	err = loadExternalSigner(qclient.NetworkParams())
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	// Set the authorization policies of the wallets
+	err = loadPolicies()
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	// Set the addresses the change of the wallets is paid to
+	err = loadChangeAddresses(qclient.NetworkParams())
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
 	// Create new proxy server
-	srv, err := server.NewServer(address, backendUrl, qclient, network)
+	srv, err := server.NewServer(address, backendUrl, qclient, network, txStore, gasPriceCacheTTL, viper.GetUint64("chainid"), consolidation, wsOrigins)
 	if err != nil {
 		logger.Error(err)
 		os.Exit(1)
@@ -129,12 +248,185 @@ func runQtumProxy(cmd *cobra.Command, args []string) {
 	if err != nil {
 		logger.WithField("module", "root").Fatal(err)
 	}
+	// close tx store
+	err = txStore.Close()
+	if err != nil {
+		logger.WithField("module", "root").Fatal(err)
+	}
 
 	logger.Println("shutting down")
 	os.Exit(0)
 
 }
 
+// getFeeConfig returns the fee configuration of the qtum client from the fee rate flags
+func getFeeConfig() (qtum.FeeConfig, error) {
+	feeCfg := qtum.FeeConfig{ConfTarget: feeConfTarget}
+	var err error
+	feeCfg.MinFeeRate, err = btcutil.NewAmount(minFeeRate)
+	if err != nil {
+		return feeCfg, errors.Wrapf(err, "invalid min fee rate: %v", minFeeRate)
+	}
+	feeCfg.MaxFeeRate, err = btcutil.NewAmount(maxFeeRate)
+	if err != nil {
+		return feeCfg, errors.Wrapf(err, "invalid max fee rate: %v", maxFeeRate)
+	}
+	feeCfg.FallbackFeeRate, err = btcutil.NewAmount(fallbackFeeRate)
+	if err != nil {
+		return feeCfg, errors.Wrapf(err, "invalid fallback fee rate: %v", fallbackFeeRate)
+	}
+	return feeCfg, nil
+}
+
+// getConsolidationConfig returns the configuration of the consolidation of the UTXOs from the consolidation flags
+func getConsolidationConfig() (rpc.ConsolidationConfig, error) {
+	consolidation := rpc.ConsolidationConfig{
+		Interval:  consolidateInterval,
+		MinUTXOs:  consolidateMinUTXOs,
+		MaxInputs: consolidateMaxInputs,
+	}
+	var err error
+	consolidation.MaxFeeRate, err = btcutil.NewAmount(consolidateMaxFeeRate)
+	if err != nil {
+		return consolidation, errors.Wrapf(err, "invalid consolidation max fee rate: %v", consolidateMaxFeeRate)
+	}
+	return consolidation, consolidation.Validate()
+}
+
+// openTxStore opens the persistent store of broadcast transactions within the data directory
+func openTxStore() (store.TxStore, error) {
+	dir, err := getDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return store.NewBoltStore(filepath.Join(dir, "qproxy.db"))
+}
+
+// openKeystore opens the keystore of the wallets and, if a password file is
+// given, loads the wallets encrypted with its passphrase
+func openKeystore(cfg *chaincfg.Params) error {
+	dir := keystoreDir
+	if dir == "" {
+		dataDir, err := getDataDir()
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(dataDir, "keystore")
+	}
+	ks, err := wallet.NewKeystore(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		return err
+	}
+	wallet.SetKeystore(ks)
+	if passwordFile == "" {
+		return nil
+	}
+	password, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return errors.Wrapf(err, "error reading password file: %s", passwordFile)
+	}
+	loaded, err := wallet.GetWallets().LoadKeystore(strings.TrimRight(string(password), "\r\n"), cfg)
+	if err != nil {
+		return errors.Wrapf(err, "error loading keystore: %s", dir)
+	}
+	log.With("module", "root").Infof("Loaded %d wallets from keystore %s", loaded, dir)
+	return nil
+}
+
+// loadExternalSigner adds a wallet for every key of the external signer, if any.
+// The private keys stay in the signer, which signs the hashes requested by the proxy
+func loadExternalSigner(cfg *chaincfg.Params) error {
+	if signerEndpoint == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rs, err := wallet.DialSigner(ctx, signerEndpoint)
+	if err != nil {
+		return err
+	}
+	signers, err := rs.Signers()
+	if err != nil {
+		return err
+	}
+	for _, signer := range signers {
+		w, err := wallet.GetWallets().AddSigner(signer, cfg)
+		if err != nil {
+			return errors.Wrapf(err, "error adding wallet of external signer %s", signerEndpoint)
+		}
+		log.With("module", "root").Infof("Added wallet %s of external signer %s", w.GetEthereumAddress(), signerEndpoint)
+	}
+	return nil
+}
+
+// policyConfig is the authorization policy of a wallet in the config file,
+// with the value limits in wei
+type policyConfig struct {
+	AllowedTo     []string `mapstructure:"allowedto"`
+	MaxTxValue    string   `mapstructure:"maxtxvalue"`
+	MaxDailyValue string   `mapstructure:"maxdailyvalue"`
+}
+
+// loadPolicies sets the authorization policies of the wallets from the
+// "policies" section of the config file, keyed by ethereum address
+func loadPolicies() error {
+	var configs map[string]policyConfig
+	err := viper.UnmarshalKey("policies", &configs)
+	if err != nil {
+		return errors.Wrap(err, "error reading wallet policies")
+	}
+	for address, cfg := range configs {
+		if !common.IsHexAddress(address) {
+			return errors.New("invalid wallet address of policy: " + address)
+		}
+		policy, err := wallet.NewPolicy(cfg.AllowedTo, cfg.MaxTxValue, cfg.MaxDailyValue)
+		if err != nil {
+			return errors.Wrapf(err, "invalid policy of wallet %s", address)
+		}
+		wallet.SetPolicy(common.HexToAddress(address), policy)
+		log.With("module", "root").Infof("Set policy of wallet %s", common.HexToAddress(address))
+	}
+	return nil
+}
+
+// loadChangeAddresses sets the qtum addresses the change of the wallets is paid to
+// from the "changeaddresses" section of the config file, keyed by ethereum address
+func loadChangeAddresses(cfg *chaincfg.Params) error {
+	var changeAddresses map[string]string
+	err := viper.UnmarshalKey("changeaddresses", &changeAddresses)
+	if err != nil {
+		return errors.Wrap(err, "error reading change addresses")
+	}
+	for address, changeAddress := range changeAddresses {
+		if !common.IsHexAddress(address) {
+			return errors.New("invalid wallet address of change address: " + address)
+		}
+		err = wallet.SetChangeAddress(common.HexToAddress(address), changeAddress, cfg)
+		if err != nil {
+			return errors.Wrapf(err, "invalid change address of wallet %s", address)
+		}
+		log.With("module", "root").Infof("Set change address of wallet %s to %s", common.HexToAddress(address), changeAddress)
+	}
+	return nil
+}
+
+// getDataDir returns the data directory, creating it if needed
+func getDataDir() (string, error) {
+	dir := dataDir
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "error getting user home directory")
+		}
+		dir = filepath.Join(homeDir, ".qproxy")
+	}
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", errors.Wrapf(err, "error creating data directory: %s", dir)
+	}
+	return dir, nil
+}
+
 func setLogger() error {
 	var err error
 	level := viper.GetString("loglevel")
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// signerCmd runs an external signer for the proxy
+var signerCmd = &cobra.Command{
+	Use:   "signer",
+	Short: "Run an external signer with the keystore wallets",
+	Long: `External signer that holds the private keys of the keystore wallets decrypted
+with the passphrase of --password, and signs the hashes requested by the proxy
+(started with --signer <socket>) over a unix socket. The private keys are never
+loaded by the proxy process.
+`,
+	Run:  runSigner,
+	Args: cobra.MaximumNArgs(0),
+}
+
+var signerSocket string
+
+func init() {
+	rootCmd.AddCommand(signerCmd)
+	signerCmd.Flags().StringVar(&signerSocket, "socket", "", "unix socket to listen on (default is <datadir>/signer.ipc)")
+}
+
+func runSigner(cmd *cobra.Command, args []string) {
+	signers, err := loadKeystoreSigners()
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	socket := signerSocket
+	if socket == "" {
+		dir, err := getDataDir()
+		if err != nil {
+			logger.Error(err)
+			os.Exit(1)
+		}
+		socket = filepath.Join(dir, "signer.ipc")
+	}
+	server := rpc.NewServer()
+	err = server.RegisterName("signer", wallet.NewSignerService(signers...))
+	if err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+	// remove the socket left by a previous run
+	os.Remove(socket)
+	// only the user running the signer can connect to the socket. The umask
+	// creates it as 0600, so others can not connect before it is restricted
+	oldMask := syscall.Umask(0177)
+	listener, err := net.Listen("unix", socket)
+	syscall.Umask(oldMask)
+	if err != nil {
+		logger.Error(errors.Wrapf(err, "error listening on socket: %s", socket))
+		os.Exit(1)
+	}
+	go server.ServeListener(listener)
+	log.With("module", "signer").Infof("Signing with %d keys on socket %s", len(signers), socket)
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGQUIT, syscall.SIGTERM)
+	<-c
+
+	listener.Close()
+	server.Stop()
+	logger.Println("shutting down")
+	os.Exit(0)
+}
+
+// loadKeystoreSigners returns the signers of the keystore keys decrypted with the
+// passphrase of the password file. The key files encrypted with other passphrases are skipped
+func loadKeystoreSigners() ([]wallet.Signer, error) {
+	if passwordFile == "" {
+		return nil, errors.New("a password file is required to decrypt the keystore")
+	}
+	password, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading password file: %s", passwordFile)
+	}
+	passphrase := strings.TrimRight(string(password), "\r\n")
+	dir := keystoreDir
+	if dir == "" {
+		dataDir, err := getDataDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(dataDir, "keystore")
+	}
+	ks, err := wallet.NewKeystore(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := ks.Accounts()
+	if err != nil {
+		return nil, err
+	}
+	var signers []wallet.Signer
+	for _, address := range addresses {
+		privKey, err := ks.Load(address, passphrase)
+		if err != nil {
+			log.With("module", "signer").Debugf("Skipping key of address %s: %v", address, err)
+			continue
+		}
+		signers = append(signers, wallet.NewKeySigner(secp256k1.PrivKeyFromBytes(crypto.FromECDSA(privKey))))
+		log.With("module", "signer").Infof("Loaded key of address %s", address)
+	}
+	return signers, nil
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
 	"github.com/alejoacosta74/qproxy/pkg/wallet"
 	"github.com/qtumproject/btcd/btcjson"
 	"github.com/qtumproject/btcd/btcutil"
@@ -31,9 +32,26 @@ type MockQcli struct {
 	RawTxResult               *btcjson.TxRawResult           // Mock response for GetRawTransactionVerbose
 	BlockResult               *btcjson.GetBlockVerboseResult // Mock response for GetBlockVerbose
 	AddressResult             *btcjson.GetAddressInfoResult  // Mock response for GetAddressInfo
-	BuildUnsignedQtumTxResult *wire.MsgTx                    // Mock response for BuildUnsignedQtumTx
+	BuildUnsignedQtumTxResult *wire.MsgTx                    // Mock response for BuildUnsignedQtumTx and BuildUnsignedContractTx
+	DroppedChangeResult       btcutil.Amount                 // Mock change paid as fee by BuildUnsignedQtumTx and BuildUnsignedContractTx
+	ChangeAddress             string                         // Last change address received by BuildUnsignedQtumTx or BuildUnsignedContractTx
+	ConsolidationTxResult     *wire.MsgTx                    // Mock response for BuildConsolidationTx
+	ConsolidationAddress      string                         // Last address received by BuildConsolidationTx
+	VerifiedWallet            wallet.IQtumWallet             // Last wallet received by VerifyWallet
 	FindSpendableUTXOResult   []btcjson.ListUnspentResult    // Mock response for FindSpendableUTXO
-	SendRawTransactionResult  *chainhash.Hash                // Mock response for SendRawTransaction
+	SpendableAddresses        []string                       // Last addresses received by FindSpendableUTXO
+	SendRawTransactionResult  *chainhash.Hash                // Last txid returned by SendRawTransaction
+	SendRawTransactionErr     error                          // Mock error for SendRawTransaction
+	ContractParams            *qtypes.ContractParams         // Last contract params received by BuildUnsignedContractTx
+	CallContractResult        *qtypes.CallContractResult     // Mock response for CallContract
+	CallContractGasNeeded     uint64                         // Lowest gas limit CallContract succeeds with, if set
+	TransactionReceiptResult  []qtypes.TransactionReceipt    // Mock response for GetTransactionReceipt
+	SearchLogsResult          []qtypes.TransactionReceipt    // Mock response for SearchLogs
+	SearchLogsRange           [2]int64                       // Last block range received by SearchLogs
+	FeeRateResult             btcutil.Amount                 // Mock response for EstimateFeeRate
+	DGPInfoResult             *qtypes.DGPInfo                // Mock response for GetDGPInfo
+	BlockCountResult          int64                          // Mock response for GetBlockCount
+	RawMempoolResult          []*chainhash.Hash              // Mock response for GetRawMempool
 	DefaultResponses          map[string]interface{}
 }
 
@@ -48,17 +66,42 @@ func (q *MockQcli) EstimateSmartFee(confTarget int64, mode *btcjson.EstimateSmar
 }
 
 func (q *MockQcli) GetRawTransactionVerbose(txHash *chainhash.Hash) (*btcjson.TxRawResult, error) {
-	if txHash.String() == q.RawTxResult.Txid {
+	if q.RawTxResult != nil && txHash.String() == q.RawTxResult.Txid {
 		return q.RawTxResult, nil
 	}
-	return nil, errors.New("tx not found")
+	return nil, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCNoTxInfo,
+		Message: "No such mempool or blockchain transaction",
+	}
 }
 
 func (q *MockQcli) GetBlockVerbose(blockHash *chainhash.Hash) (*btcjson.GetBlockVerboseResult, error) {
-	if blockHash.String() == q.BlockResult.Hash {
+	if q.BlockResult != nil && blockHash.String() == q.BlockResult.Hash {
 		return q.BlockResult, nil
 	}
-	return nil, errors.New("block not found")
+	return nil, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCBlockNotFound,
+		Message: "Block not found",
+	}
+}
+
+func (q *MockQcli) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
+	if q.BlockResult != nil && blockHeight == q.BlockResult.Height {
+		return chainhash.NewHashFromStr(q.BlockResult.Hash)
+	}
+	return nil, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidParameter,
+		Message: "Block height out of range",
+	}
+}
+
+func (q *MockQcli) GetTransactionIndex(txHash string, block *btcjson.GetBlockVerboseResult) (int, error) {
+	for i, tx := range block.Tx {
+		if tx == txHash {
+			return i, nil
+		}
+	}
+	return 0, errors.New("tx not found in block")
 }
 
 func (q *MockQcli) ListUnspentMinMaxAddresses(minConf int, maxConf int, addrs []btcutil.Address) ([]btcjson.ListUnspentResult, error) {
@@ -79,12 +122,58 @@ func (q *MockQcli) VerifyAddress(address string) error {
 	return nil
 }
 
-func (q *MockQcli) FindSpendableUTXO(address string) ([]btcjson.ListUnspentResult, error) {
+func (q *MockQcli) VerifyWallet(w wallet.IQtumWallet) error {
+	q.VerifiedWallet = w
+	return nil
+}
+
+func (q *MockQcli) FindSpendableUTXO(addresses ...string) ([]btcjson.ListUnspentResult, error) {
+	q.SpendableAddresses = addresses
 	return q.FindSpendableUTXOResult, nil
 }
 
-func (q *MockQcli) BuildUnsignedQtumTx(unspent []btcjson.ListUnspentResult, sender, receiver string, amount float64) (*wire.MsgTx, error) {
-	return nil, nil
+func (q *MockQcli) BuildUnsignedQtumTx(unspent []btcjson.ListUnspentResult, change, receiver string, amount float64) (*wire.MsgTx, btcutil.Amount, error) {
+	q.ChangeAddress = change
+	return q.BuildUnsignedQtumTxResult, q.DroppedChangeResult, nil
+}
+
+func (q *MockQcli) BuildUnsignedContractTx(unspent []btcjson.ListUnspentResult, sender, change string, contract *qtypes.ContractParams, amount float64) (*wire.MsgTx, btcutil.Amount, error) {
+	q.ContractParams = contract
+	q.ChangeAddress = change
+	return q.BuildUnsignedQtumTxResult, q.DroppedChangeResult, nil
+}
+
+func (q *MockQcli) BuildConsolidationTx(unspent []btcjson.ListUnspentResult, address string, maxInputs int) (*wire.MsgTx, error) {
+	q.ConsolidationAddress = address
+	if q.ConsolidationTxResult == nil {
+		return nil, errors.New("nothing to consolidate")
+	}
+	return q.ConsolidationTxResult, nil
+}
+
+func (q *MockQcli) CallContract(address, data, sender string, gasLimit uint64, amount float64) (*qtypes.CallContractResult, error) {
+	if q.CallContractResult == nil {
+		return nil, errors.New("contract not found")
+	}
+	if gasLimit < q.CallContractGasNeeded {
+		return &qtypes.CallContractResult{ExecutionResult: qtypes.ExecutionResult{Excepted: "OutOfGas", GasUsed: gasLimit}}, nil
+	}
+	return q.CallContractResult, nil
+}
+
+func (q *MockQcli) GetTransactionReceipt(txid string) ([]qtypes.TransactionReceipt, error) {
+	return q.TransactionReceiptResult, nil
+}
+
+func (q *MockQcli) SearchLogs(fromBlock, toBlock int64, addresses []string, topics []*string) ([]qtypes.TransactionReceipt, error) {
+	q.SearchLogsRange = [2]int64{fromBlock, toBlock}
+	var receipts []qtypes.TransactionReceipt
+	for _, receipt := range q.SearchLogsResult {
+		if receipt.BlockNumber >= uint64(fromBlock) && (toBlock < 0 || receipt.BlockNumber <= uint64(toBlock)) {
+			receipts = append(receipts, receipt)
+		}
+	}
+	return receipts, nil
 }
 
 func (q *MockQcli) SignRawTX(tx *wire.MsgTx, unspent []btcjson.ListUnspentResult, w wallet.IQtumWallet) error {
@@ -92,9 +181,30 @@ func (q *MockQcli) SignRawTX(tx *wire.MsgTx, unspent []btcjson.ListUnspentResult
 }
 
 func (q *MockQcli) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	if q.SendRawTransactionErr != nil {
+		return nil, q.SendRawTransactionErr
+	}
+	hash := tx.TxHash()
+	q.SendRawTransactionResult = &hash
 	return q.SendRawTransactionResult, nil
 }
 
+func (q *MockQcli) EstimateFeeRate() (btcutil.Amount, error) {
+	return q.FeeRateResult, nil
+}
+
+func (q *MockQcli) GetDGPInfo() (*qtypes.DGPInfo, error) {
+	return q.DGPInfoResult, nil
+}
+
+func (q *MockQcli) GetBlockCount() (int64, error) {
+	return q.BlockCountResult, nil
+}
+
+func (q *MockQcli) GetRawMempool() ([]*chainhash.Hash, error) {
+	return q.RawMempoolResult, nil
+}
+
 func (q *MockQcli) GetBalance(account string) (btcutil.Amount, error) {
 	return 0, nil
 }
@@ -163,8 +273,6 @@ var buildUnsignedQtumTxResponseHEX string = "01000000020015929c4268dd58632176cba
 var getAddressInfoResponse btcjson.GetAddressInfoResult
 
 // Default response for SendRawTransaction()
-var hashHex string = "1dbf40139b6038d5f19b43c592b33a5ad3fe55494e6407712de55cff6b2938da"
-
 func (q *MockQcli) loadDefaultResponses() {
 
 	// Set default response for FindSpendableUTXO()
@@ -196,13 +304,15 @@ func (q *MockQcli) loadDefaultResponses() {
 		fmt.Printf("Error deserializing unsigned tx: %v\n", err)
 		panic(err)
 	}
-
-	// Set default response for SendRawTransaction()
-	hash, err := chainhash.NewHashFromStr(hashHex)
-	if err != nil {
-		fmt.Printf("Error converting hash: %v\n", err)
-		panic(err)
+	q.BuildUnsignedQtumTxResult = unsignedTx
+
+	// Set default responses for EstimateFeeRate(), GetDGPInfo() and GetBlockCount()
+	q.FeeRateResult = btcutil.Amount(400000)
+	q.DGPInfoResult = &qtypes.DGPInfo{
+		MaxBlockSize:  8000000,
+		MinGasPrice:   qtypes.MinGasPrice,
+		BlockGasLimit: 40000000,
 	}
-	q.SendRawTransactionResult = hash
+	q.BlockCountResult = 1234
 
 }
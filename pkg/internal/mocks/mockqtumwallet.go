@@ -1,14 +1,14 @@
 package mocks
 
 import (
-	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
 	"github.com/ethereum/go-ethereum/common"
 )
 
 type MockQtumWallet struct {
 }
 
-func (w *MockQtumWallet) GetPrivateKey(address string) (*secp256k1.PrivateKey, error) {
+func (w *MockQtumWallet) GetSigner(address string) (wallet.Signer, error) {
 	return nil, nil
 }
 
@@ -22,3 +22,7 @@ func (w *MockQtumWallet) GetEthereumAddress() *common.Address {
 func (w *MockQtumWallet) GetQtumAddress() (string, error) {
 	return "", nil
 }
+
+func (w *MockQtumWallet) GetQtumAddresses() ([]string, error) {
+	return nil, nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/alejoacosta74/gologger"
 	"github.com/alejoacosta74/qproxy/pkg/log"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
 
 	"github.com/qtumproject/btcd/chaincfg"
 )
@@ -52,11 +53,11 @@ func SetLogger() {
 func SetNetworkConfig() {
 	switch Network {
 	case "mainnet":
-		ChainCfg = &chaincfg.QtumMainnetParams
+		ChainCfg = &qtypes.QtumMainnetParams
 	case "testnet":
-		ChainCfg = &chaincfg.QtumTestnetParams
+		ChainCfg = &qtypes.QtumTestnetParams
 	case "regtest":
-		ChainCfg = &chaincfg.QtumRegtestParams
+		ChainCfg = &qtypes.QtumRegtestParams
 	default:
 		panic("wrong network")
 	}
@@ -2,13 +2,16 @@ package qtum
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"os"
 	"strings"
 
 	"github.com/alejoacosta74/qproxy/pkg/log"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
 	"github.com/btcsuite/btclog"
 	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcutil"
 	"github.com/qtumproject/btcd/chaincfg"
 	"github.com/qtumproject/btcd/rpcclient"
 )
@@ -16,7 +19,11 @@ import (
 // QtumClient is a wrapper for the btcd rpcclient used to connect to a Qtum Node
 type QtumClient struct {
 	*rpcclient.Client
-	cfg *chaincfg.Params
+	cfg      *chaincfg.Params
+	feeCfg   FeeConfig
+	selector CoinSelector
+	minConf  int64
+	dust     btcutil.Amount
 }
 
 func NewQtumClient(host, user, pass, network string) (*QtumClient, error) {
@@ -56,6 +63,10 @@ func NewQtumClient(host, user, pass, network string) (*QtumClient, error) {
 	qcli := QtumClient{
 		qclient,
 		nil,
+		DefaultFeeConfig(),
+		&LargestFirstSelector{},
+		DefaultMinConfirmations,
+		DefaultDustThreshold,
 	}
 
 	cfg, err := qcli.determineNetworkParams(network)
@@ -100,16 +111,45 @@ func (q *QtumClient) checkNetworkConfig() (*chaincfg.Params, error) {
 	}
 	log.With("module", "qcli").Debugf("Qtum network testnet: %+v", info.TestNet)
 	if info.TestNet {
-		return &chaincfg.QtumTestnetParams, nil
+		return &qtypes.QtumTestnetParams, nil
 	} else {
-		return &chaincfg.QtumMainnetParams, nil
+		return &qtypes.QtumMainnetParams, nil
 	}
 }
 
+// rawRequest sends a JSON-RPC request for a method not implemented by the
+// btcd rpcclient and unmarshals the response into result
+func (q *QtumClient) rawRequest(method string, params []interface{}, result interface{}) error {
+	rawParams := make([]json.RawMessage, 0, len(params))
+	for _, param := range params {
+		rawParam, err := json.Marshal(param)
+		if err != nil {
+			return errors.Wrapf(err, "Error marshalling param %v for method %s", param, method)
+		}
+		rawParams = append(rawParams, rawParam)
+	}
+	response, err := q.RawRequest(method, rawParams)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response, result)
+}
+
+// NetworkParams returns the params of the network the client is configured for
+func (q *QtumClient) NetworkParams() *chaincfg.Params {
+	return q.cfg
+}
+
 func (q *QtumClient) determineNetworkParams(network string) (*chaincfg.Params, error) {
-	if network == "testnet" || network == "regtest" {
-		return &chaincfg.QtumTestnetParams, nil
+	// regtest shares the address ids of testnet, but not the prefix of the segwit addresses
+	if network == "regtest" {
+		return &qtypes.QtumRegtestParams, nil
+	} else if network == "testnet" {
+		return &qtypes.QtumTestnetParams, nil
 	} else {
-		return &chaincfg.QtumMainnetParams, nil
+		return &qtypes.QtumMainnetParams, nil
 	}
 }
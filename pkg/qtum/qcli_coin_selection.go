@@ -0,0 +1,338 @@
+package qtum
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/qtumproject/btcd/wire"
+)
+
+const (
+	// DefaultMinConfirmations is the default number of confirmations
+	// an unspent output needs to be selected as input
+	DefaultMinConfirmations = 6
+	// DefaultCoinSelection is the default coin selection strategy
+	DefaultCoinSelection = LargestFirst
+
+	// LargestFirst selects the largest unspent outputs first
+	LargestFirst = "largest-first"
+	// SmallestFirst selects the smallest unspent outputs first, consolidating UTXOs
+	SmallestFirst = "smallest-first"
+	// BranchAndBound searches for a selection that needs no change output,
+	// falling back to largest-first when there is none
+	BranchAndBound = "bnb"
+	// RandomImprove selects random unspent outputs and then improves the selection
+	// to create a change output of about the size of the amount paid
+	RandomImprove = "random-improve"
+
+	// bnbMaxTries is the maximum number of selections evaluated by the branch and bound search
+	bnbMaxTries = 100000
+)
+
+// ErrInsufficientFunds is returned when the unspent outputs can not pay for the amount and the fee
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// ErrNothingToConsolidate is returned when there are not enough unspent outputs worth merging
+var ErrNothingToConsolidate = errors.New("nothing to consolidate")
+
+// ErrNoExactMatch is returned by the branch and bound selector when no selection avoids the change output
+var ErrNoExactMatch = errors.New("no exact match found for the amount")
+
+// CoinSelector selects the unspent outputs to be spent by a transaction
+type CoinSelector interface {
+	// Select returns the unspent outputs, among the candidates, to be used as inputs
+	// to pay for the target amount and the fee of the resulting transaction
+	Select(candidates []btcjson.ListUnspentResult, target *CoinSelectionTarget) ([]btcjson.ListUnspentResult, error)
+}
+
+// NewCoinSelector returns the coin selector for the given strategy:
+// largest-first, smallest-first, bnb or random-improve
+func NewCoinSelector(strategy string) (CoinSelector, error) {
+	switch strategy {
+	case LargestFirst:
+		return &LargestFirstSelector{}, nil
+	case SmallestFirst:
+		return &SmallestFirstSelector{}, nil
+	case BranchAndBound:
+		return &BranchAndBoundSelector{Fallback: &LargestFirstSelector{}}, nil
+	case RandomImprove:
+		return NewRandomImproveSelector(time.Now().UnixNano()), nil
+	default:
+		return nil, errors.Errorf("unknown coin selection strategy: %s", strategy)
+	}
+}
+
+// CoinSelectionTarget describes the transaction the coins are selected for
+type CoinSelectionTarget struct {
+	// Amount is the value to pay with the selected coins, excluding the tx fee
+	Amount btcutil.Amount
+	// Size is the estimated size in bytes of the signed tx without inputs and change output
+	Size int
+	// FeeRate is the fee rate to pay, in satoshis per kB
+	FeeRate btcutil.Amount
+}
+
+// minValue returns the effective value the selected coins must add up to
+// for a transaction without change output
+func (t *CoinSelectionTarget) minValue() btcutil.Amount {
+	return t.Amount + calculateFee(t.Size, t.FeeRate)
+}
+
+// changeCost returns the cost of creating a change output and spending it later
+func (t *CoinSelectionTarget) changeCost() btcutil.Amount {
+	return calculateFee(changeOutputSize+p2pkhInputSize, t.FeeRate)
+}
+
+// coin is an unspent output along with its effective value,
+// i.e. its value minus the fee paid to spend it
+type coin struct {
+	utxo      btcjson.ListUnspentResult
+	effective btcutil.Amount
+}
+
+// effectiveCoins returns the candidates as coins, skipping the
+// ones that cost more in fees than their value
+func effectiveCoins(candidates []btcjson.ListUnspentResult, feeRate btcutil.Amount) ([]coin, error) {
+	coins := make([]coin, 0, len(candidates))
+	for _, utxo := range candidates {
+		value, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error converting amount of utxo %s:%d", utxo.TxID, utxo.Vout)
+		}
+		inputSize, err := estimateInputVirtualSize(utxo.ScriptPubKey)
+		if err != nil {
+			return nil, err
+		}
+		effective := value - calculateFee(inputSize, feeRate)
+		if effective <= 0 {
+			log.With("module", "qtum").Tracef("Skipping uneconomical utxo %s:%d", utxo.TxID, utxo.Vout)
+			continue
+		}
+		coins = append(coins, coin{utxo: utxo, effective: effective})
+	}
+	return coins, nil
+}
+
+// selectInOrder selects coins in the given order until they pay for the target
+func selectInOrder(coins []coin, target *CoinSelectionTarget) ([]btcjson.ListUnspentResult, error) {
+	var selected []btcjson.ListUnspentResult
+	var total btcutil.Amount
+	for _, c := range coins {
+		selected = append(selected, c.utxo)
+		total += c.effective
+		if total >= target.minValue() {
+			return selected, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrInsufficientFunds, "%v available to pay %v", total, target.minValue())
+}
+
+// LargestFirstSelector selects the largest unspent outputs first,
+// minimizing the number of inputs of the transaction
+type LargestFirstSelector struct{}
+
+// Select implements CoinSelector
+func (s *LargestFirstSelector) Select(candidates []btcjson.ListUnspentResult, target *CoinSelectionTarget) ([]btcjson.ListUnspentResult, error) {
+	coins, err := effectiveCoins(candidates, target.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(coins, func(i, j int) bool { return coins[i].effective > coins[j].effective })
+	return selectInOrder(coins, target)
+}
+
+// SmallestFirstSelector selects the smallest unspent outputs first,
+// consolidating small UTXOs at the cost of bigger transactions
+type SmallestFirstSelector struct{}
+
+// Select implements CoinSelector
+func (s *SmallestFirstSelector) Select(candidates []btcjson.ListUnspentResult, target *CoinSelectionTarget) ([]btcjson.ListUnspentResult, error) {
+	coins, err := effectiveCoins(candidates, target.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(coins, func(i, j int) bool { return coins[i].effective < coins[j].effective })
+	return selectInOrder(coins, target)
+}
+
+// BranchAndBoundSelector searches for a selection whose value exceeds the target by
+// less than the cost of a change output, so the transaction needs no change.
+// If there is no such selection, the Fallback selector is used (if any)
+type BranchAndBoundSelector struct {
+	Fallback CoinSelector
+}
+
+// Select implements CoinSelector
+func (s *BranchAndBoundSelector) Select(candidates []btcjson.ListUnspentResult, target *CoinSelectionTarget) ([]btcjson.ListUnspentResult, error) {
+	coins, err := effectiveCoins(candidates, target.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(coins, func(i, j int) bool { return coins[i].effective > coins[j].effective })
+
+	best := searchExactMatch(coins, target.minValue(), target.minValue()+target.changeCost())
+	if best == nil {
+		if s.Fallback == nil {
+			return nil, ErrNoExactMatch
+		}
+		log.With("module", "qtum").Debugf("No exact match found, using fallback coin selection")
+		return s.Fallback.Select(candidates, target)
+	}
+	selected := make([]btcjson.ListUnspentResult, 0, len(best))
+	for _, i := range best {
+		selected = append(selected, coins[i].utxo)
+	}
+	return selected, nil
+}
+
+// searchExactMatch runs a depth first search over the coins (sorted by descending value)
+// for the selection with a total value in the range [min, max] that wastes the least.
+// It returns the indexes of the selected coins, or nil if no selection was found
+func searchExactMatch(coins []coin, min, max btcutil.Amount) []int {
+	var remaining btcutil.Amount
+	for _, c := range coins {
+		remaining += c.effective
+	}
+
+	var best, selected []int
+	bestWaste := max - min + 1
+	tries := 0
+	var search func(i int, total, remaining btcutil.Amount)
+	search = func(i int, total, remaining btcutil.Amount) {
+		if tries >= bnbMaxTries || bestWaste == 0 || total > max {
+			return
+		}
+		tries++
+		if total >= min {
+			if waste := total - min; waste < bestWaste {
+				best = append([]int(nil), selected...)
+				bestWaste = waste
+			}
+			return
+		}
+		if i == len(coins) || total+remaining < min {
+			return
+		}
+		value := coins[i].effective
+		// branch including the coin first, then excluding it
+		selected = append(selected, i)
+		search(i+1, total+value, remaining-value)
+		selected = selected[:len(selected)-1]
+		search(i+1, total, remaining-value)
+	}
+	search(0, 0, remaining)
+	return best
+}
+
+// RandomImproveSelector implements the random-improve algorithm: it selects random
+// unspent outputs until the target is paid, then keeps adding random outputs while
+// they bring the selected value closer to twice the target (without exceeding three
+// times the target), so the change output is about the size of the amount paid
+type RandomImproveSelector struct {
+	rand *rand.Rand
+}
+
+// NewRandomImproveSelector returns a random-improve selector using the given random seed
+func NewRandomImproveSelector(seed int64) *RandomImproveSelector {
+	return &RandomImproveSelector{rand: rand.New(rand.NewSource(seed))}
+}
+
+// Select implements CoinSelector
+func (s *RandomImproveSelector) Select(candidates []btcjson.ListUnspentResult, target *CoinSelectionTarget) ([]btcjson.ListUnspentResult, error) {
+	coins, err := effectiveCoins(candidates, target.FeeRate)
+	if err != nil {
+		return nil, err
+	}
+	s.rand.Shuffle(len(coins), func(i, j int) { coins[i], coins[j] = coins[j], coins[i] })
+
+	// random selection phase
+	selected, err := selectInOrder(coins, target)
+	if err != nil {
+		return nil, err
+	}
+	var total btcutil.Amount
+	for _, c := range coins[:len(selected)] {
+		total += c.effective
+	}
+
+	// improvement phase
+	ideal, max := 2*target.minValue(), 3*target.minValue()
+	for _, c := range coins[len(selected):] {
+		improved := total + c.effective
+		if improved <= max && absAmount(ideal-improved) < absAmount(ideal-total) {
+			selected = append(selected, c.utxo)
+			total = improved
+		}
+	}
+	return selected, nil
+}
+
+// absAmount returns the absolute value of the amount
+func absAmount(a btcutil.Amount) btcutil.Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// SetCoinSelector sets the strategy used to select the unspent outputs spent by the transactions
+func (q *QtumClient) SetCoinSelector(selector CoinSelector) {
+	q.selector = selector
+}
+
+// SetMinConfirmations sets the number of confirmations an unspent output needs to be spent
+func (q *QtumClient) SetMinConfirmations(minConf int64) error {
+	if minConf < 0 {
+		return errors.Errorf("min confirmations can not be negative: %d", minConf)
+	}
+	q.minConf = minConf
+	return nil
+}
+
+// selectCoins selects, among the unspent outputs with the minimum number of confirmations,
+// the ones to spend to pay for the outputs of the tx, the given extra fee and the tx fee
+//
+// Params:
+//   - tx: the transaction with all its outputs but the change
+//   - unspent: the candidate unspent outputs
+//   - extraFee: the amount paid as fee on top of the size based fee (i.e. contract gas)
+func (q *QtumClient) selectCoins(tx *wire.MsgTx, unspent []btcjson.ListUnspentResult, extraFee float64) ([]btcjson.ListUnspentResult, error) {
+	var candidates []btcjson.ListUnspentResult
+	for _, utxo := range unspent {
+		if utxo.Confirmations >= q.minConf {
+			candidates = append(candidates, utxo)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.Wrapf(ErrInsufficientFunds, "no unspent outputs with %d confirmations", q.minConf)
+	}
+
+	feeRate, err := q.EstimateFeeRate()
+	if err != nil {
+		return nil, err
+	}
+	size, err := estimateSignedSize(tx, nil)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := btcutil.NewAmount(extraFee)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error converting amount %v", extraFee)
+	}
+	for _, txOut := range tx.TxOut {
+		amount += btcutil.Amount(txOut.Value)
+	}
+	target := &CoinSelectionTarget{Amount: amount, Size: size, FeeRate: feeRate}
+
+	selected, err := q.selector.Select(candidates, target)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error selecting unspent outputs")
+	}
+	log.With("module", "qtum").Debugf("Selected %d of %d unspent outputs to pay %v", len(selected), len(candidates), amount)
+	return selected, nil
+}
@@ -0,0 +1,134 @@
+package qtum
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestCoins returns P2PKH unspent outputs of the sender with the given amounts in Qtum
+func newTestCoins(amounts ...float64) []btcjson.ListUnspentResult {
+	coins := make([]btcjson.ListUnspentResult, 0, len(amounts))
+	for i, amount := range amounts {
+		coins = append(coins, btcjson.ListUnspentResult{
+			TxID:          fmt.Sprintf("%064x", i+1),
+			Vout:          0,
+			Address:       SENDER_ADDR,
+			ScriptPubKey:  "76a9147926223070547d2d15b2ef5e7383e541c338ffe988ac",
+			Amount:        amount,
+			Confirmations: 100,
+		})
+	}
+	return coins
+}
+
+// selectedAmounts returns the amounts of the selected unspent outputs
+func selectedAmounts(selected []btcjson.ListUnspentResult) []float64 {
+	amounts := make([]float64, 0, len(selected))
+	for _, utxo := range selected {
+		amounts = append(amounts, utxo.Amount)
+	}
+	return amounts
+}
+
+// testTarget pays 2.5 Qtum with a tx of 44 bytes (version, locktime and one P2PKH output)
+var testTarget = &CoinSelectionTarget{Amount: 250000000, Size: 44, FeeRate: testFeeRate}
+
+func TestLargestFirstSelector(t *testing.T) {
+	selected, err := (&LargestFirstSelector{}).Select(newTestCoins(1, 3, 0.5, 2), testTarget)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{3}, selectedAmounts(selected))
+
+	selected, err = (&LargestFirstSelector{}).Select(newTestCoins(1, 0.5, 2), testTarget)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{2, 1}, selectedAmounts(selected))
+
+	_, err = (&LargestFirstSelector{}).Select(newTestCoins(1, 0.5, 1), testTarget)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestSmallestFirstSelector(t *testing.T) {
+	selected, err := (&SmallestFirstSelector{}).Select(newTestCoins(1, 3, 0.5, 2), testTarget)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 1, 2}, selectedAmounts(selected))
+
+	// outputs worth less than the fee to spend them are never selected
+	selected, err = (&SmallestFirstSelector{}).Select(newTestCoins(0.0001, 3), testTarget)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{3}, selectedAmounts(selected))
+}
+
+func TestBranchAndBoundSelector(t *testing.T) {
+	// fee of the tx without change and with one input: (44 + 149) bytes * 400 satoshis/byte
+	exact := (testTarget.Amount + calculateFee(44+p2pkhInputSize, testFeeRate)).ToBTC()
+
+	selector := &BranchAndBoundSelector{}
+	selected, err := selector.Select(newTestCoins(3, exact, 1, 2), testTarget)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{exact}, selectedAmounts(selected))
+
+	// 1.5 + 1 pays the amount and the fee of two inputs within the cost of change
+	selected, err = selector.Select(newTestCoins(3, 1.5, 1.0015, 2), testTarget)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.5, 1.0015}, selectedAmounts(selected))
+
+	_, err = selector.Select(newTestCoins(3, 2), testTarget)
+	assert.ErrorIs(t, err, ErrNoExactMatch)
+
+	// without exact match, the fallback selector is used
+	selector.Fallback = &LargestFirstSelector{}
+	selected, err = selector.Select(newTestCoins(3, 2), testTarget)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{3}, selectedAmounts(selected))
+}
+
+func TestRandomImproveSelector(t *testing.T) {
+	coins := newTestCoins(0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5, 0.5)
+	selector := NewRandomImproveSelector(1)
+	for i := 0; i < 10; i++ {
+		selected, err := selector.Select(coins, testTarget)
+		assert.NoError(t, err)
+		// the selection pays the target and gets close to twice the target
+		var total btcutil.Amount
+		for _, utxo := range selected {
+			amount, _ := btcutil.NewAmount(utxo.Amount)
+			total += amount - calculateFee(p2pkhInputSize, testFeeRate)
+		}
+		assert.GreaterOrEqual(t, total, 2*testTarget.minValue()-50000000)
+		assert.LessOrEqual(t, total, 3*testTarget.minValue())
+	}
+
+	_, err := selector.Select(newTestCoins(1, 1), testTarget)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestSelectCoinsMinConfirmations(t *testing.T) {
+	qcli := newTestQtumClient(t)
+	coins := newTestCoins(1, 3, 2)
+	coins[1].Confirmations = 2
+
+	tx, _, err := qcli.BuildUnsignedQtumTx(coins, SENDER_ADDR, RECEIVER_ADDR, 2.5)
+	assert.NoError(t, err)
+	// the largest output has not enough confirmations
+	assert.Equal(t, 2, len(tx.TxIn))
+
+	err = qcli.SetMinConfirmations(1)
+	assert.NoError(t, err)
+	tx, _, err = qcli.BuildUnsignedQtumTx(coins, SENDER_ADDR, RECEIVER_ADDR, 2.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tx.TxIn))
+	assert.Equal(t, coins[1].TxID, tx.TxIn[0].PreviousOutPoint.Hash.String())
+}
+
+func TestNewCoinSelector(t *testing.T) {
+	for _, strategy := range []string{LargestFirst, SmallestFirst, BranchAndBound, RandomImprove} {
+		selector, err := NewCoinSelector(strategy)
+		assert.NoError(t, err)
+		assert.NotNil(t, selector)
+	}
+	_, err := NewCoinSelector("unknown")
+	assert.Error(t, err)
+}
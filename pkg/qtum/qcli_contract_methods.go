@@ -0,0 +1,336 @@
+package qtum
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/qtumproject/btcd/chaincfg"
+	"github.com/qtumproject/btcd/chaincfg/chainhash"
+	"github.com/qtumproject/btcd/txscript"
+	"github.com/qtumproject/btcd/wire"
+)
+
+const (
+	// senderAddressType is the address type pushed in OP_SENDER scripts for a pubkeyhash sender
+	senderAddressType = 1
+	// contractAddressLength is the length in bytes of a contract address
+	contractAddressLength = 20
+)
+
+// BuildContractScript returns the output script used to create or call
+// a contract depending on the given params
+func BuildContractScript(params *qtypes.ContractParams) ([]byte, error) {
+	if params.IsCreate() {
+		return BuildCreateScript(params)
+	}
+	return BuildCallScript(params)
+}
+
+// BuildCreateScript returns the output script used to deploy a contract on qtum:
+//
+//	<version> <gasLimit> <gasPrice> <bytecode> OP_CREATE
+func BuildCreateScript(params *qtypes.ContractParams) ([]byte, error) {
+	if len(params.Data) == 0 {
+		return nil, errors.New("contract bytecode is empty")
+	}
+	script, err := txscript.NewScriptBuilder().
+		AddInt64(qtypes.ContractVMVersion).
+		AddData(scriptNum(params.GasLimit)).
+		AddData(scriptNum(params.GasPrice)).
+		// bytecode routinely exceeds the standard push size limit,
+		// which does not apply to qtum contract outputs
+		AddFullData(params.Data).
+		Script()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error building OP_CREATE script")
+	}
+	return append(script, txscript.OP_CREATE), nil
+}
+
+// BuildCallScript returns the output script used to call a contract on qtum:
+//
+//	<version> <gasLimit> <gasPrice> <data> <contract address> OP_CALL
+func BuildCallScript(params *qtypes.ContractParams) ([]byte, error) {
+	address, err := hex.DecodeString(params.Address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error decoding contract address: %s", params.Address)
+	}
+	if len(address) != contractAddressLength {
+		return nil, errors.Errorf("invalid contract address length: %s", params.Address)
+	}
+	script, err := txscript.NewScriptBuilder().
+		AddInt64(qtypes.ContractVMVersion).
+		AddData(scriptNum(params.GasLimit)).
+		AddData(scriptNum(params.GasPrice)).
+		AddFullData(params.Data).
+		AddData(address).
+		Script()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error building OP_CALL script")
+	}
+	return append(script, txscript.OP_CALL), nil
+}
+
+// buildSenderScript prepends the OP_SENDER prefix to a contract script:
+//
+//	<address type> <sender pubkeyhash> <sender scriptSig> OP_SENDER <contract script>
+//
+// qtumd requires the prefix to learn the sender of a contract
+// tx whose first input is neither P2PKH nor P2PK.
+func buildSenderScript(senderPkh []byte, sigScript []byte, contractScript []byte) ([]byte, error) {
+	script, err := txscript.NewScriptBuilder().
+		AddInt64(senderAddressType).
+		AddData(senderPkh).
+		AddFullData(sigScript).
+		AddOp(txscript.OP_SENDER).
+		Script()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error building OP_SENDER script")
+	}
+	return append(script, contractScript...), nil
+}
+
+// parseSenderScript splits an output script with an OP_SENDER prefix into the
+// sender pubkeyhash and the contract script. ok is false if the script has no OP_SENDER prefix
+func parseSenderScript(script []byte) (senderPkh []byte, contractScript []byte, ok bool) {
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	// <address type>
+	if !tokenizer.Next() || tokenizer.Opcode() != txscript.OP_1 {
+		return nil, nil, false
+	}
+	// <sender pubkeyhash>
+	if !tokenizer.Next() || len(tokenizer.Data()) != 20 {
+		return nil, nil, false
+	}
+	senderPkh = tokenizer.Data()
+	// <sender scriptSig> OP_SENDER
+	if !tokenizer.Next() || !tokenizer.Next() || tokenizer.Opcode() != txscript.OP_SENDER {
+		return nil, nil, false
+	}
+	return senderPkh, script[tokenizer.ByteIndex():], true
+}
+
+// needsSenderScript returns true if the contract sender determined by qtumd from the
+// first input of the transaction is not the sender with the given pubkey hash, i.e.
+// the input is not P2PKH or P2PK, or it pays to another key (e.g. the uncompressed
+// pubkey of the sender wallet)
+func needsSenderScript(unspent []btcjson.ListUnspentResult, senderPkh []byte) (bool, error) {
+	if len(unspent) == 0 {
+		return false, errors.New("no unspent outputs to spend")
+	}
+	scriptPubKey, err := hex.DecodeString(unspent[0].ScriptPubKey)
+	if err != nil {
+		return false, errors.Wrapf(err, "Error decoding scriptPubKey: %s", unspent[0].ScriptPubKey)
+	}
+	switch txscript.GetScriptClass(scriptPubKey) {
+	case txscript.PubKeyHashTy:
+		// OP_DUP OP_HASH160 <pubkey hash> OP_EQUALVERIFY OP_CHECKSIG
+		return !bytes.Equal(scriptPubKey[3:23], senderPkh), nil
+	case txscript.PubKeyTy:
+		// <pubkey> OP_CHECKSIG
+		pubKey := scriptPubKey[1 : len(scriptPubKey)-1]
+		return !bytes.Equal(btcutil.Hash160(pubKey), senderPkh), nil
+	default:
+		return true, nil
+	}
+}
+
+// signSenderOutputs signs the OP_SENDER prefix of every contract output of the tx with the
+// key of the sender. Sender signatures do not commit to the inputs scriptSigs, so they are
+// produced before signing the inputs.
+func signSenderOutputs(tx *wire.MsgTx, w wallet.IQtumWallet, cfg *chaincfg.Params) error {
+	for i, txOut := range tx.TxOut {
+		senderPkh, _, ok := parseSenderScript(txOut.PkScript)
+		if !ok {
+			continue
+		}
+		senderAddr, err := btcutil.NewAddressPubKeyHash(senderPkh, cfg)
+		if err != nil {
+			return errors.Wrapf(err, "Error decoding sender of output %d", i)
+		}
+		signer, err := w.GetSigner(senderAddr.EncodeAddress())
+		if err != nil {
+			return errors.Wrapf(err, "Error getting signer for sender: %s", senderAddr.EncodeAddress())
+		}
+		sigHash, err := calcSenderSigHash(tx, i, senderPkh, txscript.SigHashAll)
+		if err != nil {
+			return errors.Wrapf(err, "Error calculating sender signature hash for output %d", i)
+		}
+		signature, err := signWithHashType(signer, sigHash, txscript.SigHashAll)
+		if err != nil {
+			return errors.Wrapf(err, "Error signing sender of output %d", i)
+		}
+		sigScript, err := txscript.NewScriptBuilder().
+			AddData(signature).
+			AddData(signer.PublicKey().SerializeCompressed()).
+			Script()
+		if err != nil {
+			return errors.Wrapf(err, "Error creating sender scriptSig for output %d", i)
+		}
+		_, contractScript, _ := parseSenderScript(txOut.PkScript)
+		txOut.PkScript, err = buildSenderScript(senderPkh, sigScript, contractScript)
+		if err != nil {
+			return err
+		}
+		log.With("module", "qtum").Tracef("signed sender of output %d: %x", i, txOut.PkScript)
+	}
+	return nil
+}
+
+// calcSenderSigHash computes the hash signed by the sender of a contract output, following
+// qtumd SignatureHashOutput: a BIP143 digest where the output being signed takes the place
+// of the input, the P2PKH script of the sender is used as script code and the outputs are
+// committed to without their sender signatures.
+func calcSenderSigHash(tx *wire.MsgTx, idx int, senderPkh []byte, hashType txscript.SigHashType) ([]byte, error) {
+	scriptCode, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).
+		AddData(senderPkh).
+		AddOp(txscript.OP_EQUALVERIFY).
+		AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+
+	var prevouts, sequences, outputs bytes.Buffer
+	for _, txIn := range tx.TxIn {
+		prevouts.Write(txIn.PreviousOutPoint.Hash[:])
+		binary.Write(&prevouts, binary.LittleEndian, txIn.PreviousOutPoint.Index)
+		binary.Write(&sequences, binary.LittleEndian, txIn.Sequence)
+	}
+	for _, txOut := range tx.TxOut {
+		script := txOut.PkScript
+		if pkh, contractScript, ok := parseSenderScript(script); ok {
+			script, err = buildSenderScript(pkh, nil, contractScript)
+			if err != nil {
+				return nil, err
+			}
+		}
+		wire.WriteTxOut(&outputs, 0, 0, wire.NewTxOut(txOut.Value, script))
+	}
+
+	var sigHash bytes.Buffer
+	binary.Write(&sigHash, binary.LittleEndian, tx.Version)
+	sigHash.Write(chainhash.DoubleHashB(prevouts.Bytes()))
+	sigHash.Write(chainhash.DoubleHashB(sequences.Bytes()))
+	binary.Write(&sigHash, binary.LittleEndian, uint32(idx))
+	wire.WriteVarBytes(&sigHash, 0, scriptCode)
+	binary.Write(&sigHash, binary.LittleEndian, tx.TxOut[idx].Value)
+	sigHash.Write(chainhash.DoubleHashB(outputs.Bytes()))
+	binary.Write(&sigHash, binary.LittleEndian, tx.LockTime)
+	binary.Write(&sigHash, binary.LittleEndian, uint32(hashType))
+
+	return chainhash.DoubleHashB(sigHash.Bytes()), nil
+}
+
+// CallContract executes a contract call on the node without creating a transaction
+// (i.e. the node's callcontract RPC) and returns the execution result.
+//
+// Params:
+//   - address: the hex address (without 0x prefix) of the contract
+//   - data: the hex encoded (without 0x prefix) ABI data of the call
+//   - sender: the sender address in base58 format. If empty, gasLimit and amount are ignored
+//   - gasLimit: the gas limit of the execution. If 0, the node's default is used
+//   - amount: the amount in Qtum sent to the contract
+func (q *QtumClient) CallContract(address, data, sender string, gasLimit uint64, amount float64) (*qtypes.CallContractResult, error) {
+	params := []interface{}{address, data}
+	if sender != "" {
+		params = append(params, sender)
+		if gasLimit > 0 || amount > 0 {
+			if gasLimit == 0 {
+				gasLimit = qtypes.DefaultCallGasLimit
+			}
+			params = append(params, gasLimit)
+		}
+		if amount > 0 {
+			params = append(params, amount)
+		}
+	}
+	var result qtypes.CallContractResult
+	err := q.rawRequest("callcontract", params, &result)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error calling contract: %s", address)
+	}
+	log.With("module", "qtum").Tracef("callcontract result: %+v", result)
+	return &result, nil
+}
+
+// GetTransactionReceipt returns the receipts of the contract outputs of the transaction
+// (i.e. the node's gettransactionreceipt RPC). The list is empty if the transaction is
+// not mined or has no contract outputs.
+//
+// The node must run with -logevents for the receipts to be available
+func (q *QtumClient) GetTransactionReceipt(txid string) ([]qtypes.TransactionReceipt, error) {
+	var receipts []qtypes.TransactionReceipt
+	err := q.rawRequest("gettransactionreceipt", []interface{}{txid}, &receipts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error getting transaction receipt: %s", txid)
+	}
+	log.With("module", "qtum").Tracef("gettransactionreceipt result: %+v", receipts)
+	return receipts, nil
+}
+
+// SearchLogs returns the receipts of the contract executions in the given block range
+// with logs emitted by the given contract addresses and matching the given topics
+// (i.e. the node's searchlogs RPC). A toBlock of -1 searches up to the latest block.
+//
+// Addresses and topics are hex encoded without 0x prefix. A nil topic matches any
+// topic at its position, and no addresses match any contract.
+//
+// The node must run with -logevents for the logs to be available
+func (q *QtumClient) SearchLogs(fromBlock, toBlock int64, addresses []string, topics []*string) ([]qtypes.TransactionReceipt, error) {
+	if addresses == nil {
+		addresses = []string{}
+	}
+	if topics == nil {
+		topics = []*string{}
+	}
+	params := []interface{}{
+		fromBlock,
+		toBlock,
+		map[string][]string{"addresses": addresses},
+		map[string][]*string{"topics": topics},
+	}
+	var receipts []qtypes.TransactionReceipt
+	err := q.rawRequest("searchlogs", params, &receipts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error searching logs from block %d to %d", fromBlock, toBlock)
+	}
+	log.With("module", "qtum").Tracef("searchlogs result: %+v", receipts)
+	return receipts, nil
+}
+
+// ContractAddress returns the hex address (without 0x prefix) of the contract created by the
+// output vout of the transaction txid.
+//
+// The address is computed the same way as qtumd does: RIPEMD160(SHA256(txid || vout)),
+// where the txid is in internal byte order and vout is a 4 byte little endian integer
+func ContractAddress(txid *chainhash.Hash, vout uint32) string {
+	buf := make([]byte, 0, chainhash.HashSize+4)
+	buf = append(buf, txid[:]...)
+	buf = append(buf, byte(vout), byte(vout>>8), byte(vout>>16), byte(vout>>24))
+	return hex.EncodeToString(btcutil.Hash160(buf))
+}
+
+// scriptNum encodes an unsigned integer as a minimal little endian byte slice,
+// as expected by qtumd when parsing gas values from contract scripts
+func scriptNum(n uint64) []byte {
+	var buf []byte
+	for n > 0 {
+		buf = append(buf, byte(n&0xff))
+		n >>= 8
+	}
+	// keep the number positive if the most significant bit is set
+	if len(buf) > 0 && buf[len(buf)-1]&0x80 != 0 {
+		buf = append(buf, 0x00)
+	}
+	return buf
+}
@@ -0,0 +1,367 @@
+package qtum
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/qtumproject/btcd/btcec/v2"
+	"github.com/qtumproject/btcd/btcec/v2/ecdsa"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/qtumproject/btcd/chaincfg/chainhash"
+	"github.com/qtumproject/btcd/txscript"
+	"github.com/qtumproject/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// bytecode of a minimal contract used for testing
+const CONTRACT_BYTECODE = "6080604052348015600f57600080fd5b50603f80601d6000396000f3fe6080604052600080fdfea164736f6c6343000811000a"
+
+func TestBuildCreateScript(t *testing.T) {
+	bytecode, _ := hex.DecodeString(CONTRACT_BYTECODE)
+	params := &qtypes.ContractParams{
+		GasLimit: 2500000,
+		GasPrice: 40,
+		Data:     bytecode,
+	}
+	script, err := BuildCreateScript(params)
+	utils.HandleFatalError(t, err)
+
+	// <version> <gasLimit> <gasPrice> <bytecode> OP_CREATE
+	pushes, err := txscript.PushedData(script[:len(script)-1])
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, byte(txscript.OP_4), script[0])
+	assert.Equal(t, []byte{0xa0, 0x25, 0x26}, pushes[0])
+	assert.Equal(t, []byte{0x28}, pushes[1])
+	assert.Equal(t, bytecode, pushes[2])
+	assert.Equal(t, byte(txscript.OP_CREATE), script[len(script)-1])
+
+	t.Run("empty bytecode", func(t *testing.T) {
+		_, err := BuildCreateScript(&qtypes.ContractParams{GasLimit: 2500000, GasPrice: 40})
+		assert.Error(t, err)
+	})
+}
+
+func TestBuildCallScript(t *testing.T) {
+	data, _ := hex.DecodeString("a9059cbb")
+	params := &qtypes.ContractParams{
+		GasLimit: 250000,
+		GasPrice: 40,
+		Data:     data,
+		Address:  "71517f86711b4bff4d789ad6fee9a58d8af1c6bb",
+	}
+	script, err := BuildContractScript(params)
+	utils.HandleFatalError(t, err)
+
+	// <version> <gasLimit> <gasPrice> <data> <contract address> OP_CALL
+	pushes, err := txscript.PushedData(script[:len(script)-1])
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, byte(txscript.OP_4), script[0])
+	assert.Equal(t, []byte{0x90, 0xd0, 0x03}, pushes[0])
+	assert.Equal(t, data, pushes[2])
+	assert.Equal(t, params.Address, hex.EncodeToString(pushes[3]))
+	assert.Equal(t, byte(txscript.OP_CALL), script[len(script)-1])
+
+	t.Run("invalid contract address", func(t *testing.T) {
+		params := &qtypes.ContractParams{GasLimit: 250000, GasPrice: 40, Data: data, Address: "71517f86"}
+		_, err := BuildCallScript(params)
+		assert.Error(t, err)
+	})
+}
+
+func TestSenderScript(t *testing.T) {
+	pkh, _ := hex.DecodeString("7926223070547d2d15b2ef5e7383e541c338ffe9")
+	contractScript, err := BuildCallScript(&qtypes.ContractParams{
+		GasLimit: 250000,
+		GasPrice: 40,
+		Address:  "71517f86711b4bff4d789ad6fee9a58d8af1c6bb",
+	})
+	utils.HandleFatalError(t, err)
+
+	script, err := buildSenderScript(pkh, nil, contractScript)
+	utils.HandleFatalError(t, err)
+
+	gotPkh, gotContractScript, ok := parseSenderScript(script)
+	assert.True(t, ok)
+	assert.Equal(t, pkh, gotPkh)
+	assert.Equal(t, contractScript, gotContractScript)
+
+	_, _, ok = parseSenderScript(contractScript)
+	assert.False(t, ok)
+}
+
+func TestNeedsSenderScript(t *testing.T) {
+	const senderPkh = "7926223070547d2d15b2ef5e7383e541c338ffe9"
+	privKeyBytes, _ := hex.DecodeString(SENDER_PRIVKEY)
+	_, pubKey := btcec.PrivKeyFromBytes(privKeyBytes)
+
+	tests := []struct {
+		name         string
+		scriptPubKey string
+		want         bool
+	}{
+		{name: "P2PKH of sender", scriptPubKey: "76a914" + senderPkh + "88ac", want: false},
+		{name: "P2PK of sender", scriptPubKey: "21" + hex.EncodeToString(pubKey.SerializeCompressed()) + "ac", want: false},
+		{name: "uncompressed P2PKH", scriptPubKey: "76a914" + hex.EncodeToString(btcutil.Hash160(pubKey.SerializeUncompressed())) + "88ac", want: true},
+		{name: "uncompressed P2PK", scriptPubKey: "41" + hex.EncodeToString(pubKey.SerializeUncompressed()) + "ac", want: true},
+		{name: "P2WPKH of sender", scriptPubKey: "0014" + senderPkh, want: true},
+	}
+	pkh, _ := hex.DecodeString(senderPkh)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := needsSenderScript([]btcjson.ListUnspentResult{{ScriptPubKey: tt.scriptPubKey}}, pkh)
+			utils.HandleFatalError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestContractAddress(t *testing.T) {
+	txid, err := chainhash.NewHashFromStr("bbe399eebaf12849cb306af8218460061223baa8cb76216358dd68429c921500")
+	utils.HandleFatalError(t, err)
+
+	assert.Equal(t, "dfc0611a9bba70e2f469bd1e7e20cbc810111e29", ContractAddress(txid, 0))
+	assert.Equal(t, "9801de0d10827f6a5fcd06b4db917f5830f1829c", ContractAddress(txid, 1))
+}
+
+func TestScriptNum(t *testing.T) {
+	assert.Equal(t, []byte(nil), scriptNum(0))
+	assert.Equal(t, []byte{0x28}, scriptNum(40))
+	assert.Equal(t, []byte{0x80, 0x00}, scriptNum(128))
+	assert.Equal(t, []byte{0x90, 0xd0, 0x03}, scriptNum(250000))
+}
+
+func TestBuildUnsignedContractTx(t *testing.T) {
+	listUnspent := []btcjson.ListUnspentResult{}
+	err := json.Unmarshal([]byte(listUnspentResponseJSON), &listUnspent)
+	utils.HandleFatalError(t, err)
+
+	qcli := newTestQtumClient(t)
+
+	bytecode, _ := hex.DecodeString(CONTRACT_BYTECODE)
+	contract := &qtypes.ContractParams{
+		GasLimit: 2500000,
+		GasPrice: 40,
+		Data:     bytecode,
+	}
+
+	tests := []struct {
+		name       string
+		contract   *qtypes.ContractParams
+		amount     float64
+		wantChange float64 // change before paying the tx fee
+		wantErr    bool
+	}{
+		{
+			name:       "create contract without value",
+			contract:   contract,
+			amount:     0,
+			wantChange: 20000 - contract.GasFee(),
+		},
+		{
+			name:       "create contract with value",
+			contract:   contract,
+			amount:     10,
+			wantChange: 19990 - contract.GasFee(),
+		},
+		{
+			name: "call contract with value",
+			contract: &qtypes.ContractParams{
+				GasLimit: 250000,
+				GasPrice: 40,
+				Data:     []byte{0xa9, 0x05, 0x9c, 0xbb},
+				Address:  "71517f86711b4bff4d789ad6fee9a58d8af1c6bb",
+			},
+			amount:     10,
+			wantChange: 19990 - 0.1,
+		},
+		{
+			name:     "gas limit too low",
+			contract: &qtypes.ContractParams{GasLimit: 100, GasPrice: 40, Data: bytecode},
+			wantErr:  true,
+		},
+		{
+			name:     "gas price too low",
+			contract: &qtypes.ContractParams{GasLimit: 2500000, GasPrice: 1, Data: bytecode},
+			wantErr:  true,
+		},
+		{
+			name:     "gas limit above the block gas limit",
+			contract: &qtypes.ContractParams{GasLimit: math.MaxUint64, GasPrice: 40, Data: bytecode},
+			wantErr:  true,
+		},
+		{
+			name:     "gas fee overflow",
+			contract: &qtypes.ContractParams{GasLimit: qtypes.MaxGasLimit, GasPrice: math.MaxUint64 / 2, Data: bytecode},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, _, err := qcli.BuildUnsignedContractTx(listUnspent[0:1], SENDER_ADDR, SENDER_ADDR, tt.contract, tt.amount)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			utils.HandleFatalError(t, err)
+
+			assert.Equal(t, 1, len(tx.TxIn))
+			assert.Equal(t, 2, len(tx.TxOut))
+
+			// check the contract output
+			contractOut := tx.TxOut[0]
+			wantOpcode := byte(txscript.OP_CALL)
+			if tt.contract.IsCreate() {
+				wantOpcode = txscript.OP_CREATE
+			}
+			assert.Equal(t, wantOpcode, contractOut.PkScript[len(contractOut.PkScript)-1])
+			_, _, hasSender := parseSenderScript(contractOut.PkScript)
+			assert.False(t, hasSender)
+			assert.Equal(t, int64(tt.amount*Qtum), contractOut.Value)
+
+			// check the change output is paid to the sender
+			script, err := txscript.ParsePkScript(tx.TxOut[1].PkScript)
+			utils.HandleFatalError(t, err)
+			changeAddr, err := script.Address(cfg)
+			utils.HandleFatalError(t, err)
+			assert.Equal(t, SENDER_ADDR, changeAddr.String())
+			size, err := estimateSignedSize(tx, listUnspent[0:1])
+			utils.HandleFatalError(t, err)
+			wantChange := tt.wantChange - calculateFee(size, testFeeRate).ToBTC()
+			changeQtum := float64(tx.TxOut[1].Value) / Qtum
+			if !utils.AreEqual(changeQtum, wantChange) {
+				t.Errorf("change amount is not correct, want %v, got %v", wantChange, changeQtum)
+			}
+		})
+	}
+}
+
+func TestSignSenderOutputs(t *testing.T) {
+	listUnspent := []btcjson.ListUnspentResult{}
+	err := json.Unmarshal([]byte(listUnspentResponseJSON), &listUnspent)
+	utils.HandleFatalError(t, err)
+
+	// spend a P2WPKH output of the sender, which requires OP_SENDER
+	unspent := listUnspent[0:1]
+	unspent[0].ScriptPubKey = "00147926223070547d2d15b2ef5e7383e541c338ffe9"
+
+	qcli := newTestQtumClient(t)
+	contract := &qtypes.ContractParams{
+		GasLimit: 250000,
+		GasPrice: 40,
+		Data:     []byte{0xa9, 0x05, 0x9c, 0xbb},
+		Address:  "71517f86711b4bff4d789ad6fee9a58d8af1c6bb",
+	}
+	tx, _, err := qcli.BuildUnsignedContractTx(unspent, SENDER_ADDR, SENDER_ADDR, contract, 0)
+	utils.HandleFatalError(t, err)
+
+	senderPkh, _, ok := parseSenderScript(tx.TxOut[0].PkScript)
+	assert.True(t, ok)
+	assert.Equal(t, "7926223070547d2d15b2ef5e7383e541c338ffe9", hex.EncodeToString(senderPkh))
+
+	w, err := wallet.NewQtumWallet(SENDER_PRIVKEY, cfg)
+	utils.HandleFatalError(t, err)
+	err = signSenderOutputs(tx, w, cfg)
+	utils.HandleFatalError(t, err)
+
+	// the sender scriptSig is <signature> <pubkey>
+	pushes, err := txscript.PushedData(tx.TxOut[0].PkScript)
+	utils.HandleFatalError(t, err)
+	sigScript, err := txscript.PushedData(pushes[1])
+	utils.HandleFatalError(t, err)
+	privKeyBytes, _ := hex.DecodeString(SENDER_PRIVKEY)
+	_, pubKey := btcec.PrivKeyFromBytes(privKeyBytes)
+	assert.Equal(t, pubKey.SerializeCompressed(), sigScript[1])
+
+	// the signature commits to the tx without sender signatures
+	signature, err := ecdsa.ParseDERSignature(sigScript[0][:len(sigScript[0])-1])
+	utils.HandleFatalError(t, err)
+	sigHash, err := calcSenderSigHash(tx, 0, senderPkh, txscript.SigHashAll)
+	utils.HandleFatalError(t, err)
+	assert.True(t, signature.Verify(sigHash, pubKey))
+}
+
+func TestCalcSenderSigHash(t *testing.T) {
+	// unsigned tx of the native P2WPKH example of BIP143, whose hashPrevouts,
+	// hashSequence and hashOutputs are published in the BIP
+	rawTx, _ := hex.DecodeString("0100000002fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f0000000000eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac11000000")
+	tx := wire.NewMsgTx(wire.TxVersion)
+	err := tx.Deserialize(bytes.NewReader(rawTx))
+	utils.HandleFatalError(t, err)
+	senderPkh, _ := hex.DecodeString("1d0f172a0ecb48aee1be1f2687d2963ae33f71a1")
+
+	// qtumd SignatureHashOutput preimage of output 1: the BIP143 preimage with the
+	// index and value of the output in place of the outpoint and value of an input
+	preimage, _ := hex.DecodeString("01000000" + // version
+		"96b827c8483d4e9b96712b6713a7b68d6e8003a781feba36c31143470b4efd37" + // hashPrevouts
+		"52b0a642eea2fb7ae638c36f6252b6750293dbe574a806984b8e4d8548339a3b" + // hashSequence
+		"01000000" + // output index
+		"1976a9141d0f172a0ecb48aee1be1f2687d2963ae33f71a188ac" + // P2PKH script code of the sender
+		"9093510d00000000" + // output value
+		"863ef3e1a92afbfdb97f31ad0fc7683ee943e9abcf2501590ff8f6551f47e5e5" + // hashOutputs
+		"11000000" + // locktime
+		"01000000") // SIGHASH_ALL
+	sigHash, err := calcSenderSigHash(tx, 1, senderPkh, txscript.SigHashAll)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, chainhash.DoubleHashB(preimage), sigHash)
+
+	// the outputs are committed to without their sender signatures
+	contractScript, err := BuildCallScript(&qtypes.ContractParams{
+		GasLimit: 250000,
+		GasPrice: 40,
+		Address:  "71517f86711b4bff4d789ad6fee9a58d8af1c6bb",
+	})
+	utils.HandleFatalError(t, err)
+	tx.TxOut[0].PkScript, err = buildSenderScript(senderPkh, nil, contractScript)
+	utils.HandleFatalError(t, err)
+	unsigned, err := calcSenderSigHash(tx, 0, senderPkh, txscript.SigHashAll)
+	utils.HandleFatalError(t, err)
+	tx.TxOut[0].PkScript, err = buildSenderScript(senderPkh, []byte{0x01, 0x02}, contractScript)
+	utils.HandleFatalError(t, err)
+	signed, err := calcSenderSigHash(tx, 0, senderPkh, txscript.SigHashAll)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, unsigned, signed)
+	assert.NotEqual(t, sigHash, unsigned)
+}
+
+func TestCallContract(t *testing.T) {
+	const callcontractJSON = `{
+		"address": "71517f86711b4bff4d789ad6fee9a58d8af1c6bb",
+		"executionResult": {
+			"gasUsed": 21664,
+			"excepted": "None",
+			"newAddress": "71517f86711b4bff4d789ad6fee9a58d8af1c6bb",
+			"output": "0000000000000000000000000000000000000000000000000000000000000064",
+			"codeDeposit": 0,
+			"gasRefunded": 0,
+			"depositSize": 0,
+			"gasForDeposit": 0,
+			"exceptedMessage": ""
+		},
+		"transactionReceipt": {
+			"stateRoot": "2f8a1d6ff0a2a34b4d1a4b4bd5dd0bd4e8ff1b0b5d6c1ac1c0b1e2f6c2c1e0d1",
+			"gasUsed": 21664,
+			"bloom": "00",
+			"log": []
+		}
+	}`
+
+	mockQtumd := mocks.NewMockQtumd(map[string]string{"callcontract": callcontractJSON})
+	defer mockQtumd.Close()
+	qcli, err := NewQtumClient(mockQtumd.URL, "qtum", "qtumpass", cfg.Net.String())
+	utils.HandleFatalError(t, err)
+
+	result, err := qcli.CallContract("71517f86711b4bff4d789ad6fee9a58d8af1c6bb", "70a08231", SENDER_ADDR, 0, 0)
+	utils.HandleFatalError(t, err)
+	assert.False(t, result.ExecutionResult.Failed())
+	assert.Equal(t, uint64(21664), result.ExecutionResult.GasUsed)
+	assert.Equal(t, "0000000000000000000000000000000000000000000000000000000000000064", result.ExecutionResult.Output)
+}
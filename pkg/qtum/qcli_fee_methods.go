@@ -0,0 +1,247 @@
+package qtum
+
+import (
+	"encoding/hex"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/qtumproject/btcd/txscript"
+	"github.com/qtumproject/btcd/wire"
+)
+
+const (
+	// DefaultMinFeeRate is the default minimum fee rate in satoshis per kB,
+	// which matches the qtum minimum relay fee
+	DefaultMinFeeRate = btcutil.Amount(400000)
+	// DefaultMaxFeeRate is the default maximum fee rate in satoshis per kB
+	DefaultMaxFeeRate = btcutil.Amount(10000000)
+	// DefaultFallbackFeeRate is the default fee rate in satoshis per kB used
+	// when the node can not estimate a fee rate
+	DefaultFallbackFeeRate = btcutil.Amount(900000)
+	// DefaultFeeConfTarget is the default number of blocks within which
+	// the transactions are expected to be confirmed
+	DefaultFeeConfTarget = 6
+
+	// p2pkhSigScriptSize is the maximum size of the scriptSig spending a P2PKH output:
+	// <push> <DER signature + hashtype (73 bytes)> <push> <compressed pubkey (33 bytes)>
+	p2pkhSigScriptSize = 1 + 73 + 1 + 33
+	// p2pkSigScriptSize is the maximum size of the scriptSig spending a P2PK output:
+	// <push> <DER signature + hashtype (73 bytes)>
+	p2pkSigScriptSize = 1 + 73
+	// p2shP2wpkhSigScriptSize is the size of the scriptSig spending a P2SH-P2WPKH
+	// output: <push> <P2WPKH redeem script (22 bytes)>
+	p2shP2wpkhSigScriptSize = 1 + 22
+	// p2wpkhWitnessSize is the maximum size of the witness spending a P2WPKH output:
+	// <item count> <len> <DER signature + hashtype (73 bytes)> <len> <compressed pubkey (33 bytes)>
+	p2wpkhWitnessSize = 1 + 1 + 73 + 1 + 33
+	// witnessFlagSize is the size of the segwit marker and flag
+	// of the transactions with witnesses
+	witnessFlagSize = 2
+	// witnessScaleFactor is the weight of the non-witness bytes
+	// relative to the witness bytes (BIP141)
+	witnessScaleFactor = 4
+	// inputBaseSize is the size of an input without its scriptSig:
+	// outpoint (36 bytes), scriptSig length (1 byte) and sequence (4 bytes)
+	inputBaseSize = 36 + 1 + 4
+	// p2pkhInputSize is the size of an input spending a P2PKH output
+	p2pkhInputSize = inputBaseSize + p2pkhSigScriptSize
+	// changeOutputSize is the size of a P2PKH change output:
+	// value (8 bytes), script length (1 byte) and script (25 bytes)
+	changeOutputSize = 8 + 1 + 25
+	// txOverheadSize is the size of a transaction without inputs and outputs:
+	// version (4 bytes), input and output counts (1 byte each) and locktime (4 bytes)
+	txOverheadSize = 4 + 1 + 1 + 4
+
+	// TransferTxSize is the size of a signed transaction spending a P2PKH
+	// output to a P2PKH receiver output and a change output
+	TransferTxSize = txOverheadSize + p2pkhInputSize + 2*changeOutputSize
+)
+
+// FeeConfig holds the values used to calculate the fee of the transactions
+type FeeConfig struct {
+	// MinFeeRate is the lowest fee rate (satoshis per kB) paid by a transaction
+	MinFeeRate btcutil.Amount
+	// MaxFeeRate is the highest fee rate (satoshis per kB) paid by a transaction
+	MaxFeeRate btcutil.Amount
+	// FallbackFeeRate is the fee rate (satoshis per kB) used when the node returns no estimate
+	FallbackFeeRate btcutil.Amount
+	// ConfTarget is the confirmation target (in blocks) passed to estimatesmartfee
+	ConfTarget int64
+}
+
+// DefaultFeeConfig returns the fee configuration used when none is set
+func DefaultFeeConfig() FeeConfig {
+	return FeeConfig{
+		MinFeeRate:      DefaultMinFeeRate,
+		MaxFeeRate:      DefaultMaxFeeRate,
+		FallbackFeeRate: DefaultFallbackFeeRate,
+		ConfTarget:      DefaultFeeConfTarget,
+	}
+}
+
+// Validate checks the fee rate bounds are consistent
+func (c FeeConfig) Validate() error {
+	if c.MinFeeRate <= 0 {
+		return errors.Errorf("min fee rate must be positive: %v", c.MinFeeRate)
+	}
+	if c.MaxFeeRate < c.MinFeeRate {
+		return errors.Errorf("max fee rate %v is lower than min fee rate %v", c.MaxFeeRate, c.MinFeeRate)
+	}
+	if c.FallbackFeeRate < c.MinFeeRate || c.FallbackFeeRate > c.MaxFeeRate {
+		return errors.Errorf("fallback fee rate %v is out of the range [%v, %v]", c.FallbackFeeRate, c.MinFeeRate, c.MaxFeeRate)
+	}
+	if c.ConfTarget <= 0 {
+		return errors.Errorf("fee confirmation target must be positive: %d", c.ConfTarget)
+	}
+	return nil
+}
+
+// SetFeeConfig sets the configuration used to calculate the fee of the transactions
+func (q *QtumClient) SetFeeConfig(feeCfg FeeConfig) error {
+	err := feeCfg.Validate()
+	if err != nil {
+		return errors.Wrap(err, "Invalid fee config")
+	}
+	q.feeCfg = feeCfg
+	return nil
+}
+
+// EstimateFeeRate returns the fee rate in satoshis per kB to pay for a transaction,
+// as estimated by the node's estimatesmartfee and bounded by the configured min and max
+// fee rates. The fallback fee rate is returned if the node has no estimate
+func (q *QtumClient) EstimateFeeRate() (btcutil.Amount, error) {
+	estimate, err := q.EstimateSmartFee(q.feeCfg.ConfTarget, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "Error estimating fee rate")
+	}
+	if estimate.FeeRate == nil || *estimate.FeeRate <= 0 {
+		log.With("module", "qtum").Debugf("No fee rate estimate (errors: %v), using fallback fee rate: %v", estimate.Errors, q.feeCfg.FallbackFeeRate)
+		return q.feeCfg.FallbackFeeRate, nil
+	}
+	feeRate, err := btcutil.NewAmount(*estimate.FeeRate)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error converting fee rate %v", *estimate.FeeRate)
+	}
+	switch {
+	case feeRate < q.feeCfg.MinFeeRate:
+		feeRate = q.feeCfg.MinFeeRate
+	case feeRate > q.feeCfg.MaxFeeRate:
+		feeRate = q.feeCfg.MaxFeeRate
+	}
+	log.With("module", "qtum").Tracef("Estimated fee rate: %v per kB (node estimate: %v)", feeRate, *estimate.FeeRate)
+	return feeRate, nil
+}
+
+// GetDGPInfo returns the blockchain parameters set by the
+// Decentralized Governance Protocol (i.e. the node's getdgpinfo RPC)
+func (q *QtumClient) GetDGPInfo() (*qtypes.DGPInfo, error) {
+	var info qtypes.DGPInfo
+	err := q.rawRequest("getdgpinfo", nil, &info)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error getting DGP info")
+	}
+	log.With("module", "qtum").Tracef("getdgpinfo result: %+v", info)
+	return &info, nil
+}
+
+// calculateFee returns the fee to pay for a transaction of the given size at the given fee rate
+func calculateFee(size int, feeRate btcutil.Amount) btcutil.Amount {
+	return feeRate * btcutil.Amount(size) / 1000
+}
+
+// CalculateTxFee returns the fee paid by the transaction, i.e. the value of the
+// outputs it spends minus the value of its outputs. The spent outputs are
+// looked up in the given list of unspent outputs
+func CalculateTxFee(tx *wire.MsgTx, unspent []btcjson.ListUnspentResult) (btcutil.Amount, error) {
+	var fee btcutil.Amount
+	for _, txIn := range tx.TxIn {
+		utxo, err := findUnspent(unspent, &txIn.PreviousOutPoint)
+		if err != nil {
+			return 0, err
+		}
+		value, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			return 0, errors.Wrapf(err, "Error converting amount of utxo %s:%d", utxo.TxID, utxo.Vout)
+		}
+		fee += value
+	}
+	for _, txOut := range tx.TxOut {
+		fee -= btcutil.Amount(txOut.Value)
+	}
+	return fee, nil
+}
+
+// estimateSignedSize returns the virtual size in bytes the unsigned transaction will have
+// once signed, adding the size of the scriptSigs and witnesses of the inputs spending the
+// given unspent outputs and of the sender signatures of the contract outputs. Witness
+// bytes count as a quarter of a byte (BIP141), so without witnesses the virtual size
+// is the size of the transaction
+func estimateSignedSize(tx *wire.MsgTx, unspent []btcjson.ListUnspentResult) (int, error) {
+	size := tx.SerializeSizeStripped()
+	// the inputs without witness have an empty witness (item count 0) if any input has one
+	witnessSize := 0
+	emptyWitnesses := 0
+	for _, txIn := range tx.TxIn {
+		utxo, err := findUnspent(unspent, &txIn.PreviousOutPoint)
+		if err != nil {
+			return 0, err
+		}
+		sigScriptSize, inputWitnessSize, err := estimateInputScriptsSize(utxo.ScriptPubKey)
+		if err != nil {
+			return 0, err
+		}
+		size += sigScriptSize
+		witnessSize += inputWitnessSize
+		if inputWitnessSize == 0 {
+			emptyWitnesses++
+		}
+	}
+	for _, txOut := range tx.TxOut {
+		if _, _, ok := parseSenderScript(txOut.PkScript); ok {
+			// the sender scriptSig and the growth of the script length varint
+			size += p2pkhSigScriptSize + 2
+		}
+	}
+	if witnessSize > 0 {
+		size += witnessVirtualSize(witnessFlagSize + witnessSize + emptyWitnesses)
+	}
+	return size, nil
+}
+
+// estimateInputVirtualSize returns the maximum virtual size of the
+// input spending an output with the given hex encoded scriptPubKey
+func estimateInputVirtualSize(scriptPubKeyHex string) (int, error) {
+	sigScriptSize, witnessSize, err := estimateInputScriptsSize(scriptPubKeyHex)
+	if err != nil {
+		return 0, err
+	}
+	return inputBaseSize + sigScriptSize + witnessVirtualSize(witnessSize), nil
+}
+
+// estimateInputScriptsSize returns the maximum size of the scriptSig and of the
+// witness spending an output with the given hex encoded scriptPubKey. P2SH outputs
+// are assumed to be P2SH-P2WPKH, the only P2SH outputs that are signed
+func estimateInputScriptsSize(scriptPubKeyHex string) (sigScriptSize int, witnessSize int, err error) {
+	scriptPubKey, err := hex.DecodeString(scriptPubKeyHex)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "Error decoding scriptPubKey: %s", scriptPubKeyHex)
+	}
+	switch txscript.GetScriptClass(scriptPubKey) {
+	case txscript.PubKeyTy:
+		return p2pkSigScriptSize, 0, nil
+	case txscript.WitnessV0PubKeyHashTy:
+		return 0, p2wpkhWitnessSize, nil
+	case txscript.ScriptHashTy:
+		return p2shP2wpkhSigScriptSize, p2wpkhWitnessSize, nil
+	default:
+		return p2pkhSigScriptSize, 0, nil
+	}
+}
+
+// witnessVirtualSize returns the virtual size of the given witness bytes
+func witnessVirtualSize(witnessSize int) int {
+	return (witnessSize + witnessScaleFactor - 1) / witnessScaleFactor
+}
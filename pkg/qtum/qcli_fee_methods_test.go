@@ -0,0 +1,134 @@
+package qtum
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock response from qtumd estimatesmartfee
+const estimateSmartFeeResponseJSON = `{
+		"feerate": 0.00400000,
+		"blocks": 6
+	}`
+
+// testFeeRate is the fee rate (satoshis per kB) returned by estimateSmartFeeResponseJSON
+const testFeeRate = btcutil.Amount(400000)
+
+// newTestQtumClient returns a qtum client connected to a mock qtumd
+// that answers estimatesmartfee with estimateSmartFeeResponseJSON
+func newTestQtumClient(t *testing.T) *QtumClient {
+	mockQtumd := mocks.NewMockQtumd(map[string]string{"estimatesmartfee": estimateSmartFeeResponseJSON})
+	t.Cleanup(mockQtumd.Close)
+	qcli, err := NewQtumClient(mockQtumd.URL, "qtum", "qtumpass", cfg.Net.String())
+	utils.HandleFatalError(t, err)
+	return qcli
+}
+
+func TestEstimateFeeRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     btcutil.Amount
+	}{
+		{
+			name:     "estimate within bounds",
+			response: `{"feerate": 0.01, "blocks": 6}`,
+			want:     1000000,
+		},
+		{
+			name:     "estimate below min fee rate",
+			response: `{"feerate": 0.001, "blocks": 6}`,
+			want:     DefaultMinFeeRate,
+		},
+		{
+			name:     "estimate above max fee rate",
+			response: `{"feerate": 1.5, "blocks": 6}`,
+			want:     DefaultMaxFeeRate,
+		},
+		{
+			name:     "no estimate",
+			response: `{"errors": ["Insufficient data or no feerate found"], "blocks": 0}`,
+			want:     DefaultFallbackFeeRate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQtumd := mocks.NewMockQtumd(map[string]string{"estimatesmartfee": tt.response})
+			defer mockQtumd.Close()
+			qcli, err := NewQtumClient(mockQtumd.URL, "qtum", "qtumpass", cfg.Net.String())
+			utils.HandleFatalError(t, err)
+
+			got, err := qcli.EstimateFeeRate()
+			utils.HandleFatalError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetFeeConfig(t *testing.T) {
+	qcli := newTestQtumClient(t)
+
+	invalid := DefaultFeeConfig()
+	invalid.MaxFeeRate = invalid.MinFeeRate - 1
+	assert.Error(t, qcli.SetFeeConfig(invalid))
+
+	invalid = DefaultFeeConfig()
+	invalid.FallbackFeeRate = invalid.MaxFeeRate + 1
+	assert.Error(t, qcli.SetFeeConfig(invalid))
+
+	// the node estimate is raised to the configured min fee rate
+	feeCfg := DefaultFeeConfig()
+	feeCfg.MinFeeRate = 500000
+	assert.NoError(t, qcli.SetFeeConfig(feeCfg))
+	got, err := qcli.EstimateFeeRate()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, btcutil.Amount(500000), got)
+}
+
+func TestEstimateSignedSize(t *testing.T) {
+	listUnspent := []btcjson.ListUnspentResult{}
+	err := json.Unmarshal([]byte(listUnspentResponseJSON), &listUnspent)
+	utils.HandleFatalError(t, err)
+	inputs := listUnspent[0:2]
+
+	qcli := newTestQtumClient(t)
+	tx, _, err := qcli.BuildUnsignedQtumTx(inputs, SENDER_ADDR, RECEIVER_ADDR, 10000.1)
+	utils.HandleFatalError(t, err)
+	size, err := estimateSignedSize(tx, inputs)
+	utils.HandleFatalError(t, err)
+
+	w, err := wallet.NewQtumWallet(SENDER_PRIVKEY, cfg)
+	utils.HandleFatalError(t, err)
+	err = qcli.SignRawTX(tx, inputs, w)
+	utils.HandleFatalError(t, err)
+
+	// signatures are 71 or 72 bytes long, so the estimate is an upper bound
+	assert.GreaterOrEqual(t, size, tx.SerializeSize())
+	assert.LessOrEqual(t, size-tx.SerializeSize(), 2*len(inputs))
+}
+
+func TestCalculateTxFee(t *testing.T) {
+	listUnspent := []btcjson.ListUnspentResult{}
+	err := json.Unmarshal([]byte(listUnspentResponseJSON), &listUnspent)
+	utils.HandleFatalError(t, err)
+
+	qcli := newTestQtumClient(t)
+	tx, _, err := qcli.BuildUnsignedQtumTx(listUnspent, SENDER_ADDR, RECEIVER_ADDR, 10000.1)
+	utils.HandleFatalError(t, err)
+
+	fee, err := CalculateTxFee(tx, listUnspent)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, calculateFee(227, testFeeRate), fee)
+
+	// the outputs spent by the tx must be in the list
+	_, err = CalculateTxFee(tx, listUnspent[2:])
+	assert.Error(t, err)
+}
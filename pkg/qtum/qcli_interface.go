@@ -1,6 +1,7 @@
 package qtum
 
 import (
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
 	"github.com/alejoacosta74/qproxy/pkg/wallet"
 	// "github.com/btcsuite/btcutil"
 	"github.com/qtumproject/btcd/btcjson"
@@ -18,15 +19,25 @@ type Iqcli interface {
 	// addressed to provided address.
 	ImportAddressRescan(address string, account string, rescan bool) error
 
-	// FindSpendableUTXO returns a list of spendable UTXOs for the given address
-	FindSpendableUTXO(address string) ([]btcjson.ListUnspentResult, error)
+	// FindSpendableUTXO returns a list of spendable UTXOs for the given addresses
+	FindSpendableUTXO(addresses ...string) ([]btcjson.ListUnspentResult, error)
 
 	// GetAddressInfo returns information about the given qtum address.
 	GetAddressInfo(address string) (*btcjson.GetAddressInfoResult, error)
 
-	// BuildUnsignedQtumTx creates a qtum/btc raw transaction using the given unspent outputs
-	// to create inputs, and creates resulting outputs
-	BuildUnsignedQtumTx(unspent []btcjson.ListUnspentResult, sender, receiver string, amount float64) (*wire.MsgTx, error)
+	// BuildUnsignedQtumTx creates a qtum/btc raw transaction selecting its inputs among
+	// the given unspent outputs, and creates resulting outputs. It also returns the change
+	// paid as fee because it was too low for a change output
+	BuildUnsignedQtumTx(unspent []btcjson.ListUnspentResult, change, receiver string, amount float64) (*wire.MsgTx, btcutil.Amount, error)
+
+	// BuildUnsignedContractTx creates a qtum raw transaction with a contract output
+	// built from the given contract params, selecting its inputs among the given unspent outputs.
+	// It also returns the change paid as fee because it was too low for a change output
+	BuildUnsignedContractTx(unspent []btcjson.ListUnspentResult, sender, change string, contract *qtypes.ContractParams, amount float64) (*wire.MsgTx, btcutil.Amount, error)
+
+	// BuildConsolidationTx creates a qtum raw transaction merging the smallest of the
+	// given unspent outputs into a single output paid to the address
+	BuildConsolidationTx(unspent []btcjson.ListUnspentResult, address string, maxInputs int) (*wire.MsgTx, error)
 
 	// SendRawTransaction submits the encoded transaction to the server
 	// which will then relay it to the network.
@@ -38,16 +49,58 @@ type Iqcli interface {
 	// The transaction is not sent to the network.
 	SignRawTX(tx *wire.MsgTx, unspent []btcjson.ListUnspentResult, wallet wallet.IQtumWallet) error
 
+	// CallContract executes a contract call on the node without creating a transaction
+	// and returns the execution result.
+	CallContract(address, data, sender string, gasLimit uint64, amount float64) (*qtypes.CallContractResult, error)
+
+	// GetTransactionReceipt returns the receipts of the contract outputs of the transaction
+	GetTransactionReceipt(txid string) ([]qtypes.TransactionReceipt, error)
+
+	// SearchLogs returns the receipts of the contract executions in the given block
+	// range with logs emitted by the given addresses and matching the given topics
+	SearchLogs(fromBlock, toBlock int64, addresses []string, topics []*string) ([]qtypes.TransactionReceipt, error)
+
+	// GetRawTransactionVerbose returns a transaction given its hash, along with the
+	// block it was included in, if any.
+	GetRawTransactionVerbose(txHash *chainhash.Hash) (*btcjson.TxRawResult, error)
+
+	// GetBlockVerbose returns a data structure from the server with information
+	// about a block given its hash.
+	GetBlockVerbose(blockHash *chainhash.Hash) (*btcjson.GetBlockVerboseResult, error)
+
+	// GetBlockHash returns the hash of the block in the best block chain at the given height.
+	GetBlockHash(blockHeight int64) (*chainhash.Hash, error)
+
+	// GetTransactionIndex seeks a tx hash within the block and returns the index of the transaction
+	GetTransactionIndex(txHash string, block *btcjson.GetBlockVerboseResult) (int, error)
+
 	// DecodeRawTransaction returns information about a transaction given its serialized bytes.
 	DecodeRawTransaction(serializedTx []byte) (*btcjson.TxRawResult, error)
 
 	// EstimateFee provides an estimated fee in bitcoins per kilobyte.
 	EstimateSmartFee(confTarget int64, mode *btcjson.EstimateSmartFeeMode) (*btcjson.EstimateSmartFeeResult, error)
 
+	// EstimateFeeRate returns the fee rate in satoshis per kB to pay for a transaction
+	EstimateFeeRate() (btcutil.Amount, error)
+
+	// GetDGPInfo returns the blockchain parameters set by the Decentralized Governance Protocol
+	GetDGPInfo() (*qtypes.DGPInfo, error)
+
+	// GetBlockCount returns the number of blocks in the longest block chain.
+	GetBlockCount() (int64, error)
+
+	// GetRawMempool returns the hashes of all transactions in the memory pool.
+	GetRawMempool() ([]*chainhash.Hash, error)
+
 	// VerifyAddress checks if the address is known to the node's wallet.
 	// If not, it will import the address and rescan the blockchain seeking transactions
 	// related to this address.
 	VerifyAddress(address string) error
 
+	// VerifyWallet checks every address of the wallet key is known to the node's wallet.
+	// If not, it will import the public keys of the wallet, so its P2PK outputs are also
+	// tracked, and rescan the blockchain seeking transactions related to them.
+	VerifyWallet(w wallet.IQtumWallet) error
+
 	GetBalance(account string) (btcutil.Amount, error)
 }
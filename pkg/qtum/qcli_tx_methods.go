@@ -1,11 +1,15 @@
 package qtum
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
+	"sort"
 
 	"github.com/alejoacosta74/qproxy/pkg/log"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
 	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/pkg/errors"
 	"github.com/qtumproject/btcd/btcjson"
 	"github.com/qtumproject/btcd/btcutil"
@@ -16,12 +20,13 @@ import (
 )
 
 const (
-	// DefaultGasPrice is the default gas price used for transactions
-	DefaultGasPrice = 100000
 	// Qtum is the number of satoshis in 1 Qtum
 	Qtum = 100000000
 	// Precision digits to use for decimal operations with Qtum amounts
 	PrecisionExp = -8
+	// DefaultDustThreshold is the default lowest value in satoshis of a change
+	// output, which matches the qtum dust limit of a P2PKH output
+	DefaultDustThreshold = btcutil.Amount(72800)
 )
 
 // findCoinStake returns the transaction hash of the coinstake transaction in the block
@@ -84,128 +89,375 @@ func (q *QtumClient) GetTransactionIndex(txHash string, block *btcjson.GetBlockV
 	return 0, fmt.Errorf("tx not found in block")
 }
 
-// FindSpendableUTXO returns a list of spendable UTXOs for the given address
+// FindSpendableUTXO returns a list of spendable UTXOs for the given addresses
 //
 // Params:
-//   - addr: the address to search for UTXOs in base58 format
-func (q *QtumClient) FindSpendableUTXO(addr string) ([]btcjson.ListUnspentResult, error) {
+//   - addrs: the addresses to search for UTXOs in base58 (or bech32) format
+func (q *QtumClient) FindSpendableUTXO(addrs ...string) ([]btcjson.ListUnspentResult, error) {
 
-	log.With("module", "qtum").Tracef("Searching unspent utxos for address %s: ", addr)
-	address, err := btcutil.DecodeAddress(addr, q.cfg)
-	if err != nil {
-		log.With("module", "qtum").Tracef("Error decoding address: %s, error: %+v", addr, err)
-		return nil, errors.Wrapf(err, "Error decoding address: %s", addr)
+	log.With("module", "qtum").Tracef("Searching unspent utxos for addresses %v: ", addrs)
+	addresses := make([]btcutil.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		address, err := btcutil.DecodeAddress(addr, q.cfg)
+		if err != nil {
+			log.With("module", "qtum").Tracef("Error decoding address: %s, error: %+v", addr, err)
+			return nil, errors.Wrapf(err, "Error decoding address: %s", addr)
+		}
+		addresses = append(addresses, address)
 	}
 
-	addresses := []btcutil.Address{address}
 	unspent, err := q.ListUnspentMinMaxAddresses(0, 9999999, addresses)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Error listing unspent utxos for address: %s", address.EncodeAddress())
+		return nil, errors.Wrapf(err, "Error listing unspent utxos for addresses: %v", addrs)
 	}
 	return unspent, nil
 }
 
+// SetDustThreshold sets the lowest value of a change output. Lower change is paid as fee
+func (q *QtumClient) SetDustThreshold(dust btcutil.Amount) error {
+	if dust < 0 {
+		return errors.Errorf("dust threshold can not be negative: %v", dust)
+	}
+	q.dust = dust
+	return nil
+}
+
 // BuildUnsignedQtumTx creates a qtum/btc raw transaction using the given parameters
-// to create inputs and resulting outputs.
-// Returns a wire.MsgTx ready to be signed and sent to the network
+// to create inputs and resulting outputs. The inputs are selected from the unspent
+// outputs with the client's coin selector.
+// Returns a wire.MsgTx ready to be signed and sent to the network, along with
+// the change paid as fee because it was too low for a change output, if any
 //
 // Params:
-//   - unspent: a list of unspent outputs to use as inputs
-//   - sender: the sender address in base58 format
+//   - unspent: a list of unspent outputs to select the inputs from
+//   - change: the address in base58 format the change is paid to
 //   - receiver: the receiver address in base58 format
 //   - amount: the amount to send in Qtum
-func (q *QtumClient) BuildUnsignedQtumTx(unspent []btcjson.ListUnspentResult, sender, receiver string, amount float64) (*wire.MsgTx, error) {
+func (q *QtumClient) BuildUnsignedQtumTx(unspent []btcjson.ListUnspentResult, change, receiver string, amount float64) (*wire.MsgTx, btcutil.Amount, error) {
 
 	//1. Create new empty transaction
 	tx := wire.NewMsgTx(wire.TxVersion)
 
 	//2. Get addresses and amounts
 	if q.cfg == nil {
-		return nil, errors.New("Network parameters not set in qtum client")
+		return nil, 0, errors.New("Network parameters not set in qtum client")
+	}
+	receiverAddr, err := btcutil.DecodeAddress(receiver, q.cfg)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "Error decoding receiver address: %s", receiver)
+	}
+
+	//3. Create outputs
+
+	// create receiver output
+	receiverAmount, err := btcutil.NewAmount(amount)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "Error converting amount %v", amount)
+	}
+	receiverScript, err := txscript.PayToAddrScript(receiverAddr)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "Error creating receiver script: %s", receiverAddr.EncodeAddress())
+	}
+	log.With("module", "qtum").Tracef("receiverScript: %x", receiverScript)
+	txOut := wire.NewTxOut(int64(receiverAmount), receiverScript)
+	tx.AddTxOut(txOut)
+
+	//4. Select and create inputs
+	selected, err := q.selectCoins(tx, unspent, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = addInputs(tx, selected)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	//5. Create change output
+	dropped, err := q.addChangeOutput(tx, selected, change, amount)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tx, dropped, nil
+}
+
+// BuildUnsignedContractTx creates a qtum raw transaction with a contract output
+// built from the given contract params, followed by the change output.
+// The inputs are selected from the unspent outputs with the client's coin selector.
+// If the first input does not identify the sender (i.e. it is not P2PKH or P2PK),
+// the contract output is prefixed with OP_SENDER.
+// Returns a wire.MsgTx ready to be signed and sent to the network, along with
+// the change paid as fee because it was too low for a change output, if any
+//
+// Params:
+//   - unspent: a list of unspent outputs to select the inputs from
+//   - sender: the sender address in base58 format
+//   - change: the address in base58 format the change is paid to
+//   - contract: the gas values, EVM data and address of the contract output
+//   - amount: the amount in Qtum to send to the contract
+func (q *QtumClient) BuildUnsignedContractTx(unspent []btcjson.ListUnspentResult, sender, change string, contract *qtypes.ContractParams, amount float64) (*wire.MsgTx, btcutil.Amount, error) {
+	if q.cfg == nil {
+		return nil, 0, errors.New("Network parameters not set in qtum client")
+	}
+	err := contract.Validate()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "Invalid contract params")
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+
+	// create contract output
+	contractAmount, err := btcutil.NewAmount(amount)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "Error converting amount %v", amount)
+	}
+	contractScript, err := BuildContractScript(contract)
+	if err != nil {
+		return nil, 0, err
+	}
+	contractOut := wire.NewTxOut(int64(contractAmount), contractScript)
+	tx.AddTxOut(contractOut)
+
+	// select inputs. The gas reserved for the contract execution is paid as part of the tx fee
+	selected, err := q.selectCoins(tx, unspent, contract.GasFee())
+	if err != nil {
+		return nil, 0, err
 	}
 	senderAddr, err := btcutil.DecodeAddress(sender, q.cfg)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Error decoding sender address: %s", sender)
+		return nil, 0, errors.Wrapf(err, "Error decoding sender address: %s", sender)
 	}
-	receiverAddr, err := btcutil.DecodeAddress(receiver, q.cfg)
+	pkhAddr, ok := senderAddr.(*btcutil.AddressPubKeyHash)
+	if !ok {
+		return nil, 0, errors.Errorf("sender address %s is not a pubkeyhash address", sender)
+	}
+	senderRequired, err := needsSenderScript(selected, pkhAddr.Hash160()[:])
 	if err != nil {
-		return nil, errors.Wrapf(err, "Error decoding receiver address: %s", receiver)
+		return nil, 0, err
+	}
+	if senderRequired {
+		// the sender signature is added when the tx is signed
+		contractOut.PkScript, err = buildSenderScript(pkhAddr.Hash160()[:], nil, contractScript)
+		if err != nil {
+			return nil, 0, err
+		}
+		// select again to pay for the bigger contract output
+		selected, err = q.selectCoins(tx, unspent, contract.GasFee())
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	log.With("module", "qtum").Tracef("contractScript: %x", contractOut.PkScript)
+	err = addInputs(tx, selected)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	//3. Create inputs
+	// create change output
+	dropped, err := q.addChangeOutput(tx, selected, change, amount+contract.GasFee())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return tx, dropped, nil
+}
+
+// BuildConsolidationTx creates a qtum raw transaction merging the smallest unspent
+// outputs into a single output paid to the given address, after the fee.
+// Only unspent outputs with the minimum number of confirmations and worth the
+// fee of spending them are merged, and the largest one is left out so the
+// address can keep spending while the consolidation is confirmed.
+// Returns a wire.MsgTx ready to be signed and sent to the network
+//
+// Params:
+//   - unspent: a list of unspent outputs to merge
+//   - address: the address in base58 format the merged output is paid to
+//   - maxInputs: the maximum number of unspent outputs merged
+func (q *QtumClient) BuildConsolidationTx(unspent []btcjson.ListUnspentResult, address string, maxInputs int) (*wire.MsgTx, error) {
+	if q.cfg == nil {
+		return nil, errors.New("Network parameters not set in qtum client")
+	}
+	if maxInputs < 2 {
+		return nil, errors.Errorf("at least 2 inputs are needed to consolidate: %d", maxInputs)
+	}
+	addr, err := btcutil.DecodeAddress(address, q.cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error decoding address: %s", address)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error creating script: %s", addr.EncodeAddress())
+	}
+	var candidates []btcjson.ListUnspentResult
+	for _, utxo := range unspent {
+		if utxo.Confirmations >= q.minConf {
+			candidates = append(candidates, utxo)
+		}
+	}
+	feeRate, err := q.EstimateFeeRate()
+	if err != nil {
+		return nil, err
+	}
+	coins, err := effectiveCoins(candidates, feeRate)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(coins, func(i, j int) bool { return coins[i].effective < coins[j].effective })
+	if len(coins) < 3 {
+		return nil, errors.Wrapf(ErrNothingToConsolidate, "%d unspent outputs worth spending", len(coins))
+	}
+	coins = coins[:len(coins)-1]
+	if len(coins) > maxInputs {
+		coins = coins[:maxInputs]
+	}
+	selected := make([]btcjson.ListUnspentResult, 0, len(coins))
+	for _, c := range coins {
+		selected = append(selected, c.utxo)
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	err = addInputs(tx, selected)
+	if err != nil {
+		return nil, err
+	}
+	txOut := wire.NewTxOut(0, script)
+	tx.AddTxOut(txOut)
+	size, err := estimateSignedSize(tx, selected)
+	if err != nil {
+		return nil, err
+	}
+	fee := calculateFee(size, feeRate)
+	value, err := btcutil.NewAmount(sumUTXO(selected))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error converting amount of unspent outputs")
+	}
+	txOut.Value = int64(value - fee)
+	if btcutil.Amount(txOut.Value) < q.dust {
+		return nil, errors.Wrapf(ErrNothingToConsolidate, "merged output %v is lower than the dust threshold %v", btcutil.Amount(txOut.Value), q.dust)
+	}
+	log.With("module", "qtum").Debugf("Consolidating %d unspent outputs of %v into %v paying fee %v", len(selected), value, btcutil.Amount(txOut.Value), fee)
+	return tx, nil
+}
+
+// addInputs adds an input to the transaction for each of the given unspent outputs
+func addInputs(tx *wire.MsgTx, unspent []btcjson.ListUnspentResult) error {
 	for _, utxo := range unspent {
 		hash, err := chainhash.NewHashFromStr(utxo.TxID)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Error creating chainhash: %s", utxo.TxID)
+			return errors.Wrapf(err, "Error creating chainhash: %s", utxo.TxID)
 		}
 		outPoint := wire.NewOutPoint(hash, utxo.Vout)
 		txIn := wire.NewTxIn(outPoint, nil, nil)
 		tx.AddTxIn(txIn)
 	}
+	return nil
+}
 
-	//4. Create outputs
-
-	// create receiver output
-	receiverAmount, err := btcutil.NewAmount(amount)
+// addChangeOutput adds an output paying the change, if any, to the change address
+// after spending the given amount and the fee from the unspent outputs.
+//
+// The fee is calculated from the estimated size of the signed transaction
+// and the fee rate returned by EstimateFeeRate. If the change left once the fee
+// is paid is lower than the dust threshold or does not cover the cost of creating
+// and spending the change output, the output is not added and the change left
+// without it is returned, as it is paid as fee.
+func (q *QtumClient) addChangeOutput(tx *wire.MsgTx, unspent []btcjson.ListUnspentResult, changeAddress string, amount float64) (btcutil.Amount, error) {
+	changeAddr, err := btcutil.DecodeAddress(changeAddress, q.cfg)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Error converting amount %v", amount)
+		return 0, errors.Wrapf(err, "Error decoding change address: %s", changeAddress)
 	}
-	receiverScript, err := txscript.PayToAddrScript(receiverAddr)
+	changeScript, err := txscript.PayToAddrScript(changeAddr)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Error creating receiver script: %s", receiverAddr.EncodeAddress())
+		return 0, errors.Wrapf(err, "Error creating change script: %s", changeAddr.EncodeAddress())
+	}
+	log.With("module", "qtum").Tracef("changeScript: %x", changeScript)
+	feeRate, err := q.EstimateFeeRate()
+	if err != nil {
+		return 0, err
 	}
-	log.With("module", "qtum").Tracef("receiverScript: %x", receiverScript)
-	txOut := wire.NewTxOut(int64(receiverAmount), receiverScript)
-	tx.AddTxOut(txOut)
-
-	// TODO: research querying of gas fee dynamically
 
-	change := calculateChange(unspent, amount)
-	// create change output
-	if change.GreaterThan(decimal.NewFromFloat(0)) {
+	// estimate the fee with the change output in place
+	changeOut := wire.NewTxOut(0, changeScript)
+	tx.AddTxOut(changeOut)
+	size, err := estimateSignedSize(tx, unspent)
+	if err != nil {
+		return 0, err
+	}
+	fee := calculateFee(size, feeRate)
+	change := calculateChange(unspent, amount, fee)
+	minChange := calculateFee(changeOutputSize+p2pkhInputSize, feeRate)
+	if q.dust > minChange {
+		minChange = q.dust
+	}
+	if change.GreaterThanOrEqual(decimal.NewFromFloatWithExponent(minChange.ToBTC(), PrecisionExp)) {
 		changeF, _ := change.Float64()
 		changeAmount, err := btcutil.NewAmount(changeF)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Error converting change amount %v", change)
-		}
-		senderScript, err := txscript.PayToAddrScript(senderAddr)
-		if err != nil {
-			return nil, errors.Wrapf(err, "Error creating sender script: %s", senderAddr.EncodeAddress())
+			return 0, errors.Wrapf(err, "Error converting change amount %v", change)
 		}
-		log.With("module", "qtum").Tracef("senderScript: %x", senderScript)
-		txOut = wire.NewTxOut(int64(changeAmount), senderScript)
-		tx.AddTxOut(txOut)
+		changeOut.Value = int64(changeAmount)
+		return 0, nil
 	}
 
-	return tx, nil
+	// the change is dust or not worth the cost of creating and spending it:
+	// drop the change output and check the fee can still be paid
+	tx.TxOut = tx.TxOut[:len(tx.TxOut)-1]
+	fee = calculateFee(size-changeOut.SerializeSize(), feeRate)
+	change = calculateChange(unspent, amount, fee)
+	if change.IsNegative() {
+		return 0, errors.Errorf("insufficient funds: %v Qtum needed to pay amount %v and fee %v", decimal.NewFromFloat(amount).Add(decimal.NewFromFloat(fee.ToBTC())), amount, fee)
+	}
+	changeF, _ := change.Float64()
+	dropped, err := btcutil.NewAmount(changeF)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error converting change amount %v", change)
+	}
+	if dropped > 0 {
+		log.With("module", "qtum").Infof("Change %v is lower than %v (dust threshold %v), paying it as fee", dropped, minChange, q.dust)
+	}
+	return dropped, nil
 }
 
 // SignRawTX signs the given raw transaction off-line using the given unspent outputs to create
-// signatures for the inputs.
+// signatures for the inputs. P2PKH and P2PK inputs are signed with a scriptSig, and
+// P2WPKH and P2SH-P2WPKH inputs with a witness (BIP143), which makes the transaction
+// witness serialized.
 //
 // The transaction is not sent to the network.
 //
 // Params:
 //   - tx: the transaction to sign
-//   - unspent: the list of outputs referenced by the inputs to sign
+//   - unspent: a list of outputs including the ones referenced by the inputs to sign
 //   - w: the wallet to use for signing
 func (q *QtumClient) SignRawTX(tx *wire.MsgTx, unspent []btcjson.ListUnspentResult, w wallet.IQtumWallet) error {
 
+	// Sign contract senders, if any
+	err := signSenderOutputs(tx, w, q.cfg)
+	if err != nil {
+		return errors.Wrap(err, "Error signing contract sender")
+	}
+
+	// The witness signatures commit to the amounts of the spent outputs. They are
+	// computed once the outputs are final, i.e. after signing the contract senders
+	var sigHashes *txscript.TxSigHashes
+
 	// Sign inputs
 	for i, txin := range tx.TxIn {
-		//check unspent length
-		if i >= len(unspent) {
-			return errors.New("unspent length is less than txin length")
+		utxo, err := findUnspent(unspent, &txin.PreviousOutPoint)
+		if err != nil {
+			return err
 		}
-		// Take the address from the input and get the private key from the wallet
-		privKey, err := w.GetPrivateKey(unspent[i].Address)
+		scriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
 		if err != nil {
-			return errors.Wrapf(err, "Error getting private key for address: %s", unspent[i].Address)
+			return errors.Wrapf(err, "Error decoding scriptPubKey: %s", utxo.ScriptPubKey)
 		}
-		scriptPubKey, err := hex.DecodeString(unspent[i].ScriptPubKey)
+
+		// Take the address from the input and get its signer from the wallet
+		signer, err := w.GetSigner(utxo.Address)
 		if err != nil {
-			return errors.Wrapf(err, "Error decoding scriptPubKey: %s", unspent[i].ScriptPubKey)
+			return errors.Wrapf(err, "Error getting signer for address: %s", utxo.Address)
 		}
+		pubKey := signer.PublicKey()
 
 		var sigScript []byte
 		// check what type of scriptPubKey it is
@@ -213,35 +465,81 @@ func (q *QtumClient) SignRawTX(tx *wire.MsgTx, unspent []btcjson.ListUnspentResu
 		switch pKscriptType {
 		case txscript.PubKeyHashTy:
 			log.With("module", "qtum").Tracef("scriptPubKey is P2PKH")
-			sigScript, err = txscript.SignatureScript(
-				tx,
-				i,
-				scriptPubKey,
-				txscript.SigHashAll,
-				privKey,
-				true,
-			)
+			// the output pays to the hash of either the compressed or the uncompressed pubkey
+			compress, err := isCompressedP2PKH(scriptPubKey, pubKey)
+			if err != nil {
+				return errors.Wrapf(err, "error signing input type P2PKH with index %d", i)
+			}
+			signature, err := legacySignature(tx, i, scriptPubKey, signer)
 			if err != nil {
 				return errors.Wrapf(err, "error signing input type P2PKH with index %d", i)
 			}
+			serializedPubKey := pubKey.SerializeUncompressed()
+			if compress {
+				serializedPubKey = pubKey.SerializeCompressed()
+			}
+			sigScript, err = txscript.NewScriptBuilder().AddData(signature).AddData(serializedPubKey).Script()
+			if err != nil {
+				return errors.Wrapf(err, "Error creating scriptSig for input %d", i)
+			}
 		case txscript.ScriptHashTy:
-			// create scriptSig for P2SH
+			// only P2SH wrapping the P2WPKH script of the wallet key can be signed
 			log.With("module", "qtum").Tracef("scriptPubKey is P2SH")
-			return errors.New("P2SH is not supported")
+			redeemScript, err := p2wpkhScript(pubKey)
+			if err != nil {
+				return errors.Wrapf(err, "Error creating redeem script for input %d", i)
+			}
+			p2shScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+				AddData(btcutil.Hash160(redeemScript)).AddOp(txscript.OP_EQUAL).Script()
+			if err != nil {
+				return errors.Wrapf(err, "Error creating P2SH-P2WPKH script for input %d", i)
+			}
+			if !bytes.Equal(scriptPubKey, p2shScript) {
+				return errors.Errorf("P2SH is only supported for P2SH-P2WPKH of the wallet key (input %d)", i)
+			}
+			if sigHashes == nil {
+				sigHashes, err = newTxSigHashes(tx, unspent)
+				if err != nil {
+					return err
+				}
+			}
+			txin.Witness, err = witnessSignature(tx, sigHashes, i, utxo, redeemScript, signer)
+			if err != nil {
+				return errors.Wrapf(err, "error signing input type P2SH-P2WPKH with index %d", i)
+			}
+			sigScript, err = txscript.NewScriptBuilder().AddData(redeemScript).Script()
+			if err != nil {
+				return errors.Wrapf(err, "Error creating scriptSig for input %d", i)
+			}
 		case txscript.WitnessV0PubKeyHashTy:
 			log.With("module", "qtum").Tracef("scriptPubKey is P2WPKH")
-			return errors.New("P2WPKH is not supported")
+			walletScript, err := p2wpkhScript(pubKey)
+			if err != nil {
+				return errors.Wrapf(err, "Error creating P2WPKH script for input %d", i)
+			}
+			if !bytes.Equal(scriptPubKey, walletScript) {
+				return errors.Errorf("P2WPKH input %d does not pay to the wallet key", i)
+			}
+			if sigHashes == nil {
+				sigHashes, err = newTxSigHashes(tx, unspent)
+				if err != nil {
+					return err
+				}
+			}
+			txin.Witness, err = witnessSignature(tx, sigHashes, i, utxo, scriptPubKey, signer)
+			if err != nil {
+				return errors.Wrapf(err, "error signing input type P2WPKH with index %d", i)
+			}
 		case txscript.WitnessV0ScriptHashTy:
 			log.With("module", "qtum").Tracef("scriptPubKey is P2WSH")
 			return errors.New("P2WSH is not supported")
 		case txscript.PubKeyTy:
 			log.With("module", "qtum").Tracef("scriptPubKey is P2PK")
-			// create scriptSig for P2PK
-			// sigHash, err := txscript.CalcSignatureHash(scriptPubKey, txscript.SigHashAll, tx, i)
-			// if err != nil {
-			// 	return errors.Wrapf(err, "Error calculating signature hash for input %d", i)
-			// }
-			signature, err := txscript.RawTxInSignature(tx, i, scriptPubKey, txscript.SigHashAll, privKey)
+			scriptKey := scriptPubKey[1 : len(scriptPubKey)-1]
+			if !bytes.Equal(scriptKey, pubKey.SerializeCompressed()) && !bytes.Equal(scriptKey, pubKey.SerializeUncompressed()) {
+				return errors.Errorf("P2PK input %d does not pay to the wallet key", i)
+			}
+			signature, err := legacySignature(tx, i, scriptPubKey, signer)
 			if err != nil {
 				return errors.Wrapf(err, "Error creating signature for input %d", i)
 			}
@@ -251,26 +549,12 @@ func (q *QtumClient) SignRawTX(tx *wire.MsgTx, unspent []btcjson.ListUnspentResu
 			}
 
 		case txscript.WitnessUnknownTy:
-			// create scriptSig for P2PKH
 			return errors.New("WitnessUnknown type is not supported")
 		case txscript.NonStandardTy:
 			// create scriptSig for non-standard
 			log.With("module", "qtum").Tracef("scriptPubKey is non-standard")
 			return errors.New("non-standard type is not supported")
 		}
-		/*
-			sigScript, err := txscript.SignatureScript(
-				tx,
-				i,
-				scriptPubKey,
-				txscript.SigHashAll,
-				privKey,
-				true,
-			)
-			if err != nil {
-				return errors.Wrapf(err, "Error signing input %d", i)
-			}
-		*/
 
 		txin.SignatureScript = sigScript
 	}
@@ -278,6 +562,102 @@ func (q *QtumClient) SignRawTX(tx *wire.MsgTx, unspent []btcjson.ListUnspentResu
 	return nil
 }
 
+// isCompressedP2PKH returns true if the P2PKH script pays to the hash of the compressed
+// public key, and false if it pays to the uncompressed one
+func isCompressedP2PKH(scriptPubKey []byte, pubKey *secp256k1.PublicKey) (bool, error) {
+	for _, compress := range []bool{true, false} {
+		serialized := pubKey.SerializeUncompressed()
+		if compress {
+			serialized = pubKey.SerializeCompressed()
+		}
+		script, err := txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).
+			AddData(btcutil.Hash160(serialized)).AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).Script()
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(scriptPubKey, script) {
+			return compress, nil
+		}
+	}
+	return false, errors.New("P2PKH output does not pay to the wallet key")
+}
+
+// p2wpkhScript returns the P2WPKH script paying to the compressed public key
+func p2wpkhScript(pubKey *secp256k1.PublicKey) ([]byte, error) {
+	pubKeyHash := btcutil.Hash160(pubKey.SerializeCompressed())
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(pubKeyHash).Script()
+}
+
+// newTxSigHashes returns the BIP143 sighash midstate of the transaction,
+// taking the outputs spent by its inputs from the unspent outputs
+func newTxSigHashes(tx *wire.MsgTx, unspent []btcjson.ListUnspentResult) (*txscript.TxSigHashes, error) {
+	prevOuts := make(map[wire.OutPoint]*wire.TxOut, len(tx.TxIn))
+	for _, txIn := range tx.TxIn {
+		utxo, err := findUnspent(unspent, &txIn.PreviousOutPoint)
+		if err != nil {
+			return nil, err
+		}
+		value, err := btcutil.NewAmount(utxo.Amount)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error converting amount of utxo %s:%d", utxo.TxID, utxo.Vout)
+		}
+		scriptPubKey, err := hex.DecodeString(utxo.ScriptPubKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error decoding scriptPubKey: %s", utxo.ScriptPubKey)
+		}
+		prevOuts[txIn.PreviousOutPoint] = wire.NewTxOut(int64(value), scriptPubKey)
+	}
+	return txscript.NewTxSigHashes(tx, txscript.NewMultiPrevOutFetcher(prevOuts)), nil
+}
+
+// legacySignature returns the signature with hash type of the input
+// spending the P2PKH or P2PK scriptPubKey
+func legacySignature(tx *wire.MsgTx, idx int, scriptPubKey []byte, signer wallet.Signer) ([]byte, error) {
+	sigHash, err := txscript.CalcSignatureHash(scriptPubKey, txscript.SigHashAll, tx, idx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error calculating signature hash")
+	}
+	return signWithHashType(signer, sigHash, txscript.SigHashAll)
+}
+
+// witnessSignature returns the witness <signature> <compressed pubkey> spending the
+// P2WPKH script (the scriptPubKey, or the redeem script of P2SH-P2WPKH) of the input
+func witnessSignature(tx *wire.MsgTx, sigHashes *txscript.TxSigHashes, idx int, utxo *btcjson.ListUnspentResult, script []byte, signer wallet.Signer) (wire.TxWitness, error) {
+	value, err := btcutil.NewAmount(utxo.Amount)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error converting amount of utxo %s:%d", utxo.TxID, utxo.Vout)
+	}
+	sigHash, err := txscript.CalcWitnessSigHash(script, sigHashes, txscript.SigHashAll, tx, idx, int64(value))
+	if err != nil {
+		return nil, errors.Wrap(err, "Error calculating witness signature hash")
+	}
+	signature, err := signWithHashType(signer, sigHash, txscript.SigHashAll)
+	if err != nil {
+		return nil, err
+	}
+	return wire.TxWitness{signature, signer.PublicKey().SerializeCompressed()}, nil
+}
+
+// signWithHashType returns the DER signature of the hash by the signer followed by
+// the hash type, as pushed by the scriptSigs and witnesses
+func signWithHashType(signer wallet.Signer, hash []byte, hashType txscript.SigHashType) ([]byte, error) {
+	signature, err := wallet.SignDER(signer, hash)
+	if err != nil {
+		return nil, err
+	}
+	return append(signature, byte(hashType)), nil
+}
+
+// findUnspent returns the unspent output of the list referenced by the outpoint
+func findUnspent(unspent []btcjson.ListUnspentResult, outpoint *wire.OutPoint) (*btcjson.ListUnspentResult, error) {
+	for i := range unspent {
+		if unspent[i].TxID == outpoint.Hash.String() && unspent[i].Vout == outpoint.Index {
+			return &unspent[i], nil
+		}
+	}
+	return nil, errors.Errorf("unspent output not found for input: %s", outpoint.String())
+}
+
 // sumUTXO sums the amount of all unspent outputs in the given list
 func sumUTXO(list []btcjson.ListUnspentResult) float64 {
 	var sum float64
@@ -288,24 +668,9 @@ func sumUTXO(list []btcjson.ListUnspentResult) float64 {
 }
 
 // calculateChange calculates the change amount due to the sender
-func calculateChange(unspent []btcjson.ListUnspentResult, amount float64) decimal.Decimal {
-	// TODO: research querying of gas fee dynamically
-	// Estimate gas fee
-	// txSize := int64(tx.SerializeSize() / 1000)
-	// log.With("module", "qtum").Tracef("Estimated tx size: %v KB", txSize)
-	// gas, err := q.EstimateFee(txSize)
-	// gas, err := q.EstimateSmartFee(6, nil)
-	// if err != nil {
-	// 	return nil, errors.Wrapf(err, "Error estimating gas fee")
-	// }
-	// log.With("module", "qtum").Tracef("Estimated gas fee: %+v", gas)
-	// if gas.FeeRate == nil {
-	// 	*gas.FeeRate = 100000
-	// }
-
-	// ! hardcoded gas fee in satoshis
-	gasSatoshis := DefaultGasPrice
-	gas := decimal.NewFromFloatWithExponent(float64(gasSatoshis)/Qtum, PrecisionExp)
+// after paying the amount and the given fee
+func calculateChange(unspent []btcjson.ListUnspentResult, amount float64, fee btcutil.Amount) decimal.Decimal {
+	gas := decimal.NewFromFloatWithExponent(fee.ToBTC(), PrecisionExp)
 	utxoTotalAmount := decimal.NewFromFloatWithExponent(sumUTXO(unspent), PrecisionExp)
 	amountToSend := decimal.NewFromFloatWithExponent(amount, PrecisionExp)
 	change := utxoTotalAmount.Sub(amountToSend).Sub(gas)
@@ -2,15 +2,22 @@ package qtum
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"math"
+	"net"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
 	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/qtumproject/btcd/btcec/v2"
 	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/btcutil"
 	"github.com/qtumproject/btcd/txscript"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -64,8 +71,18 @@ const listUnspentResponseJSON string = `[
 
 var listUnspentResponse []btcjson.ListUnspentResult
 var cfg = utils.GetNetworkParams()
-var gasSatoshis int = 10000
-var gas float64 = float64(gasSatoshis) / 100000000
+
+// Fees paid at testFeeRate by the P2PKH txs built in the tests, given their signed sizes
+var (
+	// 1 input, receiver and change outputs: 227 bytes
+	fee1In2Out = calculateFee(227, testFeeRate).ToBTC()
+	// 1 input, receiver output: 193 bytes
+	fee1In1Out = calculateFee(193, testFeeRate).ToBTC()
+	// 2 inputs, receiver and change outputs: 376 bytes
+	fee2In2Out = calculateFee(376, testFeeRate).ToBTC()
+	// 2 inputs, receiver output: 342 bytes
+	fee2In1Out = calculateFee(342, testFeeRate).ToBTC()
+)
 
 func TestBuildUnsignedQtumTx(t *testing.T) {
 	err := json.Unmarshal([]byte(listUnspentResponseJSON), &listUnspentResponse)
@@ -80,7 +97,7 @@ func TestBuildUnsignedQtumTx(t *testing.T) {
 	unspent3 := append(unspent1, unspent2...)
 
 	// Create qtum client
-	qcli, _ := NewQtumClient("", "qtum", "qtumpass", cfg.Net.String())
+	qcli := newTestQtumClient(t)
 
 	// Define and run tests
 	tests := []struct {
@@ -95,14 +112,14 @@ func TestBuildUnsignedQtumTx(t *testing.T) {
 			name:         "test1: one input, amount < input.amount",
 			unspent:      unspent1,
 			outputAmount: 10000.1,
-			wantChange:   9999.9 - gas,
+			wantChange:   9999.9 - fee1In2Out,
 			wantOutputs:  2,
 			wantErr:      false,
 		},
 		{
-			name:         "test2: one input, amount = input.amount-gas",
+			name:         "test2: one input, amount = input.amount-fee",
 			unspent:      unspent1,
-			outputAmount: 20000.0000000 - gas,
+			outputAmount: 20000.0000000 - fee1In1Out,
 			wantChange:   0,
 			wantOutputs:  1,
 			wantErr:      false,
@@ -111,14 +128,14 @@ func TestBuildUnsignedQtumTx(t *testing.T) {
 			name:         "test3: two inputs, amount < inputs.amount",
 			unspent:      unspent3,
 			outputAmount: 30000.3,
-			wantChange:   9999.7 - gas,
+			wantChange:   9999.7 - fee2In2Out,
 			wantOutputs:  2,
 			wantErr:      false,
 		},
 		{
-			name:         "test4: two inputs, amount = inputs.amount-gas",
+			name:         "test4: two inputs, amount = inputs.amount-fee",
 			unspent:      unspent3,
-			outputAmount: 40000 - gas,
+			outputAmount: 40000 - fee2In1Out,
 			wantChange:   0,
 			wantOutputs:  1,
 			wantErr:      false,
@@ -127,7 +144,7 @@ func TestBuildUnsignedQtumTx(t *testing.T) {
 			name:         "test5: two inputs, amount with decimals",
 			unspent:      unspent3,
 			outputAmount: 30000.8,
-			wantChange:   9999.2 - gas,
+			wantChange:   9999.2 - fee2In2Out,
 			wantOutputs:  2,
 			wantErr:      false,
 		},
@@ -143,7 +160,7 @@ func TestBuildUnsignedQtumTx(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			unsignedTx, err := qcli.BuildUnsignedQtumTx(tt.unspent, SENDER_ADDR, RECEIVER_ADDR, tt.outputAmount)
+			unsignedTx, _, err := qcli.BuildUnsignedQtumTx(tt.unspent, SENDER_ADDR, RECEIVER_ADDR, tt.outputAmount)
 			// check error
 			if err != nil {
 				if tt.wantErr {
@@ -188,6 +205,134 @@ func TestBuildUnsignedQtumTx(t *testing.T) {
 
 }
 
+func TestBuildUnsignedQtumTxChange(t *testing.T) {
+	const changeAddr = "qcXuYftjg3Sv9kzHv4M5zY5DL5szbJN488"
+	err := json.Unmarshal([]byte(listUnspentResponseJSON), &listUnspentResponse)
+	utils.HandleFatalError(t, err)
+	unspent := listUnspentResponse[0:1]
+	qcli := newTestQtumClient(t)
+
+	// the change is paid to the change address
+	tx, dropped, err := qcli.BuildUnsignedQtumTx(unspent, changeAddr, RECEIVER_ADDR, 20000-fee1In2Out-0.001)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, btcutil.Amount(0), dropped)
+	assert.Len(t, tx.TxOut, 2)
+	script, err := txscript.ParsePkScript(tx.TxOut[1].PkScript)
+	utils.HandleFatalError(t, err)
+	addr, err := script.Address(cfg)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, changeAddr, addr.String())
+	assert.Equal(t, int64(100000), tx.TxOut[1].Value)
+
+	// change lower than the dust threshold is paid as fee
+	tx, dropped, err = qcli.BuildUnsignedQtumTx(unspent, changeAddr, RECEIVER_ADDR, 20000-fee1In2Out-0.0005)
+	utils.HandleFatalError(t, err)
+	assert.Len(t, tx.TxOut, 1)
+	want, _ := btcutil.NewAmount(0.0005 + fee1In2Out - fee1In1Out)
+	assert.Equal(t, want, dropped)
+
+	utils.HandleFatalError(t, qcli.SetDustThreshold(btcutil.Amount(Qtum)))
+	tx, dropped, err = qcli.BuildUnsignedQtumTx(unspent, changeAddr, RECEIVER_ADDR, 20000-fee1In2Out-0.5)
+	utils.HandleFatalError(t, err)
+	assert.Len(t, tx.TxOut, 1)
+	want, _ = btcutil.NewAmount(0.5 + fee1In2Out - fee1In1Out)
+	assert.Equal(t, want, dropped)
+
+	assert.Error(t, qcli.SetDustThreshold(-1))
+}
+
+func TestBuildConsolidationTx(t *testing.T) {
+	var template []btcjson.ListUnspentResult
+	err := json.Unmarshal([]byte(listUnspentResponseJSON), &template)
+	utils.HandleFatalError(t, err)
+	newUnspent := func(amounts ...float64) []btcjson.ListUnspentResult {
+		unspent := make([]btcjson.ListUnspentResult, len(amounts))
+		for i, amount := range amounts {
+			unspent[i] = template[0]
+			unspent[i].Vout = uint32(i)
+			unspent[i].Amount = amount
+		}
+		return unspent
+	}
+	// value of the output merging the unspent outputs with the vouts
+	merged := func(unspent []btcjson.ListUnspentResult, vouts ...uint32) int64 {
+		var sum btcutil.Amount
+		for _, vout := range vouts {
+			amount, _ := btcutil.NewAmount(unspent[vout].Amount)
+			sum += amount
+		}
+		return int64(sum - calculateFee(txOverheadSize+len(vouts)*p2pkhInputSize+changeOutputSize, testFeeRate))
+	}
+	qcli := newTestQtumClient(t)
+
+	tests := []struct {
+		name      string
+		unspent   []btcjson.ListUnspentResult
+		maxInputs int
+		wantVouts []uint32
+		wantErr   error
+	}{
+		{
+			name:      "smallest first, largest left out",
+			unspent:   newUnspent(3, 0.5, 2, 1),
+			maxInputs: 10,
+			wantVouts: []uint32{1, 3, 2},
+		},
+		{
+			name:      "max inputs",
+			unspent:   newUnspent(3, 0.5, 2, 1),
+			maxInputs: 2,
+			wantVouts: []uint32{1, 3},
+		},
+		{
+			name:      "uneconomical utxos skipped",
+			unspent:   newUnspent(0.0001, 3, 0.5, 1),
+			maxInputs: 10,
+			wantVouts: []uint32{2, 3},
+		},
+		{
+			name:      "not enough utxos",
+			unspent:   newUnspent(3, 0.0001, 1),
+			maxInputs: 10,
+			wantErr:   ErrNothingToConsolidate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := qcli.BuildConsolidationTx(tt.unspent, SENDER_ADDR, tt.maxInputs)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			utils.HandleFatalError(t, err)
+			var vouts []uint32
+			for _, txIn := range tx.TxIn {
+				vouts = append(vouts, txIn.PreviousOutPoint.Index)
+			}
+			assert.Equal(t, tt.wantVouts, vouts)
+			assert.Len(t, tx.TxOut, 1)
+			assert.Equal(t, merged(tt.unspent, tt.wantVouts...), tx.TxOut[0].Value)
+			script, err := txscript.ParsePkScript(tx.TxOut[0].PkScript)
+			utils.HandleFatalError(t, err)
+			addr, err := script.Address(cfg)
+			utils.HandleFatalError(t, err)
+			assert.Equal(t, SENDER_ADDR, addr.String())
+		})
+	}
+
+	// unconfirmed utxos are not merged
+	unspent := newUnspent(3, 0.5, 2, 1)
+	unspent[1].Confirmations = 1
+	tx, err := qcli.BuildConsolidationTx(unspent, SENDER_ADDR, 10)
+	utils.HandleFatalError(t, err)
+	assert.Len(t, tx.TxIn, 2)
+
+	// the merged output can not be dust
+	utils.HandleFatalError(t, qcli.SetDustThreshold(btcutil.Amount(2*Qtum)))
+	_, err = qcli.BuildConsolidationTx(newUnspent(3, 0.5, 1), SENDER_ADDR, 10)
+	assert.ErrorIs(t, err, ErrNothingToConsolidate)
+}
+
 func round(num float64, decimalPlaces int) float64 {
 	shift := math.Pow(10, float64(decimalPlaces))
 	return math.Round(num*shift) / shift
@@ -203,11 +348,11 @@ func TestSignRawTx(t *testing.T) {
 	inputs := listUnspent[0:2]
 
 	// Create qtum client
-	qcli, _ := NewQtumClient("", "qtum", "qtumpass", cfg.Net.String())
+	qcli := newTestQtumClient(t)
 
 	// Build unsigned tx
 	amount := 10000.1
-	tx, err := qcli.BuildUnsignedQtumTx(inputs, SENDER_ADDR, RECEIVER_ADDR, amount)
+	tx, _, err := qcli.BuildUnsignedQtumTx(inputs, SENDER_ADDR, RECEIVER_ADDR, amount)
 	utils.HandleFatalError(t, err)
 
 	// Mocked wallet
@@ -245,3 +390,153 @@ func TestSignRawTx(t *testing.T) {
 	}
 
 }
+
+func TestSignRawTxWalletAddresses(t *testing.T) {
+	w, err := wallet.NewQtumWallet(SENDER_PRIVKEY, cfg)
+	utils.HandleFatalError(t, err)
+	addresses, err := w.GetQtumAddresses()
+	utils.HandleFatalError(t, err)
+	privKeyBytes, _ := hex.DecodeString(SENDER_PRIVKEY)
+	_, pubKey := btcec.PrivKeyFromBytes(privKeyBytes)
+
+	// scripts paying to the sender key, and the wallet address of their outputs
+	senderP2PKH := "76a9147926223070547d2d15b2ef5e7383e541c338ffe988ac"
+	uncompressedP2PKH := "76a914" + hex.EncodeToString(btcutil.Hash160(pubKey.SerializeUncompressed())) + "88ac"
+	senderP2PK := "21" + hex.EncodeToString(pubKey.SerializeCompressed()) + "ac"
+	uncompressedP2PK := "41" + hex.EncodeToString(pubKey.SerializeUncompressed()) + "ac"
+	senderP2WPKH := "00147926223070547d2d15b2ef5e7383e541c338ffe9"
+	redeemScript, _ := hex.DecodeString(senderP2WPKH)
+	senderP2SH := "a914" + hex.EncodeToString(btcutil.Hash160(redeemScript)) + "87"
+	scriptAddresses := map[string]string{
+		senderP2PKH:       addresses[0],
+		senderP2PK:        addresses[0],
+		uncompressedP2PKH: addresses[1],
+		uncompressedP2PK:  addresses[1],
+		senderP2WPKH:      addresses[2],
+		senderP2SH:        addresses[3],
+	}
+	const otherP2WPKH = "0014" + "96216849c49358b10257cb55b28ea603c874b05e"
+	const otherP2SH = "a914" + "96216849c49358b10257cb55b28ea603c874b05e" + "87"
+
+	newUtxo := func(i int, scriptPubKey string) btcjson.ListUnspentResult {
+		address, ok := scriptAddresses[scriptPubKey]
+		if !ok {
+			// outputs of other keys are reported with an address of the wallet
+			address = addresses[i%len(addresses)]
+		}
+		return btcjson.ListUnspentResult{
+			TxID:          fmt.Sprintf("%064x", i+1),
+			Vout:          uint32(i),
+			Address:       address,
+			ScriptPubKey:  scriptPubKey,
+			Amount:        1,
+			Confirmations: 100,
+		}
+	}
+	tests := []struct {
+		name    string
+		scripts []string
+		wantErr bool
+	}{
+		{name: "P2WPKH", scripts: []string{senderP2WPKH}},
+		{name: "P2SH-P2WPKH", scripts: []string{senderP2SH}},
+		{name: "P2PKH, P2WPKH and P2SH-P2WPKH", scripts: []string{senderP2PKH, senderP2WPKH, senderP2SH}},
+		{name: "uncompressed P2PKH and P2PK", scripts: []string{uncompressedP2PKH, senderP2PK, uncompressedP2PK}},
+		{name: "every address", scripts: []string{senderP2PK, uncompressedP2PKH, senderP2SH, senderP2PKH, senderP2WPKH, uncompressedP2PK}},
+		{name: "P2WPKH of other key", scripts: []string{otherP2WPKH}, wantErr: true},
+		{name: "P2SH of other script", scripts: []string{otherP2SH}, wantErr: true},
+		{name: "P2PK of other key", scripts: []string{"21" + "02" + strings.Repeat("11", 32) + "ac"}, wantErr: true},
+	}
+
+	qcli := newTestQtumClient(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var unspent []btcjson.ListUnspentResult
+			for i, script := range tt.scripts {
+				unspent = append(unspent, newUtxo(i, script))
+			}
+			tx, _, err := qcli.BuildUnsignedQtumTx(unspent, SENDER_ADDR, RECEIVER_ADDR, 0.9*float64(len(unspent)))
+			utils.HandleFatalError(t, err)
+			size, err := estimateSignedSize(tx, unspent)
+			utils.HandleFatalError(t, err)
+
+			err = qcli.SignRawTX(tx, unspent, w)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			utils.HandleFatalError(t, err)
+
+			// every output is spent and every input is valid for the script engine
+			assert.Len(t, tx.TxIn, len(unspent))
+			sigHashes, err := newTxSigHashes(tx, unspent)
+			utils.HandleFatalError(t, err)
+			for i, txIn := range tx.TxIn {
+				utxo, err := findUnspent(unspent, &txIn.PreviousOutPoint)
+				utils.HandleFatalError(t, err)
+				scriptPubKey, _ := hex.DecodeString(utxo.ScriptPubKey)
+				value, _ := btcutil.NewAmount(utxo.Amount)
+				vm, err := txscript.NewEngine(scriptPubKey, tx, i, txscript.StandardVerifyFlags, nil, sigHashes, int64(value), nil)
+				utils.HandleFatalError(t, err)
+				assert.NoError(t, vm.Execute(), "input %d", i)
+			}
+
+			// the virtual size estimate is an upper bound, but for the uncompressed
+			// pubkeys of P2PKH scriptSigs, which can not be told from the script
+			vsize := (tx.SerializeSizeStripped()*3 + tx.SerializeSize() + 3) / 4
+			for _, txIn := range tx.TxIn {
+				utxo, _ := findUnspent(unspent, &txIn.PreviousOutPoint)
+				if utxo.ScriptPubKey == uncompressedP2PKH {
+					size += 65 - 33
+				}
+			}
+			assert.GreaterOrEqual(t, size, vsize)
+			assert.LessOrEqual(t, size-vsize, 2*len(tx.TxIn))
+		})
+	}
+}
+
+func TestSignRawTxExternalSigner(t *testing.T) {
+	// stub external signer holding the sender key, listening on a unix socket
+	privKeyBytes, _ := hex.DecodeString(SENDER_PRIVKEY)
+	privKey, _ := btcec.PrivKeyFromBytes(privKeyBytes)
+	server := rpc.NewServer()
+	utils.HandleFatalError(t, server.RegisterName("signer", wallet.NewSignerService(wallet.NewKeySigner(privKey))))
+	endpoint := filepath.Join(t.TempDir(), "signer.ipc")
+	listener, err := net.Listen("unix", endpoint)
+	utils.HandleFatalError(t, err)
+	go server.ServeListener(listener)
+	t.Cleanup(func() {
+		listener.Close()
+		server.Stop()
+	})
+
+	rs, err := wallet.DialSigner(context.Background(), endpoint)
+	utils.HandleFatalError(t, err)
+	defer rs.Close()
+	signers, err := rs.Signers()
+	utils.HandleFatalError(t, err)
+	w := wallet.NewSignerWallet(signers[0], cfg)
+
+	listUnspent := []btcjson.ListUnspentResult{}
+	err = json.Unmarshal([]byte(listUnspentResponseJSON), &listUnspent)
+	utils.HandleFatalError(t, err)
+	unspent := listUnspent[0:2]
+	qcli := newTestQtumClient(t)
+	tx, _, err := qcli.BuildUnsignedQtumTx(unspent, SENDER_ADDR, RECEIVER_ADDR, 30000)
+	utils.HandleFatalError(t, err)
+	err = qcli.SignRawTX(tx, unspent, w)
+	utils.HandleFatalError(t, err)
+
+	// every input is signed by the key of the external signer
+	assert.Len(t, tx.TxIn, 2)
+	for i, txIn := range tx.TxIn {
+		utxo, err := findUnspent(unspent, &txIn.PreviousOutPoint)
+		utils.HandleFatalError(t, err)
+		scriptPubKey, _ := hex.DecodeString(utxo.ScriptPubKey)
+		value, _ := btcutil.NewAmount(utxo.Amount)
+		vm, err := txscript.NewEngine(scriptPubKey, tx, i, txscript.StandardVerifyFlags, nil, nil, int64(value), nil)
+		utils.HandleFatalError(t, err)
+		assert.NoError(t, vm.Execute(), "input %d", i)
+	}
+}
@@ -1,29 +1,30 @@
 package qtum
 
 import (
+	"encoding/hex"
 	"fmt"
+	"sync"
 
 	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
 	"github.com/pkg/errors"
 	"github.com/qtumproject/btcd/btcjson"
 )
 
 var walletExists = false
 
+// importedPubKeys holds the hex public keys imported into the node's wallet by VerifyWallet
+var importedPubKeys sync.Map
+
 var errorWalletNotFound = btcjson.NewRPCError(btcjson.ErrRPCWalletNotFound, "No wallet is loaded. Load a wallet using loadwallet or create a new one with createwallet. (Note: A default wallet is no longer automatically created)")
 
 // VerifyAddress checks if the address is known to the node's wallet.
 // If not, it will import the address and rescan the blockchain seeking transactions
 // related to this address.
 func (q *QtumClient) VerifyAddress(address string) error {
-	// check the node's wallet exists
-	if !walletExists {
-		log.With("module", "qtum").Debugf("Verifying node wallet...")
-		err := q.verifyNodeWallet()
-		if err != nil {
-			return errors.Wrap(err, "Error verifying node wallet")
-		}
-		walletExists = true
+	err := q.ensureNodeWallet()
+	if err != nil {
+		return err
 	}
 	result, err := q.GetAddressInfo(address)
 	if err != nil {
@@ -41,6 +42,69 @@ func (q *QtumClient) VerifyAddress(address string) error {
 	return nil
 }
 
+// VerifyWallet checks every address of the wallet key (see GetQtumAddresses) is known
+// to the node's wallet. The compressed and uncompressed public keys of the wallet are
+// imported, so the node also tracks the P2PK outputs of the key, which have no address.
+// The blockchain is rescanned if any of the addresses was unknown
+func (q *QtumClient) VerifyWallet(w wallet.IQtumWallet) error {
+	err := q.ensureNodeWallet()
+	if err != nil {
+		return err
+	}
+	addrs, err := w.GetQtumAddresses()
+	if err != nil {
+		return errors.Wrap(err, "Error getting qtum addresses of wallet")
+	}
+	signer, err := w.GetSigner(addrs[0])
+	if err != nil {
+		return errors.Wrap(err, "Error getting signer of wallet")
+	}
+	rescan := false
+	for _, addr := range addrs {
+		result, err := q.GetAddressInfo(addr)
+		if err != nil {
+			return errors.Wrap(err, "Error getting info for address: "+addr)
+		}
+		log.With("module", "qtum").Tracef("Address info result: %+v", result)
+		if !result.IsWatchOnly && !result.IsMine {
+			log.With("module", "qtum").Debugf("Address %s not found in wallet", addr)
+			rescan = true
+		}
+	}
+	pubKey := signer.PublicKey()
+	pubKeys := []string{
+		hex.EncodeToString(pubKey.SerializeCompressed()),
+		hex.EncodeToString(pubKey.SerializeUncompressed()),
+	}
+	for i, pk := range pubKeys {
+		if _, ok := importedPubKeys.Load(pk); ok && !rescan {
+			continue
+		}
+		// the blockchain is rescanned once, after importing the last key
+		log.With("module", "qtum").Debugf("Importing public key %s (rescan: %t)", pk, rescan)
+		err := q.ImportPubKeyRescan(pk, rescan && i == len(pubKeys)-1)
+		if err != nil {
+			return errors.Wrap(err, "Error importing public key: "+pk)
+		}
+		importedPubKeys.Store(pk, true)
+	}
+	return nil
+}
+
+// ensureNodeWallet verifies the node's wallet exists, creating it if needed, the first time it is called
+func (q *QtumClient) ensureNodeWallet() error {
+	if walletExists {
+		return nil
+	}
+	log.With("module", "qtum").Debugf("Verifying node wallet...")
+	err := q.verifyNodeWallet()
+	if err != nil {
+		return errors.Wrap(err, "Error verifying node wallet")
+	}
+	walletExists = true
+	return nil
+}
+
 // VerifyNodeWallet checks that the node's wallet exists and if not, it will create it.
 func (q *QtumClient) verifyNodeWallet() error {
 	walletInfo, err := q.GetWalletInfo()
@@ -1,10 +1,12 @@
 package qtum
 
 import (
+	"encoding/hex"
 	"testing"
 
 	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
 	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -53,6 +55,7 @@ func TestVerifyAddress(t *testing.T) {
 		"getaddressinfo": getaddressinfoJSON,
 		"createwallet":   createwalletJSON,
 		"importaddress":  "null",
+		"importpubkey":   "null",
 	}
 
 	// create mock qtumd server
@@ -63,6 +66,22 @@ func TestVerifyAddress(t *testing.T) {
 	qcli, err := NewQtumClient(mockQtumd.URL, "qtum", "qtumpass", cfg.Net.String())
 	utils.HandleFatalError(t, err)
 
+	// the compressed and uncompressed public keys of the wallet are imported
+	t.Run("wallet", func(t *testing.T) {
+		w, err := wallet.NewQtumWallet(SENDER_PRIVKEY, cfg)
+		utils.HandleFatalError(t, err)
+		err = qcli.VerifyWallet(w)
+		utils.HandleFatalError(t, err)
+		addr, err := w.GetQtumAddress()
+		utils.HandleFatalError(t, err)
+		signer, err := w.GetSigner(addr)
+		utils.HandleFatalError(t, err)
+		for _, pubKey := range [][]byte{signer.PublicKey().SerializeCompressed(), signer.PublicKey().SerializeUncompressed()} {
+			_, ok := importedPubKeys.Load(hex.EncodeToString(pubKey))
+			assert.True(t, ok)
+		}
+	})
+
 	// create tests
 	var tests = []struct {
 		name    string
@@ -0,0 +1,32 @@
+package types
+
+import (
+	"github.com/qtumproject/btcd/chaincfg"
+)
+
+// The chain params of the qtum networks. They are copies of the qtum chain params
+// of btcd with the human readable parts of the bech32 segwit addresses, missing in
+// btcd, so the params of btcd are left untouched
+var (
+	QtumMainnetParams = withSegwitHRP(chaincfg.QtumMainnetParams, "qc")
+	QtumTestnetParams = withSegwitHRP(chaincfg.QtumTestnetParams, "tq")
+	QtumRegtestParams = withSegwitHRP(chaincfg.QtumRegtestParams, "qcrt")
+)
+
+// withSegwitHRP returns a copy of the params with the segwit human readable part,
+// unless the params already have one
+func withSegwitHRP(params chaincfg.Params, hrp string) chaincfg.Params {
+	if params.Bech32HRPSegwit == "" {
+		params.Bech32HRPSegwit = hrp
+	}
+	return params
+}
+
+// init registers the qtum chain params, so btcutil encodes and decodes the
+// segwit addresses of the qtum networks
+func init() {
+	for _, params := range []*chaincfg.Params{&QtumMainnetParams, &QtumTestnetParams, &QtumRegtestParams} {
+		// fails if the network is already registered, keeping its registration
+		_ = chaincfg.Register(params)
+	}
+}
@@ -0,0 +1,150 @@
+package types
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcutil"
+)
+
+// This file contains the types used to build qtum contract transactions
+
+const (
+	// ContractVMVersion is the EVM version pushed in qtum contract output scripts
+	ContractVMVersion = 4
+	// MinGasPrice is the minimum gas price (in satoshis) accepted by the qtum network
+	MinGasPrice = 40
+	// MinGasLimit is the minimum gas limit accepted by the qtum network for a contract output
+	MinGasLimit = 10000
+	// MaxGasLimit is the default block gas limit of the qtum network, which
+	// no contract output can exceed
+	MaxGasLimit = 40000000
+	// DefaultCallGasLimit is the gas limit used by the node for contract calls (callcontract)
+	// when none is given, which matches the default block gas limit
+	DefaultCallGasLimit = 40000000
+)
+
+// ContractParams holds the values needed to build a qtum contract output
+type ContractParams struct {
+	// GasLimit is the maximum amount of gas the contract execution can consume
+	GasLimit uint64
+	// GasPrice is the price to pay per unit of gas in satoshis
+	GasPrice uint64
+	// Data is the EVM bytecode of the contract to be created
+	// or the ABI encoded data of the contract call
+	Data []byte
+	// Address is the hex address (without 0x prefix) of the contract
+	// to call. It is empty when creating a new contract
+	Address string
+}
+
+// IsCreate returns true if the params describe the creation of a new contract
+func (c *ContractParams) IsCreate() bool {
+	return c.Address == ""
+}
+
+// GasFee returns the amount in Qtum reserved to pay for the contract execution
+// (i.e. gasLimit * gasPrice). The params must be valid (see Validate)
+func (c *ContractParams) GasFee() float64 {
+	return btcutil.Amount(c.GasLimit * c.GasPrice).ToBTC()
+}
+
+// Validate checks the contract params are acceptable to the qtum network
+func (c *ContractParams) Validate() error {
+	if c.GasLimit < MinGasLimit {
+		return errors.Errorf("gas limit %d is lower than the minimum gas limit %d", c.GasLimit, MinGasLimit)
+	}
+	if c.GasLimit > MaxGasLimit {
+		return errors.Errorf("gas limit %d is higher than the block gas limit %d", c.GasLimit, MaxGasLimit)
+	}
+	if c.GasPrice < MinGasPrice {
+		return errors.Errorf("gas price %d is lower than the minimum gas price %d", c.GasPrice, MinGasPrice)
+	}
+	if c.GasPrice > math.MaxInt64/c.GasLimit {
+		return errors.Errorf("gas fee of gas limit %d and gas price %d overflows", c.GasLimit, c.GasPrice)
+	}
+	return nil
+}
+
+// This section contains the types returned by qtum specific RPC calls
+
+// RPC Method: callcontract
+type CallContractResult struct {
+	Address            string                 `json:"address"`
+	ExecutionResult    ExecutionResult        `json:"executionResult"`
+	TransactionReceipt CallTransactionReceipt `json:"transactionReceipt"`
+}
+
+// ExecutionResult is the result of the EVM execution of a contract call
+type ExecutionResult struct {
+	GasUsed         uint64 `json:"gasUsed"`
+	Excepted        string `json:"excepted"`
+	ExceptedMessage string `json:"exceptedMessage"`
+	NewAddress      string `json:"newAddress"`
+	Output          string `json:"output"`
+	CodeDeposit     int    `json:"codeDeposit"`
+	GasRefunded     uint64 `json:"gasRefunded"`
+	DepositSize     int    `json:"depositSize"`
+	GasForDeposit   uint64 `json:"gasForDeposit"`
+}
+
+// ExceptedNone is the value of ExecutionResult.Excepted for successful executions
+const ExceptedNone = "None"
+
+// ExceptedRevert is the value of ExecutionResult.Excepted for reverted executions
+const ExceptedRevert = "Revert"
+
+// Failed returns true if the contract execution raised an exception
+func (r *ExecutionResult) Failed() bool {
+	return r.Excepted != "" && r.Excepted != ExceptedNone
+}
+
+// CallTransactionReceipt is the receipt of a simulated contract call
+type CallTransactionReceipt struct {
+	StateRoot string `json:"stateRoot"`
+	GasUsed   uint64 `json:"gasUsed"`
+	Bloom     string `json:"bloom"`
+	Log       []Log  `json:"log"`
+}
+
+// Log is an event log emitted by a qtum contract
+type Log struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+// RPC Method: gettransactionreceipt
+type TransactionReceipt struct {
+	BlockHash         string `json:"blockHash"`
+	BlockNumber       uint64 `json:"blockNumber"`
+	TransactionHash   string `json:"transactionHash"`
+	TransactionIndex  uint64 `json:"transactionIndex"`
+	OutputIndex       uint32 `json:"outputIndex"`
+	From              string `json:"from"`
+	To                string `json:"to"`
+	CumulativeGasUsed uint64 `json:"cumulativeGasUsed"`
+	GasUsed           uint64 `json:"gasUsed"`
+	ContractAddress   string `json:"contractAddress"`
+	Excepted          string `json:"excepted"`
+	ExceptedMessage   string `json:"exceptedMessage"`
+	Bloom             string `json:"bloom"`
+	StateRoot         string `json:"stateRoot"`
+	UtxoRoot          string `json:"utxoRoot"`
+	Log               []Log  `json:"log"`
+}
+
+// Failed returns true if the contract execution of the receipt did not succeed
+func (r *TransactionReceipt) Failed() bool {
+	return r.Excepted != ExceptedNone
+}
+
+// RPC Method: getdgpinfo
+//
+// DGPInfo holds the blockchain parameters set by the qtum
+// Decentralized Governance Protocol
+type DGPInfo struct {
+	MaxBlockSize  uint64 `json:"maxblocksize"`
+	MinGasPrice   uint64 `json:"mingasprice"`
+	BlockGasLimit uint64 `json:"blockgaslimit"`
+}
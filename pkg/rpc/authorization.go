@@ -0,0 +1,95 @@
+package rpc
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// dailyValueWindow is the period of the daily value limit of the wallet policies
+const dailyValueWindow = 24 * time.Hour
+
+// authorizeTx checks that the signed ethereum transaction of the sender can be
+// signed with the wallet: its signature must recover the public key of the wallet,
+// and it must be allowed by the policy of the wallet, if any. Every decision is
+// logged. Must be called with sendMu held, so the daily value includes every
+// transaction sent before.
+//
+// Returns an unauthorizedError if the transaction is rejected.
+func (api *EthAPI) authorizeTx(w *wallet.QtumWallet, sender common.Address, tx *types.Transaction) error {
+	if !VerifyTxSignature(tx, w.GetPublicKeyHex()) {
+		err := errors.Wrapf(wallet.ErrNotAuthorized, "signature of tx %s does not match the wallet key of %s", tx.Hash(), sender)
+		log.With("module", "auth").Infof("Rejected tx %s: %v", tx.Hash(), err)
+		return &unauthorizedError{err: err}
+	}
+
+	policy := wallet.GetPolicy(sender)
+	if policy == nil {
+		log.With("module", "auth").Debugf("Authorized tx %s of %s: no policy", tx.Hash(), sender)
+		return nil
+	}
+	dailySpent := new(big.Int)
+	if policy.MaxDailyValue != nil {
+		var err error
+		dailySpent, err = (*API)(api).getSentValue(sender, time.Now().Add(-dailyValueWindow))
+		if err != nil {
+			return errors.Wrapf(err, "Error getting value sent by %s", sender)
+		}
+	}
+	err := policy.Authorize(tx.To(), tx.Value(), dailySpent)
+	if err != nil {
+		log.With("module", "auth").Infof("Rejected tx %s of %s: %v", tx.Hash(), sender, err)
+		return &unauthorizedError{err: err}
+	}
+	log.With("module", "auth").Infof("Authorized tx %s of %s sending %v wei", tx.Hash(), sender, tx.Value())
+	return nil
+}
+
+// authorizeSign checks that messages or typed data can be signed with the wallet of
+// the address. A signed message can authorize transfers on behalf of the wallet (e.g. an
+// EIP-2612 permit) whose receivers and values are not checked by the wallet policy, so
+// wallets with a policy are not allowed to sign them. Rejections are logged.
+//
+// Returns an unauthorizedError if the signature is rejected.
+func authorizeSign(address common.Address, method string) error {
+	if wallet.GetPolicy(address) == nil {
+		return nil
+	}
+	err := errors.Wrapf(wallet.ErrNotAuthorized, "wallet %s has a policy and can not sign with %s", address, method)
+	log.With("module", "auth").Infof("Rejected %s of %s: %v", method, address, err)
+	return &unauthorizedError{err: err}
+}
+
+// getSentValue returns the value in wei of the recorded transactions of the
+// address broadcast since the given time. The records are walked from the
+// highest nonce down, until a record broadcast before that time. Records are
+// stored before their qtum tx is broadcast, so every sent tx is counted and a
+// missing record is an error
+func (api *API) getSentValue(address common.Address, since time.Time) (*big.Int, error) {
+	total := new(big.Int)
+	nonce, err := api.txStore.GetNonce(address.String())
+	if err != nil {
+		return nil, err
+	}
+	for nonce > 0 {
+		nonce--
+		record, err := api.txStore.GetTxByNonce(address.String(), nonce)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error getting record of nonce %d of address %s", nonce, address)
+		}
+		if record.Timestamp.Before(since) {
+			break
+		}
+		signedTx, err := decodeRawTx(record.EthRawTx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error decoding eth raw tx of record %s", record.EthHash)
+		}
+		total.Add(total, signedTx.Value())
+	}
+	return total, nil
+}
@@ -0,0 +1,151 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/alejoacosta74/qproxy/pkg/store"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendRawTxAuthorization(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+	allowed := common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb")
+	other := common.HexToAddress("0x7926223070547D2D15b2eF5e7383E541c338FfE9")
+
+	tests := []struct {
+		name   string
+		policy *wallet.Policy
+		txs    []*types.Transaction
+		// index of the first rejected tx, or -1 if all are authorized
+		rejected int
+	}{
+		{
+			name:     "no policy",
+			txs:      []*types.Transaction{types.NewTransaction(0, other, big.NewInt(1000000), 21000, big.NewInt(20000000000), nil)},
+			rejected: -1,
+		},
+		{
+			name:     "allowed receiver",
+			policy:   &wallet.Policy{AllowedTo: []common.Address{allowed}},
+			txs:      []*types.Transaction{types.NewTransaction(0, allowed, big.NewInt(1000000), 21000, big.NewInt(20000000000), nil)},
+			rejected: -1,
+		},
+		{
+			name:     "receiver not allowed",
+			policy:   &wallet.Policy{AllowedTo: []common.Address{allowed}},
+			txs:      []*types.Transaction{types.NewTransaction(0, other, big.NewInt(1000000), 21000, big.NewInt(20000000000), nil)},
+			rejected: 0,
+		},
+		{
+			name:     "contract creation not allowed",
+			policy:   &wallet.Policy{AllowedTo: []common.Address{allowed}},
+			txs:      []*types.Transaction{types.NewContractCreation(0, big.NewInt(0), 2500000, big.NewInt(400000000000), []byte{0x60, 0x80})},
+			rejected: 0,
+		},
+		{
+			name:     "max tx value",
+			policy:   &wallet.Policy{MaxTxValue: big.NewInt(999999)},
+			txs:      []*types.Transaction{types.NewTransaction(0, other, big.NewInt(1000000), 21000, big.NewInt(20000000000), nil)},
+			rejected: 0,
+		},
+		{
+			name:   "max daily value",
+			policy: &wallet.Policy{MaxDailyValue: big.NewInt(2500000)},
+			txs: []*types.Transaction{
+				types.NewTransaction(0, other, big.NewInt(1000000), 21000, big.NewInt(20000000000), nil),
+				types.NewTransaction(1, other, big.NewInt(1000000), 21000, big.NewInt(20000000000), nil),
+				types.NewTransaction(2, other, big.NewInt(1000000), 21000, big.NewInt(20000000000), nil),
+			},
+			rejected: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQcli := mocks.NewMockQCli()
+			api := NewAPI(context.Background(), mockQcli)
+			api.SetNetworkParams(cfg)
+			ethAPI := (*EthAPI)(api)
+			w := loadTestWallet(t, PRIVATEKEY)
+			wallet.SetPolicy(*w.GetEthereumAddress(), tt.policy)
+			t.Cleanup(func() { wallet.SetPolicy(*w.GetEthereumAddress(), nil) })
+
+			for i, tx := range tt.txs {
+				signedTx, err := signEthereumTx(tx, types.HomesteadSigner{}, PRIVATEKEY)
+				utils.HandleFatalError(t, err)
+				rawTx, err := signedTx.MarshalBinary()
+				utils.HandleFatalError(t, err)
+
+				_, err = ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+				if i != tt.rejected {
+					assert.NoError(t, err)
+					continue
+				}
+				assert.ErrorIs(t, err, wallet.ErrNotAuthorized)
+				rpcErr, ok := err.(rpc.Error)
+				if assert.True(t, ok, "error without JSON-RPC code") {
+					assert.Equal(t, errCodeUnauthorized, rpcErr.ErrorCode())
+				}
+				// the rejected tx is not broadcast
+				_, err = api.txStore.GetTxByEthHash(signedTx.Hash().Hex())
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestAuthorizeTxWalletKey(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+	const OTHERKEY = "fad9c8855b740a0b7ed4c221dbad0f33a83a49cad6b3fe8d5817ac83d38b6a19"
+
+	api := NewAPI(context.Background(), mocks.NewMockQCli())
+	ethAPI := (*EthAPI)(api)
+	signedTx, err := signEthereumTx(newEthereumTx(), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	sender, err := getFromAddress(signedTx)
+	utils.HandleFatalError(t, err)
+
+	w, err := wallet.NewQtumWallet(PRIVATEKEY, cfg)
+	utils.HandleFatalError(t, err)
+	assert.NoError(t, ethAPI.authorizeTx(w, *sender, signedTx))
+
+	// a wallet with another key can not sign the tx of the sender
+	otherWallet, err := wallet.NewQtumWallet(OTHERKEY, cfg)
+	utils.HandleFatalError(t, err)
+	err = ethAPI.authorizeTx(otherWallet, *sender, signedTx)
+	assert.ErrorIs(t, err, wallet.ErrNotAuthorized)
+}
+
+func TestGetSentValueMissingRecord(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	signedTx, err := signEthereumTx(newEthereumTx(), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	sendTestTx(t, ethAPI, mockQcli, signedTx)
+	sender, err := getFromAddress(signedTx)
+	utils.HandleFatalError(t, err)
+
+	value, err := api.getSentValue(*sender, time.Now().Add(-time.Hour))
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, signedTx.Value(), value)
+
+	// every sent tx has a record, so a missing one is not skipped
+	api.SetTxStore(&failingNonceStore{TxStore: api.txStore, err: store.ErrNotFound})
+	_, err = api.getSentValue(*sender, time.Now().Add(-time.Hour))
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
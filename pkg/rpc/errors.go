@@ -0,0 +1,106 @@
+package rpc
+
+import (
+	"fmt"
+
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	qcommon "github.com/qtumproject/qtool/lib/common"
+)
+
+const (
+	// errCodeReverted is the JSON-RPC error code for reverted contract executions
+	errCodeReverted = 3
+	// errCodeExecution is the JSON-RPC error code for failed contract executions
+	errCodeExecution = -32000
+	// errCodeUnauthorized is the JSON-RPC error code for transactions rejected
+	// by the authorization check (EIP-1474 "transaction rejected")
+	errCodeUnauthorized = -32003
+)
+
+var (
+	// errNonceTooLow is returned when the nonce of a transaction has already been used
+	errNonceTooLow = errors.New("nonce too low")
+	// errNonceTooHigh is returned when the nonce of a transaction is ahead of the next nonce of its sender
+	errNonceTooHigh = errors.New("nonce too high")
+)
+
+// revertError is returned when a contract execution is reverted.
+// The revert data is returned as the JSON-RPC error data
+type revertError struct {
+	reason string
+	data   string
+}
+
+func (e *revertError) Error() string {
+	if e.reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.reason
+}
+
+// ErrorCode returns the JSON-RPC error code for a reverted execution
+func (e *revertError) ErrorCode() int {
+	return errCodeReverted
+}
+
+// ErrorData returns the hex encoded revert data
+func (e *revertError) ErrorData() interface{} {
+	return e.data
+}
+
+// executionError is returned when a contract execution fails
+// for a reason other than a revert (e.g. out of gas)
+type executionError struct {
+	excepted string
+	message  string
+}
+
+func (e *executionError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("execution error: %s", e.excepted)
+	}
+	return fmt.Sprintf("execution error: %s: %s", e.excepted, e.message)
+}
+
+// ErrorCode returns the JSON-RPC error code for a failed execution
+func (e *executionError) ErrorCode() int {
+	return errCodeExecution
+}
+
+// unauthorizedError is returned when a transaction is not authorized to be
+// signed with the wallet of its sender
+type unauthorizedError struct {
+	err error
+}
+
+func (e *unauthorizedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unauthorizedError) Unwrap() error {
+	return e.err
+}
+
+// ErrorCode returns the JSON-RPC error code for an unauthorized transaction
+func (e *unauthorizedError) ErrorCode() int {
+	return errCodeUnauthorized
+}
+
+// newExecutionError returns the error for an excepted qtum contract execution
+func newExecutionError(result *qtypes.ExecutionResult) error {
+	if result.Excepted != qtypes.ExceptedRevert {
+		return &executionError{excepted: result.Excepted, message: result.ExceptedMessage}
+	}
+	data := common.FromHex(result.Output)
+	reason, err := abi.UnpackRevert(data)
+	if err != nil {
+		reason = result.ExceptedMessage
+	}
+	return &revertError{
+		reason: reason,
+		data:   qcommon.AddHexPrefix(result.Output),
+	}
+}
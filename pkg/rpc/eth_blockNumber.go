@@ -0,0 +1,22 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+// BlockNumber implements the eth_blockNumber JSON-RPC call.
+//
+// Returns the height of the most recent qtum block.
+//
+// No params are required.
+func (api *EthAPI) BlockNumber() (hexutil.Uint64, error) {
+	height, err := api.qcli.GetBlockCount()
+	if err != nil {
+		log.With("method", "blockNumber").Debugf(err.Error())
+		return 0, errors.Wrap(err, "Error getting block count")
+	}
+	log.With("method", "blockNumber").Debugf("BlockNumber called. Returning %d", height)
+	return hexutil.Uint64(height), nil
+}
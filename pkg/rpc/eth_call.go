@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"encoding/hex"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// Call implements the eth_call JSON-RPC call.
+//
+// Executes a new message call immediately without creating a transaction
+// on the blockchain, using the qtum node's callcontract.
+//
+// Returns the hex encoded output of the execution. Reverted executions
+// are returned as JSON-RPC errors with the revert data.
+//
+// Qtum executes calls against the latest state, so the block parameter is ignored.
+func (api *EthAPI) Call(req rpctypes.Eth_CallRequest, blockNumber *rpc.BlockNumberOrHash) (string, error) {
+	log.With("method", "call").Debugf("Call called with req: %+v, blockNumber: %v", req, blockNumber)
+
+	if req.To == nil {
+		return "", errors.New("contract address (to) is required")
+	}
+	result, err := (*API)(api).callContract(newEthTxFromCallRequest(&req), req.From)
+	if err != nil {
+		log.With("method", "call").Debugf(err.Error())
+		return "", err
+	}
+	if result.ExecutionResult.Failed() {
+		log.With("method", "call").Debugf("Contract execution excepted: %s", result.ExecutionResult.Excepted)
+		return "", newExecutionError(&result.ExecutionResult)
+	}
+
+	output := hexutil.Encode(common.FromHex(result.ExecutionResult.Output))
+	log.With("method", "call").Debugf("Contract call output: %s", output)
+	return output, nil
+}
+
+// callContract executes the contract call described by tx on the qtum node.
+// The gas limit and value are only sent to the node along with a sender.
+func (api *API) callContract(tx *ethTx, from *common.Address) (*qtypes.CallContractResult, error) {
+	contract := hex.EncodeToString(tx.to.Bytes())
+	data := hex.EncodeToString(tx.data)
+
+	var sender string
+	var gasLimit uint64
+	var amount float64
+	if from != nil {
+		var err error
+		sender, err = api.getQtumSender(*from)
+		if err != nil {
+			return nil, err
+		}
+		gasLimit = tx.gas
+		amount, err = tx.amount()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := api.qcli.CallContract(contract, data, sender, gasLimit, amount)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error calling contract: %s", tx.to.String())
+	}
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCall(t *testing.T) {
+	mockQcli := mocks.NewMockQCli()
+	rpcservice, err := getETHRPCService(cfg, mockQcli)
+	utils.HandleFatalError(t, err)
+	testserver := httptest.NewServer(rpcservice)
+	defer testserver.Close()
+
+	// balanceOf(address) call
+	const callParams = `{"from":"0x96216849c49358B10257cb55b28eA603c874b05E","to":"0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb","data":"0x70a0823100000000000000000000000096216849c49358b10257cb55b28ea603c874b05e"}`
+
+	// revert data of Error("not allowed")
+	const revertOutput = "08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000b6e6f7420616c6c6f776564000000000000000000000000000000000000000000"
+
+	tests := []struct {
+		name        string
+		result      qtypes.ExecutionResult
+		want        string
+		wantErrCode int
+		wantErrData string
+	}{
+		{
+			name: "successful call",
+			result: qtypes.ExecutionResult{
+				Excepted: qtypes.ExceptedNone,
+				Output:   "0000000000000000000000000000000000000000000000000000000000000064",
+			},
+			want: "0x0000000000000000000000000000000000000000000000000000000000000064",
+		},
+		{
+			name: "reverted call",
+			result: qtypes.ExecutionResult{
+				Excepted: qtypes.ExceptedRevert,
+				Output:   revertOutput,
+			},
+			wantErrCode: errCodeReverted,
+			wantErrData: "0x" + revertOutput,
+		},
+		{
+			name: "out of gas",
+			result: qtypes.ExecutionResult{
+				Excepted: "OutOfGas",
+			},
+			wantErrCode: errCodeExecution,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQcli.CallContractResult = &qtypes.CallContractResult{ExecutionResult: tt.result}
+
+			jsonReq, err := utils.CreateJSONRequest("eth_call", callParams, `"latest"`)
+			utils.HandleFatalError(t, err)
+			resp, err := http.Post(testserver.URL, "application/json", bytes.NewReader(jsonReq))
+			utils.HandleFatalError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			utils.HandleFatalError(t, err)
+
+			var jsonResp utils.JSONRPCResponse
+			err = json.Unmarshal(body, &jsonResp)
+			utils.HandleFatalError(t, err)
+
+			if tt.wantErrCode != 0 {
+				if assert.NotNil(t, jsonResp.Error) {
+					assert.Equal(t, tt.wantErrCode, jsonResp.Error.Code)
+					assert.Equal(t, tt.wantErrData, jsonResp.Error.Data)
+				}
+				return
+			}
+			assert.Nil(t, jsonResp.Error)
+			var got string
+			err = json.Unmarshal(jsonResp.Result, &got)
+			utils.HandleFatalError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRevertErrorReason(t *testing.T) {
+	err := newExecutionError(&qtypes.ExecutionResult{
+		Excepted: qtypes.ExceptedRevert,
+		Output:   "08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000b6e6f7420616c6c6f776564000000000000000000000000000000000000000000",
+	})
+	assert.Equal(t, "execution reverted: not allowed", err.Error())
+}
@@ -0,0 +1,39 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+// Chain ids published for the qtum networks
+const (
+	MainnetChainID = 81
+	TestnetChainID = 8889
+	RegtestChainID = 8890
+)
+
+// ChainId implements the eth_chainId JSON-RPC call.
+//
+// Returns the chain id used to sign EIP-155 transactions,
+// which is set from the configured network.
+//
+// No params are required.
+func (api *EthAPI) ChainId() (*hexutil.Big, error) {
+	log.With("method", "chainId").Debugf("ChainId called. Returning %v", api.chainID)
+	return (*hexutil.Big)(api.chainID), nil
+}
+
+// getChainID returns the chain id of the given network
+func getChainID(network string) (uint64, error) {
+	switch network {
+	case "mainnet":
+		return MainnetChainID, nil
+	case "testnet":
+		return TestnetChainID, nil
+	case "regtest":
+		return RegtestChainID, nil
+	default:
+		return 0, errors.New("Invalid network: " + network)
+	}
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainId(t *testing.T) {
+	api := NewAPI(context.Background(), mocks.NewMockQCli())
+	ethAPI := (*EthAPI)(api)
+	netAPI := (*NetAPI)(api)
+
+	chainID, err := ethAPI.ChainId()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "0x22ba", chainID.String())
+	version, err := netAPI.Version()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "8890", version)
+
+	api.SetChainID(MainnetChainID)
+	chainID, err = ethAPI.ChainId()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "0x51", chainID.String())
+	version, err = netAPI.Version()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "81", version)
+}
+
+func TestSendRawTxChainId(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	api := NewAPI(context.Background(), mocks.NewMockQCli())
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	sendTx := func(signer types.Signer) error {
+		signedTx, err := signEthereumTx(newEthereumTx(), signer, PRIVATEKEY)
+		utils.HandleFatalError(t, err)
+		rawTx, err := signedTx.MarshalBinary()
+		utils.HandleFatalError(t, err)
+		_, err = ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+		return err
+	}
+
+	// txs signed for ethereum mainnet are rejected
+	err := sendTx(types.NewEIP155Signer(big.NewInt(1)))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid chain id")
+	}
+	assert.NoError(t, sendTx(types.NewEIP155Signer(big.NewInt(RegtestChainID))))
+}
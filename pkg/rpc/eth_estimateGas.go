@@ -0,0 +1,156 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/qtum"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// utxoGasOverhead is the gas added to every estimate for the size of the UTXO side of the
+// qtum transaction (inputs, change and contract outputs), i.e. the fee of a transfer tx at
+// the minimum fee rate expressed in units of gas at the minimum gas price
+const utxoGasOverhead = uint64(qtum.TransferTxSize) * uint64(qtum.DefaultMinFeeRate) / 1000 / qtypes.MinGasPrice
+
+// estimateGasErrorRatio is the ratio between the gas limit found by estimateCallGas and
+// the lowest gas limit the call succeeds with, below which the search stops
+const estimateGasErrorRatio = 0.015
+
+// defaultCreateGasLimit is the default gas limit of the qtum node createcontract,
+// the lowest estimate of contract creations
+const defaultCreateGasLimit = 2500000
+
+// EstimateGas implements the eth_estimateGas JSON-RPC call.
+//
+// Returns the gas needed by the qtum transaction the request would be translated to
+// by eth_sendRawTransaction:
+//   - value transfers: the ethereum transfer cost
+//   - contract calls: the lowest gas limit the call simulated with the qtum node's callcontract
+//     succeeds with (see estimateCallGas)
+//   - contract creations: qtum can not simulate contract creations, so the execution of the
+//     constructor is unknown. As the unused gas of contract executions is refunded, creations
+//     get the default gas limit of the qtum node createcontract, or the cost of deploying the
+//     bytecode plus a margin for the constructor if higher (see createGas)
+//
+// A fixed overhead for the UTXO side of the transaction is added to every estimate.
+// Contract estimates are never lower than the qtum minimum gas limit nor higher than
+// the block gas limit.
+func (api *EthAPI) EstimateGas(req rpctypes.Eth_CallRequest, blockNumber *rpc.BlockNumberOrHash) (string, error) {
+	log.With("method", "estimategas").Debugf("EstimateGas called with req: %+v, blockNumber: %v", req, blockNumber)
+
+	tx := newEthTxFromCallRequest(&req)
+	var gas uint64
+	switch {
+	case !tx.isContract():
+		gas = params.TxGas
+	case tx.to == nil:
+		gas = createGas(tx.data)
+	default:
+		var err error
+		gas, err = (*API)(api).estimateCallGas(tx, req.From)
+		if err != nil {
+			log.With("method", "estimategas").Debugf(err.Error())
+			return "", err
+		}
+	}
+
+	if tx.isContract() && gas < qtypes.MinGasLimit {
+		gas = qtypes.MinGasLimit
+	}
+	gas += utxoGasOverhead
+	if tx.isContract() && gas > qtypes.MaxGasLimit {
+		gas = qtypes.MaxGasLimit
+	}
+	log.With("method", "estimategas").Debugf("Estimated gas: %d", gas)
+	return hexutil.EncodeUint64(gas), nil
+}
+
+// createGas returns the gas limit of a creation of the given bytecode: the default gas limit
+// of createcontract, unless deploying the bytecode needs more. The cost of the deployment
+// follows the ethereum gas schedule (the intrinsic cost of a creation tx plus the code
+// deposit cost), with a 50% margin for the constructor
+func createGas(bytecode []byte) uint64 {
+	gas := params.TxGasContractCreation
+	for _, b := range bytecode {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += params.TxDataNonZeroGasEIP2028
+		}
+	}
+	gas += uint64(len(bytecode)) * params.CreateDataGas
+	gas += gas / 2
+	if gas < defaultCreateGasLimit {
+		return defaultCreateGasLimit
+	}
+	return gas
+}
+
+// estimateCallGas returns the lowest gas limit the contract call succeeds with. The gas used
+// by the call is not enough when it gets refunds or forwards 63/64 of its gas to sub-calls,
+// so like geth the gas limit is searched between the gas used and the gas limit of the call
+// (the block gas limit by default), until it is within estimateGasErrorRatio of the lowest one.
+// The call is simulated with the zero address as sender if it has none, as the node only
+// applies the gas limit to calls with a sender
+func (api *API) estimateCallGas(tx *ethTx, from *common.Address) (uint64, error) {
+	if from == nil {
+		from = &common.Address{}
+	}
+	execute := func(gas uint64) (*qtypes.ExecutionResult, error) {
+		call := *tx
+		call.gas = gas
+		result, err := api.callContract(&call, from)
+		if err != nil {
+			return nil, err
+		}
+		return &result.ExecutionResult, nil
+	}
+
+	hi := tx.gas
+	if hi == 0 || hi > qtypes.MaxGasLimit {
+		hi = qtypes.MaxGasLimit
+	}
+	result, err := execute(hi)
+	if err != nil {
+		return 0, err
+	}
+	if result.Failed() {
+		log.With("method", "estimategas").Debugf("Contract execution excepted: %s", result.Excepted)
+		return 0, newExecutionError(result)
+	}
+	lo := uint64(0)
+	if result.GasUsed > 0 {
+		lo = result.GasUsed - 1
+	}
+
+	// most calls succeed with the gas used plus the refunds and the gas kept by the sub-calls
+	optimistic := (result.GasUsed + result.GasRefunded + params.CallStipend) * 64 / 63
+	if optimistic < hi {
+		result, err = execute(optimistic)
+		if err != nil {
+			return 0, err
+		}
+		if result.Failed() {
+			lo = optimistic
+		} else {
+			hi = optimistic
+		}
+	}
+	for lo+1 < hi && float64(hi-lo)/float64(hi) > estimateGasErrorRatio {
+		mid := (lo + hi) / 2
+		result, err = execute(mid)
+		if err != nil {
+			return 0, err
+		}
+		if result.Failed() {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi, nil
+}
@@ -0,0 +1,135 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateGas(t *testing.T) {
+	mockQcli := mocks.NewMockQCli()
+	rpcservice, err := getETHRPCService(cfg, mockQcli)
+	utils.HandleFatalError(t, err)
+	testserver := httptest.NewServer(rpcservice)
+	defer testserver.Close()
+
+	tests := []struct {
+		name        string
+		params      string
+		result      qtypes.ExecutionResult
+		want        uint64
+		gasNeeded   uint64 // lowest gas limit the call succeeds with
+		atLeast     uint64 // gas actually needed by the execution, if known
+		wantErrCode int
+	}{
+		{
+			name:   "value transfer",
+			params: `{"from":"0x96216849c49358B10257cb55b28eA603c874b05E","to":"0x7926223070547D2D15b2eF5e7383E541c338FfE9","value":"0x2386f26fc10000"}`,
+			want:   21000 + utxoGasOverhead,
+		},
+		{
+			name:      "contract call",
+			params:    `{"from":"0x96216849c49358B10257cb55b28eA603c874b05E","to":"0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb","data":"0xa9059cbb"}`,
+			result:    qtypes.ExecutionResult{Excepted: qtypes.ExceptedNone, GasUsed: 51234},
+			gasNeeded: 51234,
+		},
+		{
+			// the gas used does not include the refund of the call
+			name:      "contract call with a refund",
+			params:    `{"from":"0x96216849c49358B10257cb55b28eA603c874b05E","to":"0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb","data":"0xa9059cbb"}`,
+			result:    qtypes.ExecutionResult{Excepted: qtypes.ExceptedNone, GasUsed: 51234, GasRefunded: 15000},
+			gasNeeded: 66234,
+		},
+		{
+			// the sub-calls keep 1/64 of the gas, which is not used
+			name:      "contract call needing more gas than the gas used",
+			params:    `{"to":"0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb","data":"0xa9059cbb"}`,
+			result:    qtypes.ExecutionResult{Excepted: qtypes.ExceptedNone, GasUsed: 51234},
+			gasNeeded: 300000,
+		},
+		{
+			name:      "contract call below the minimum gas limit",
+			params:    `{"to":"0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb","data":"0x06fdde03"}`,
+			result:    qtypes.ExecutionResult{Excepted: qtypes.ExceptedNone, GasUsed: 2300},
+			gasNeeded: 2300,
+			want:      qtypes.MinGasLimit + utxoGasOverhead,
+		},
+		{
+			name:   "contract creation",
+			params: `{"from":"0x96216849c49358B10257cb55b28eA603c874b05E","data":"0x6080600000"}`,
+			want:   defaultCreateGasLimit + utxoGasOverhead,
+		},
+		{
+			name: "contract creation with a constructor writing storage",
+			// constructor storing 1 in the slots 0 to 4, which needs 53388 gas of intrinsic
+			// cost plus 110530 gas of execution (5 SSTOREs of new slots), as run by geth
+			params:  `{"from":"0x96216849c49358B10257cb55b28eA603c874b05E","data":"0x60016000556001600155600160025560016003556001600455"}`,
+			want:    defaultCreateGasLimit + utxoGasOverhead,
+			atLeast: 53388 + 110530,
+		},
+		{
+			name:   "contract creation of a large bytecode",
+			params: `{"from":"0x96216849c49358B10257cb55b28eA603c874b05E","data":"0x` + strings.Repeat("60", 20000) + `"}`,
+			// (53000 + 20000 non zero bytes * 16 + 20000 bytes * 200) * 1.5
+			want: 6559500 + utxoGasOverhead,
+		},
+		{
+			name:   "contract creation above the block gas limit",
+			params: `{"from":"0x96216849c49358B10257cb55b28eA603c874b05E","data":"0x` + strings.Repeat("60", 200000) + `"}`,
+			want:   qtypes.MaxGasLimit,
+		},
+		{
+			name:        "reverted call",
+			params:      `{"to":"0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb","data":"0xa9059cbb"}`,
+			result:      qtypes.ExecutionResult{Excepted: qtypes.ExceptedRevert},
+			wantErrCode: errCodeReverted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQcli.CallContractResult = &qtypes.CallContractResult{ExecutionResult: tt.result}
+			mockQcli.CallContractGasNeeded = tt.gasNeeded
+
+			jsonReq, err := utils.CreateJSONRequest("eth_estimateGas", tt.params)
+			utils.HandleFatalError(t, err)
+			resp, err := http.Post(testserver.URL, "application/json", bytes.NewReader(jsonReq))
+			utils.HandleFatalError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			utils.HandleFatalError(t, err)
+
+			var jsonResp utils.JSONRPCResponse
+			err = json.Unmarshal(body, &jsonResp)
+			utils.HandleFatalError(t, err)
+
+			if tt.wantErrCode != 0 {
+				if assert.NotNil(t, jsonResp.Error) {
+					assert.Equal(t, tt.wantErrCode, jsonResp.Error.Code)
+				}
+				return
+			}
+			assert.Nil(t, jsonResp.Error)
+			var got hexutil.Uint64
+			err = json.Unmarshal(jsonResp.Result, &got)
+			utils.HandleFatalError(t, err)
+			if tt.want == 0 {
+				// the search stops within the error ratio of the gas needed
+				assert.GreaterOrEqual(t, uint64(got), tt.gasNeeded+utxoGasOverhead)
+				assert.LessOrEqual(t, float64(uint64(got)-utxoGasOverhead), float64(tt.gasNeeded)*(1+estimateGasErrorRatio))
+				return
+			}
+			assert.Equal(t, tt.want, uint64(got))
+			assert.GreaterOrEqual(t, uint64(got), tt.atLeast)
+		})
+	}
+}
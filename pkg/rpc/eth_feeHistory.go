@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"math/big"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// maxFeeHistory is the maximum number of blocks returned by eth_feeHistory
+const maxFeeHistory = 1024
+
+// FeeHistory implements the eth_feeHistory JSON-RPC call.
+//
+// Returns the fee history of the blockCount blocks up to lastBlock.
+//
+// Qtum has neither a base fee nor priority fees, so the base fee of every
+// block is the current gas price and the rewards at the requested
+// percentiles are zero. The gas used ratio of the blocks is not tracked
+// and is returned as zero.
+func (api *EthAPI) FeeHistory(blockCount rpc.DecimalOrHex, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*rpctypes.Eth_FeeHistoryResponse, error) {
+	log.With("method", "feeHistory").Debugf("FeeHistory called with blockCount: %d, lastBlock: %v, rewardPercentiles: %v", blockCount, lastBlock, rewardPercentiles)
+
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 {
+			return nil, errors.Errorf("invalid reward percentile: %f", p)
+		}
+		if i > 0 && p < rewardPercentiles[i-1] {
+			return nil, errors.Errorf("invalid reward percentile: #%d:%f > #%d:%f", i-1, rewardPercentiles[i-1], i, p)
+		}
+	}
+
+	head, err := api.qcli.GetBlockCount()
+	if err != nil {
+		log.With("method", "feeHistory").Debugf(err.Error())
+		return nil, errors.Wrap(err, "Error getting block count")
+	}
+	last := head
+	switch {
+	case lastBlock == rpc.EarliestBlockNumber:
+		last = 0
+	case lastBlock >= 0:
+		if int64(lastBlock) > head {
+			return nil, errors.Errorf("request beyond head block: requested %d, head %d", lastBlock, head)
+		}
+		last = int64(lastBlock)
+	}
+
+	count := int64(blockCount)
+	if count > maxFeeHistory {
+		count = maxFeeHistory
+	}
+	if count > last+1 {
+		count = last + 1
+	}
+	if count == 0 {
+		return &rpctypes.Eth_FeeHistoryResponse{OldestBlock: (*hexutil.Big)(new(big.Int))}, nil
+	}
+
+	gasPrice, err := (*API)(api).getGasPrice()
+	if err != nil {
+		log.With("method", "feeHistory").Debugf(err.Error())
+		return nil, err
+	}
+	response := &rpctypes.Eth_FeeHistoryResponse{
+		OldestBlock:  (*hexutil.Big)(big.NewInt(last - count + 1)),
+		GasUsedRatio: make([]float64, count),
+		// the base fee of the next block is included
+		BaseFee: make([]*hexutil.Big, count+1),
+	}
+	for i := range response.BaseFee {
+		response.BaseFee[i] = (*hexutil.Big)(gasPrice)
+	}
+	if len(rewardPercentiles) > 0 {
+		response.Reward = make([][]*hexutil.Big, count)
+		for i := range response.Reward {
+			response.Reward[i] = make([]*hexutil.Big, len(rewardPercentiles))
+			for j := range response.Reward[i] {
+				response.Reward[i][j] = (*hexutil.Big)(new(big.Int))
+			}
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,55 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeHistory(t *testing.T) {
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	ethAPI := (*EthAPI)(api)
+
+	gasPrice, err := ethAPI.GasPrice()
+	utils.HandleFatalError(t, err)
+
+	got, err := ethAPI.FeeHistory(4, rpc.LatestBlockNumber, []float64{25, 75})
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, int64(1231), got.OldestBlock.ToInt().Int64())
+	assert.Len(t, got.GasUsedRatio, 4)
+	if assert.Len(t, got.BaseFee, 5) {
+		for _, baseFee := range got.BaseFee {
+			assert.Equal(t, gasPrice, baseFee)
+		}
+	}
+	if assert.Len(t, got.Reward, 4) {
+		for _, reward := range got.Reward {
+			assert.Len(t, reward, 2)
+			assert.Zero(t, reward[0].ToInt().Sign())
+		}
+	}
+
+	// the history is limited by the first block
+	got, err = ethAPI.FeeHistory(10, 2, nil)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, int64(0), got.OldestBlock.ToInt().Int64())
+	assert.Len(t, got.BaseFee, 4)
+	assert.Nil(t, got.Reward)
+
+	_, err = ethAPI.FeeHistory(4, 2000, nil)
+	assert.Error(t, err)
+	_, err = ethAPI.FeeHistory(4, rpc.LatestBlockNumber, []float64{75, 25})
+	assert.Error(t, err)
+}
+
+func TestMaxPriorityFeePerGas(t *testing.T) {
+	ethAPI := (*EthAPI)(NewAPI(context.Background(), mocks.NewMockQCli()))
+	got, err := ethAPI.MaxPriorityFeePerGas()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "0x0", got.String())
+}
@@ -0,0 +1,185 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// filterTimeout is the time a filter is kept after it was last polled
+const filterTimeout = 5 * time.Minute
+
+var errFilterNotFound = errors.New("filter not found")
+
+// logFilter holds the criteria of a log filter and the
+// next block height to search on eth_getFilterChanges
+type logFilter struct {
+	criteria rpctypes.Eth_FilterRequest
+	next     int64
+	lastPoll time.Time
+}
+
+// filterManager holds the installed filters. Filters not polled
+// within filterTimeout are removed on the next filter call
+type filterManager struct {
+	mu      sync.Mutex
+	filters map[rpc.ID]*logFilter
+}
+
+func newFilterManager() *filterManager {
+	return &filterManager{filters: make(map[rpc.ID]*logFilter)}
+}
+
+// expire removes the filters not polled within filterTimeout. Must be called with mu held
+func (fm *filterManager) expire(now time.Time) {
+	for id, filter := range fm.filters {
+		if now.Sub(filter.lastPoll) > filterTimeout {
+			log.With("module", "filters").Debugf("Filter %s expired", id)
+			delete(fm.filters, id)
+		}
+	}
+}
+
+// get returns the filter with the given id and marks it as polled. Must be called with mu held
+func (fm *filterManager) get(id rpc.ID) (*logFilter, error) {
+	now := time.Now()
+	fm.expire(now)
+	filter, ok := fm.filters[id]
+	if !ok {
+		return nil, errFilterNotFound
+	}
+	filter.lastPoll = now
+	return filter, nil
+}
+
+// NewFilter implements the eth_newFilter JSON-RPC call.
+//
+// Installs a log filter and returns its id. The logs of the blocks mined after
+// the filter is created, or after its fromBlock if it is a block number, are
+// returned by eth_getFilterChanges. The filter is removed if it is not polled
+// for 5 minutes.
+func (api *EthAPI) NewFilter(req rpctypes.Eth_FilterRequest) (rpc.ID, error) {
+	log.With("method", "newfilter").Debugf("NewFilter called with filter: %+v", req)
+
+	if req.BlockHash != nil {
+		return "", errors.New("filters by block hash are not supported")
+	}
+	head, err := api.qcli.GetBlockCount()
+	if err != nil {
+		log.With("method", "newfilter").Debugf(err.Error())
+		return "", errors.Wrap(err, "Error getting block count")
+	}
+	next := head + 1
+	if req.FromBlock != nil && *req.FromBlock >= 0 {
+		next = req.FromBlock.Int64()
+	}
+
+	id := rpc.NewID()
+	now := time.Now()
+	api.filters.mu.Lock()
+	defer api.filters.mu.Unlock()
+	api.filters.expire(now)
+	api.filters.filters[id] = &logFilter{criteria: req, next: next, lastPoll: now}
+	log.With("method", "newfilter").Debugf("Installed filter %s from block %d", id, next)
+	return id, nil
+}
+
+// GetFilterChanges implements the eth_getFilterChanges JSON-RPC call.
+//
+// Returns the logs matching the filter in the blocks mined since it was last polled.
+func (api *EthAPI) GetFilterChanges(id rpc.ID) ([]*rpctypes.Eth_Log, error) {
+	log.With("method", "getfilterchanges").Debugf("GetFilterChanges called with id: %s", id)
+
+	// the filter state is copied so the node is queried without holding the lock
+	api.filters.mu.Lock()
+	filter, err := api.filters.get(id)
+	if err != nil {
+		api.filters.mu.Unlock()
+		return nil, err
+	}
+	criteria, from := filter.criteria, filter.next
+	api.filters.mu.Unlock()
+
+	head, err := api.qcli.GetBlockCount()
+	if err != nil {
+		log.With("method", "getfilterchanges").Debugf(err.Error())
+		return nil, errors.Wrap(err, "Error getting block count")
+	}
+	to := head
+	if criteria.ToBlock != nil && *criteria.ToBlock >= 0 && criteria.ToBlock.Int64() < to {
+		to = criteria.ToBlock.Int64()
+	}
+	if from > to {
+		return []*rpctypes.Eth_Log{}, nil
+	}
+	logs, err := (*API)(api).searchLogs(&criteria, from, to)
+	if err != nil {
+		log.With("method", "getfilterchanges").Debugf(err.Error())
+		return nil, err
+	}
+
+	api.filters.mu.Lock()
+	defer api.filters.mu.Unlock()
+	filter, ok := api.filters.filters[id]
+	if !ok {
+		return nil, errFilterNotFound
+	}
+	if filter.next > from {
+		// the logs up to the next block were returned by a concurrent poll
+		logs = logsFrom(logs, filter.next)
+	}
+	if to+1 > filter.next {
+		filter.next = to + 1
+	}
+	log.With("method", "getfilterchanges").Debugf("Found %d logs for filter %s", len(logs), id)
+	return logs, nil
+}
+
+// logsFrom returns the logs mined at or after the block height
+func logsFrom(logs []*rpctypes.Eth_Log, height int64) []*rpctypes.Eth_Log {
+	filtered := []*rpctypes.Eth_Log{}
+	for _, ethLog := range logs {
+		if ethLog.BlockNumber.ToInt().Int64() >= height {
+			filtered = append(filtered, ethLog)
+		}
+	}
+	return filtered
+}
+
+// GetFilterLogs implements the eth_getFilterLogs JSON-RPC call.
+//
+// Returns all the logs matching the filter, as eth_getLogs would.
+func (api *EthAPI) GetFilterLogs(id rpc.ID) ([]*rpctypes.Eth_Log, error) {
+	log.With("method", "getfilterlogs").Debugf("GetFilterLogs called with id: %s", id)
+
+	api.filters.mu.Lock()
+	filter, err := api.filters.get(id)
+	if err != nil {
+		api.filters.mu.Unlock()
+		return nil, err
+	}
+	criteria := filter.criteria
+	api.filters.mu.Unlock()
+
+	return api.GetLogs(criteria)
+}
+
+// UninstallFilter implements the eth_uninstallFilter JSON-RPC call.
+//
+// Removes the filter. Returns false if the filter was not found.
+func (api *EthAPI) UninstallFilter(id rpc.ID) bool {
+	log.With("method", "uninstallfilter").Debugf("UninstallFilter called with id: %s", id)
+
+	api.filters.mu.Lock()
+	defer api.filters.mu.Unlock()
+	api.filters.expire(time.Now())
+	if _, ok := api.filters.filters[id]; !ok {
+		return false
+	}
+	delete(api.filters.filters, id)
+	return true
+}
@@ -1,14 +1,83 @@
 package rpc
 
-import "github.com/alejoacosta74/qproxy/pkg/log"
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/qtum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/pkg/errors"
+	qcommon "github.com/qtumproject/qtool/lib/common"
+)
+
+// DefaultGasPriceCacheTTL is the default time the gas price is cached
+// before asking the qtum node again (about one qtum block)
+const DefaultGasPriceCacheTTL = 30 * time.Second
+
+// gasPriceCache holds the last gas price calculated, the part of it
+// paying for the fee of the utxo transaction, and when they expire
+type gasPriceCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	price   *big.Int
+	utxoFee *big.Int
+	expires time.Time
+}
 
 // GasPrice implements the eth_GasPrice JSON-RPC call.
 //
 // Returns the current price per gas in wei.
 //
 // No params are required.
-func (api *EthAPI) GasPrice() (string, error) {
-	// TODO: implement
-	log.With("method", "gasPrice").Debugf("GasPrice called. Returning hardwired 0x9184e72a000")
-	return "0x9184e72a000", nil
+func (api *EthAPI) GasPrice() (*hexutil.Big, error) {
+	gasPrice, err := (*API)(api).getGasPrice()
+	if err != nil {
+		log.With("method", "gasPrice").Debugf(err.Error())
+		return nil, err
+	}
+	log.With("method", "gasPrice").Debugf("GasPrice called. Returning %v wei", gasPrice)
+	return (*hexutil.Big)(gasPrice), nil
+}
+
+// getGasPrice returns the gas price in wei advertised to ethereum clients,
+// cached for the configured interval (see getGasPrices)
+func (api *API) getGasPrice() (*big.Int, error) {
+	price, _, err := api.getGasPrices()
+	return price, err
+}
+
+// getGasPrices returns the gas price in wei advertised to ethereum clients and the
+// part of it paying for the fee of the utxo transaction, cached for the configured interval.
+//
+// The gas price is the qtum minimum gas price set by the DGP plus the fee paid
+// for the utxo transaction spread over the gas of a transfer, so a transfer
+// costs about the same in eth as the fee of its qtum transaction. The utxo fee
+// is not part of the gas price of the qtum contracts (see contractGasPrice)
+func (api *API) getGasPrices() (*big.Int, *big.Int, error) {
+	api.gasPrice.mu.Lock()
+	defer api.gasPrice.mu.Unlock()
+	if api.gasPrice.price != nil && time.Now().Before(api.gasPrice.expires) {
+		return new(big.Int).Set(api.gasPrice.price), new(big.Int).Set(api.gasPrice.utxoFee), nil
+	}
+
+	dgpInfo, err := api.qcli.GetDGPInfo()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Error getting min gas price")
+	}
+	feeRate, err := api.qcli.EstimateFeeRate()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Error estimating fee rate")
+	}
+	txFee := uint64(feeRate) * qtum.TransferTxSize / 1000
+	feePerGas := (txFee + params.TxGas - 1) / params.TxGas
+	satoshis := dgpInfo.MinGasPrice + feePerGas
+	log.With("module", "gasprice").Debugf("Gas price: %d satoshis (min gas price: %d, fee rate: %v per kB)", satoshis, dgpInfo.MinGasPrice, feeRate)
+
+	api.gasPrice.price = qcommon.ConvertFromSatoshiToWei(new(big.Int).SetUint64(satoshis))
+	api.gasPrice.utxoFee = qcommon.ConvertFromSatoshiToWei(new(big.Int).SetUint64(feePerGas))
+	api.gasPrice.expires = time.Now().Add(api.gasPrice.ttl)
+	return new(big.Int).Set(api.gasPrice.price), new(big.Int).Set(api.gasPrice.utxoFee), nil
 }
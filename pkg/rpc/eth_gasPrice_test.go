@@ -0,0 +1,41 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGasPrice(t *testing.T) {
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	ethAPI := (*EthAPI)(api)
+
+	// 40 satoshis min gas price plus the fee of a transfer tx (227 bytes at
+	// 400000 satoshis per kB = 90800 satoshis) spread over 21000 gas (5 satoshis)
+	got, err := ethAPI.GasPrice()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "0x68c6171400", got.String())
+	assert.Equal(t, int64(450000000000), got.ToInt().Int64())
+
+	// the utxo fee is kept apart, so it is not paid again by the contracts
+	_, utxoFee, err := api.getGasPrices()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, int64(50000000000), utxoFee.Int64())
+
+	// the gas price is cached
+	mockQcli.DGPInfoResult = &qtypes.DGPInfo{MinGasPrice: 100}
+	got, err = ethAPI.GasPrice()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, int64(450000000000), got.ToInt().Int64())
+
+	// until it expires
+	api.SetGasPriceCacheTTL(0)
+	got, err = ethAPI.GasPrice()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, int64(1050000000000), got.ToInt().Int64())
+}
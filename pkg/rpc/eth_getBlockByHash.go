@@ -0,0 +1,178 @@
+package rpc
+
+import (
+	"math/big"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/alejoacosta74/qproxy/pkg/store"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/chaincfg/chainhash"
+	qcommon "github.com/qtumproject/qtool/lib/common"
+)
+
+// GetBlockByHash implements the eth_getBlockByHash JSON-RPC call.
+//
+// Returns the qtum block with the given hash in the ethereum block format.
+// If fullTx is true the transactions are returned as objects, otherwise
+// as hashes. The transactions sent through the proxy are listed with
+// their ethereum hash.
+//
+// Returns null if the block is unknown.
+func (api *EthAPI) GetBlockByHash(hash common.Hash, fullTx bool) (*rpctypes.Eth_BlockResponse, error) {
+	log.With("method", "getblockbyhash").Debugf("GetBlockByHash called with hash: %s, fullTx: %t", hash.String(), fullTx)
+
+	blockHash, err := chainhash.NewHashFromStr(qcommon.RemoveHexPrefix(hash.String()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid block hash: %s", hash.String())
+	}
+	block, err := api.qcli.GetBlockVerbose(blockHash)
+	if isBlockNotFoundError(err) {
+		log.With("method", "getblockbyhash").Debugf("Block %s not found", hash.String())
+		return nil, nil
+	}
+	if err != nil {
+		log.With("method", "getblockbyhash").Debugf(err.Error())
+		return nil, errors.Wrapf(err, "Error getting block %s", hash.String())
+	}
+	return (*API)(api).newBlockResponse(block, fullTx)
+}
+
+// newBlockResponse returns the qtum block in the ethereum block format.
+//
+// The fields without a qtum counterpart (mix hash, uncles, state and receipts
+// roots, extra data) are set to the values of an ethereum block without them.
+// The gas used and total difficulty are not tracked and are set to zero
+func (api *API) newBlockResponse(block *btcjson.GetBlockVerboseResult, fullTx bool) (*rpctypes.Eth_BlockResponse, error) {
+	dgpInfo, err := api.qcli.GetDGPInfo()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error getting block gas limit")
+	}
+	parentHash := common.Hash{}.String()
+	if block.PreviousHash != "" {
+		parentHash = qcommon.AddHexPrefix(block.PreviousHash)
+	}
+	difficulty, _ := new(big.Float).SetFloat64(block.Difficulty).Int(nil)
+	nonce := types.EncodeNonce(uint64(block.Nonce))
+
+	response := &rpctypes.Eth_BlockResponse{
+		Eth_BlockHeader: rpctypes.Eth_BlockHeader{
+			Number:           hexutil.EncodeUint64(uint64(block.Height)),
+			Hash:             qcommon.AddHexPrefix(block.Hash),
+			ParentHash:       parentHash,
+			Nonce:            hexutil.Encode(nonce[:]),
+			MixHash:          common.Hash{}.String(),
+			Sha3Uncles:       types.EmptyUncleHash.String(),
+			LogsBloom:        hexutil.Encode(types.Bloom{}.Bytes()),
+			StateRoot:        common.Hash{}.String(),
+			Miner:            api.getBlockMiner(block),
+			Difficulty:       hexutil.EncodeBig(difficulty),
+			TotalDifficulty:  "0x0",
+			ExtraData:        "0x",
+			Size:             hexutil.EncodeUint64(uint64(block.Size)),
+			GasLimit:         hexutil.EncodeUint64(dgpInfo.BlockGasLimit),
+			GasUsed:          "0x0",
+			Timestamp:        hexutil.EncodeUint64(uint64(block.Time)),
+			TransactionsRoot: qcommon.AddHexPrefix(block.MerkleRoot),
+			ReceiptsRoot:     types.EmptyRootHash.String(),
+		},
+		Transactions: make([]interface{}, 0, len(block.Tx)),
+		Uncles:       []string{},
+	}
+
+	for i, txid := range block.Tx {
+		signedTx, err := api.getSignedTxByQtumTxID(txid)
+		if err != nil {
+			return nil, err
+		}
+		if !fullTx {
+			hash := qcommon.AddHexPrefix(txid)
+			if signedTx != nil {
+				hash = signedTx.Hash().String()
+			}
+			response.Transactions = append(response.Transactions, hash)
+			continue
+		}
+		var qtumTx *btcjson.TxRawResult
+		if signedTx == nil {
+			qtumTx, err = api.getQtumTx(txid)
+			if err != nil {
+				return nil, err
+			}
+		}
+		tx, err := api.getTransactionResponse(qtumTx, signedTx)
+		if err != nil {
+			return nil, err
+		}
+		setTransactionBlock(tx, block, i)
+		response.Transactions = append(response.Transactions, tx)
+	}
+	return response, nil
+}
+
+// getSignedTxByQtumTxID returns the signed ethereum transaction translated into
+// the qtum transaction with the given txid, or nil if it was not sent through the proxy
+func (api *API) getSignedTxByQtumTxID(txid string) (*types.Transaction, error) {
+	record, err := api.txStore.GetTxByQtumTxID(txid)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error getting record of qtum tx %s", txid)
+	}
+	signedTx, err := decodeRawTx(record.EthRawTx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error decoding eth raw tx of record %s", record.EthHash)
+	}
+	return signedTx, nil
+}
+
+// getBlockMiner returns the hex address of the staker of a proof of stake block,
+// paid by the second output of the coinstake transaction, or of the miner of a
+// proof of work block, paid by the coinbase transaction. The zero address is
+// returned if it can not be determined
+func (api *API) getBlockMiner(block *btcjson.GetBlockVerboseResult) string {
+	if len(block.Tx) > 1 {
+		coinstake, err := api.getQtumTx(block.Tx[1])
+		if err != nil {
+			log.With("module", "block").Debugf("Error getting coinstake of block %s: %v", block.Hash, err)
+		} else if isCoinStake(coinstake) {
+			if address, ok := scriptPubKeyHexAddress(&coinstake.Vout[1].ScriptPubKey, api.cfg); ok {
+				return address
+			}
+		}
+	}
+	if len(block.Tx) > 0 {
+		coinbase, err := api.getQtumTx(block.Tx[0])
+		if err != nil {
+			log.With("module", "block").Debugf("Error getting coinbase of block %s: %v", block.Hash, err)
+			return common.Address{}.String()
+		}
+		for _, vout := range coinbase.Vout {
+			if address, ok := scriptPubKeyHexAddress(&vout.ScriptPubKey, api.cfg); ok {
+				return address
+			}
+		}
+	}
+	return common.Address{}.String()
+}
+
+// isCoinStake returns true if the transaction is a coinstake,
+// i.e. its first output is empty and it has a staker output
+func isCoinStake(tx *btcjson.TxRawResult) bool {
+	if len(tx.Vin) == 0 || tx.Vin[0].IsCoinBase() || len(tx.Vout) < 2 {
+		return false
+	}
+	return tx.Vout[0].Value == 0 && tx.Vout[0].ScriptPubKey.Hex == ""
+}
+
+// isBlockNotFoundError returns true if the error is the qtum
+// node error returned when a block is unknown
+func isBlockNotFoundError(err error) bool {
+	var rpcErr *btcjson.RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCBlockNotFound
+}
@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcjson"
+)
+
+// GetBlockByNumber implements the eth_getBlockByNumber JSON-RPC call.
+//
+// Returns the qtum block at the given height in the ethereum block format.
+// The "latest" and "pending" tags return the most recent block.
+// If fullTx is true the transactions are returned as objects, otherwise
+// as hashes.
+//
+// Returns null if there is no block at the given height.
+func (api *EthAPI) GetBlockByNumber(number rpc.BlockNumber, fullTx bool) (*rpctypes.Eth_BlockResponse, error) {
+	log.With("method", "getblockbynumber").Debugf("GetBlockByNumber called with number: %v, fullTx: %t", number, fullTx)
+
+	height, err := (*API)(api).getBlockHeight(number)
+	if err != nil {
+		log.With("method", "getblockbynumber").Debugf(err.Error())
+		return nil, err
+	}
+	blockHash, err := api.qcli.GetBlockHash(height)
+	if isBlockOutOfRangeError(err) {
+		log.With("method", "getblockbynumber").Debugf("Block %d not found", height)
+		return nil, nil
+	}
+	if err != nil {
+		log.With("method", "getblockbynumber").Debugf(err.Error())
+		return nil, errors.Wrapf(err, "Error getting hash of block %d", height)
+	}
+	block, err := api.qcli.GetBlockVerbose(blockHash)
+	if err != nil {
+		log.With("method", "getblockbynumber").Debugf(err.Error())
+		return nil, errors.Wrapf(err, "Error getting block %s", blockHash.String())
+	}
+	return (*API)(api).newBlockResponse(block, fullTx)
+}
+
+// getBlockHeight returns the qtum block height of the block number.
+// The block tags other than "earliest" refer to the most recent block
+func (api *API) getBlockHeight(number rpc.BlockNumber) (int64, error) {
+	switch {
+	case number == rpc.EarliestBlockNumber:
+		return 0, nil
+	case number < 0:
+		height, err := api.qcli.GetBlockCount()
+		if err != nil {
+			return 0, errors.Wrap(err, "Error getting block count")
+		}
+		return height, nil
+	default:
+		return number.Int64(), nil
+	}
+}
+
+// blockOutOfRangeMessage is the message of the getblockhash error of the qtum node
+// for a height above the chain tip, to tell it apart from other invalid parameters
+const blockOutOfRangeMessage = "Block height out of range"
+
+// isBlockOutOfRangeError returns true if the error is the qtum node
+// error returned when there is no block at the requested height
+func isBlockOutOfRangeError(err error) bool {
+	var rpcErr *btcjson.RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCInvalidParameter && rpcErr.Message == blockOutOfRangeMessage
+}
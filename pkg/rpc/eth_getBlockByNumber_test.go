@@ -0,0 +1,122 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockNumber(t *testing.T) {
+	ethAPI := (*EthAPI)(NewAPI(context.Background(), mocks.NewMockQCli()))
+	got, err := ethAPI.BlockNumber()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "0x4d2", got.String())
+}
+
+func TestGetBlockByNumber(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	signedTx, err := signEthereumTx(newEthereumTx(), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	sendTestTx(t, ethAPI, mockQcli, signedTx)
+	txid := mockQcli.SendRawTransactionResult.String()
+	mockQcli.BlockResult = &btcjson.GetBlockVerboseResult{
+		Hash:         testBlockHash,
+		Height:       1234,
+		Size:         1024,
+		Time:         1669000000,
+		Nonce:        7,
+		MerkleRoot:   "0b5f3e1c8a2d4e6f7a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f",
+		PreviousHash: "5c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d",
+		Tx:           []string{txid},
+	}
+
+	got, err := ethAPI.GetBlockByNumber(rpc.LatestBlockNumber, false)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "0x4d2", got.Number)
+	assert.Equal(t, "0x"+testBlockHash, got.Hash)
+	assert.Equal(t, "0x5c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d", got.ParentHash)
+	assert.Equal(t, "0x0000000000000007", got.Nonce)
+	assert.Equal(t, "0x400", got.Size)
+	assert.Equal(t, "0x637aeb40", got.Timestamp)
+	assert.Equal(t, "0x2625a00", got.GasLimit)
+	assert.Equal(t, types.EmptyUncleHash.String(), got.Sha3Uncles)
+	assert.Empty(t, got.Uncles)
+	// txs sent through the proxy are listed with their eth hash
+	assert.Equal(t, []interface{}{signedTx.Hash().String()}, got.Transactions)
+
+	got, err = ethAPI.GetBlockByNumber(1234, true)
+	utils.HandleFatalError(t, err)
+	if assert.Len(t, got.Transactions, 1) {
+		tx, ok := got.Transactions[0].(*rpctypes.Eth_GetTransactionByHashResponse)
+		if assert.True(t, ok) {
+			assert.Equal(t, signedTx.Hash().String(), tx.Hash)
+			assert.Equal(t, "0x"+testBlockHash, *tx.BlockHash)
+			assert.Equal(t, "0x0", *tx.TransactionIndex)
+		}
+	}
+
+	// blocks beyond the chain tip are null
+	got, err = ethAPI.GetBlockByNumber(2000, false)
+	utils.HandleFatalError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestGetBlockByHash(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+	const coinbaseTxID = "a1c3b5a4d3f1e3d7a1e6b0f1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1"
+
+	privKey, err := crypto.HexToECDSA(PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	mockQcli := mocks.NewMockQtumClient(&btcjson.TxRawResult{
+		Txid: coinbaseTxID,
+		Vin:  []btcjson.Vin{{Coinbase: "03e8030101"}},
+		Vout: []btcjson.Vout{{Value: 4, N: 0, ScriptPubKey: btcjson.ScriptPubKeyResult{
+			Type: "pubkey",
+			Hex:  "21" + hex.EncodeToString(crypto.CompressPubkey(&privKey.PublicKey)) + "ac",
+		}}},
+	}, &btcjson.GetBlockVerboseResult{
+		Hash:   testBlockHash,
+		Height: 5,
+		Tx:     []string{coinbaseTxID},
+	})
+	mockQcli.DGPInfoResult = mocks.NewMockQCli().DGPInfoResult
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+
+	got, err := ethAPI.GetBlockByHash(common.HexToHash(testBlockHash), false)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "0x5", got.Number)
+	assert.Equal(t, common.Hash{}.String(), got.ParentHash)
+	assert.Equal(t, []interface{}{"0x" + coinbaseTxID}, got.Transactions)
+	// the miner of a pay to pubkey coinbase is the hash of the pubkey
+	assert.Equal(t, common.HexToAddress("0x7926223070547d2d15b2ef5e7383e541c338ffe9").String(), got.Miner)
+
+	got, err = ethAPI.GetBlockByHash(common.HexToHash("0x01"), false)
+	utils.HandleFatalError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestIsBlockOutOfRangeError(t *testing.T) {
+	assert.True(t, isBlockOutOfRangeError(&btcjson.RPCError{Code: btcjson.ErrRPCInvalidParameter, Message: "Block height out of range"}))
+	// other invalid parameters are not a missing block
+	assert.False(t, isBlockOutOfRangeError(&btcjson.RPCError{Code: btcjson.ErrRPCInvalidParameter, Message: "Invalid height"}))
+	assert.False(t, isBlockOutOfRangeError(&btcjson.RPCError{Code: btcjson.ErrRPCOutOfRange, Message: "Block height out of range"}))
+}
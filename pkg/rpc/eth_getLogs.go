@@ -0,0 +1,255 @@
+package rpc
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/chaincfg/chainhash"
+	qcommon "github.com/qtumproject/qtool/lib/common"
+)
+
+// GetLogs implements the eth_getLogs JSON-RPC call.
+//
+// Returns the logs matching the filter, searched with the qtum node's
+// searchlogs. The logs of transactions sent through the proxy have the
+// hash of the ethereum transaction as transactionHash.
+//
+// The qtum node must run with -logevents.
+func (api *EthAPI) GetLogs(req rpctypes.Eth_FilterRequest) ([]*rpctypes.Eth_Log, error) {
+	log.With("method", "getlogs").Debugf("GetLogs called with filter: %+v", req)
+
+	fromBlock, toBlock, err := (*API)(api).getFilterRange(&req)
+	if err != nil {
+		log.With("method", "getlogs").Debugf(err.Error())
+		return nil, err
+	}
+	logs, err := (*API)(api).searchLogs(&req, fromBlock, toBlock)
+	if err != nil {
+		log.With("method", "getlogs").Debugf(err.Error())
+		return nil, err
+	}
+	log.With("method", "getlogs").Debugf("Found %d logs from block %d to %d", len(logs), fromBlock, toBlock)
+	return logs, nil
+}
+
+// getFilterRange returns the range of qtum block heights of the filter. A block
+// hash selects a single block and missing block numbers default to the latest block
+func (api *API) getFilterRange(req *rpctypes.Eth_FilterRequest) (int64, int64, error) {
+	if req.BlockHash != nil {
+		blockHash, err := chainhash.NewHashFromStr(qcommon.RemoveHexPrefix(req.BlockHash.String()))
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "Invalid block hash: %s", req.BlockHash.String())
+		}
+		block, err := api.qcli.GetBlockVerbose(blockHash)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "Error getting block %s", req.BlockHash.String())
+		}
+		return block.Height, block.Height, nil
+	}
+	fromBlock, toBlock := rpc.LatestBlockNumber, rpc.LatestBlockNumber
+	if req.FromBlock != nil {
+		fromBlock = *req.FromBlock
+	}
+	if req.ToBlock != nil {
+		toBlock = *req.ToBlock
+	}
+	from, err := api.getBlockHeight(fromBlock)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := api.getBlockHeight(toBlock)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from > to {
+		return 0, 0, errors.Errorf("invalid block range: from %d to %d", from, to)
+	}
+	return from, to, nil
+}
+
+// searchLogs returns the logs matching the address and topics of the filter within
+// the block range. Qtum only filters by a single topic per position, so topic
+// positions with alternatives are matched here, as are the addresses in case the
+// node returns logs of other contracts of the same transaction
+func (api *API) searchLogs(req *rpctypes.Eth_FilterRequest, fromBlock, toBlock int64) ([]*rpctypes.Eth_Log, error) {
+	addresses := make([]string, 0, len(req.Addresses))
+	for _, address := range req.Addresses {
+		addresses = append(addresses, qcommon.RemoveHexPrefix(address.Hex()))
+	}
+	topics := make([]*string, len(req.Topics))
+	for i, alternatives := range req.Topics {
+		if len(alternatives) == 1 {
+			topic := qcommon.RemoveHexPrefix(alternatives[0].Hex())
+			topics[i] = &topic
+		}
+	}
+
+	receipts, err := api.qcli.SearchLogs(fromBlock, toBlock, addresses, topics)
+	if err != nil {
+		return nil, err
+	}
+
+	// without filters the node returns every log of the blocks, otherwise the
+	// logs of the blocks found are searched again to index them within the block
+	var offsets map[receiptKey]uint64
+	if len(addresses) == 0 && !hasTopics(topics) {
+		offsets = getLogOffsets(receipts)
+	} else {
+		offsets = make(map[receiptKey]uint64)
+		searched := make(map[uint64]bool)
+		for _, receipt := range receipts {
+			if searched[receipt.BlockNumber] {
+				continue
+			}
+			searched[receipt.BlockNumber] = true
+			blockOffsets, err := api.getBlockLogOffsets(int64(receipt.BlockNumber))
+			if err != nil {
+				return nil, err
+			}
+			for key, offset := range blockOffsets {
+				offsets[key] = offset
+			}
+		}
+	}
+
+	logs := []*rpctypes.Eth_Log{}
+	for _, receipt := range receipts {
+		txHash, err := api.getEthTxHash(receipt.TransactionHash)
+		if err != nil {
+			return nil, err
+		}
+		position := &rpctypes.Eth_Log{
+			BlockNumber:      (*hexutil.Big)(new(big.Int).SetUint64(receipt.BlockNumber)),
+			BlockHash:        common.HexToHash(receipt.BlockHash),
+			TransactionHash:  txHash,
+			TransactionIndex: hexutil.Uint64(receipt.TransactionIndex),
+		}
+		offset := offsets[newReceiptKey(&receipt)]
+		for i, qtumLog := range receipt.Log {
+			ethLog := convertQtumLog(&qtumLog, position, offset+uint64(i))
+			if matchAddresses(ethLog, req.Addresses) && matchTopics(ethLog, req.Topics) {
+				logs = append(logs, ethLog)
+			}
+		}
+	}
+	return logs, nil
+}
+
+// receiptKey identifies the qtum receipt of an output of a transaction
+type receiptKey struct {
+	txid   string
+	output uint32
+}
+
+func newReceiptKey(receipt *qtypes.TransactionReceipt) receiptKey {
+	return receiptKey{txid: receipt.TransactionHash, output: receipt.OutputIndex}
+}
+
+// getBlockLogOffsets returns the index within the block at height of the first
+// log of each qtum receipt of the block
+func (api *API) getBlockLogOffsets(height int64) (map[receiptKey]uint64, error) {
+	receipts, err := api.qcli.SearchLogs(height, height, nil, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error searching logs of block %d", height)
+	}
+	return getLogOffsets(receipts), nil
+}
+
+// getLogOffsets returns the index within its block of the first log of each
+// receipt. Qtum does not index the logs within the block, so they are counted
+// over the receipts of the earlier transactions and outputs of the block
+func getLogOffsets(receipts []qtypes.TransactionReceipt) map[receiptKey]uint64 {
+	sorted := make([]*qtypes.TransactionReceipt, 0, len(receipts))
+	for i := range receipts {
+		sorted = append(sorted, &receipts[i])
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].BlockNumber != sorted[j].BlockNumber {
+			return sorted[i].BlockNumber < sorted[j].BlockNumber
+		}
+		if sorted[i].TransactionIndex != sorted[j].TransactionIndex {
+			return sorted[i].TransactionIndex < sorted[j].TransactionIndex
+		}
+		return sorted[i].OutputIndex < sorted[j].OutputIndex
+	})
+
+	offsets := make(map[receiptKey]uint64, len(sorted))
+	var block, offset uint64
+	for _, receipt := range sorted {
+		if receipt.BlockNumber != block {
+			block, offset = receipt.BlockNumber, 0
+		}
+		offsets[newReceiptKey(receipt)] = offset
+		offset += uint64(len(receipt.Log))
+	}
+	return offsets
+}
+
+// hasTopics returns true if any topic position is filtered
+func hasTopics(topics []*string) bool {
+	for _, topic := range topics {
+		if topic != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// getEthTxHash returns the hash of the ethereum transaction translated into
+// the qtum transaction with the given txid, or the txid if it was not sent
+// through the proxy
+func (api *API) getEthTxHash(txid string) (common.Hash, error) {
+	signedTx, err := api.getSignedTxByQtumTxID(txid)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if signedTx != nil {
+		return signedTx.Hash(), nil
+	}
+	return common.HexToHash(txid), nil
+}
+
+// matchAddresses returns true if the log was emitted by one of the addresses of
+// the filter. An empty list matches any address
+func matchAddresses(ethLog *rpctypes.Eth_Log, addresses []common.Address) bool {
+	if len(addresses) == 0 {
+		return true
+	}
+	for _, address := range addresses {
+		if address == ethLog.Address {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTopics returns true if the log has, at each position, one of the topics
+// of the filter at that position. Empty positions match any topic
+func matchTopics(ethLog *rpctypes.Eth_Log, topics [][]common.Hash) bool {
+	if len(topics) > len(ethLog.Topics) {
+		return false
+	}
+	for i, alternatives := range topics {
+		if len(alternatives) == 0 {
+			continue
+		}
+		match := false
+		for _, topic := range alternatives {
+			if topic == ethLog.Topics[i] {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,215 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testContract      = "e4a1a5b7c3d2f1e0a9b8c7d6e5f4a3b2c1d0e9f8"
+	testTransferTopic = "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	testApprovalTopic = "8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+	testOtherTxID     = "b1c3b5a4d3f1e3d7a1e6b0f1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d2"
+	testTxID          = "c2d4c6b5e4a2f4e8b2f7c1a2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e3"
+)
+
+// newTestLogReceipt returns a qtum receipt of the tx mined at the
+// block height with a log of the test contract with the topic
+func newTestLogReceipt(txid string, height uint64, topic string) qtypes.TransactionReceipt {
+	return qtypes.TransactionReceipt{
+		BlockHash:        testBlockHash,
+		BlockNumber:      height,
+		TransactionHash:  txid,
+		TransactionIndex: 2,
+		Log: []qtypes.Log{{
+			Address: testContract,
+			Topics:  []string{topic},
+			Data:    "0000000000000000000000000000000000000000000000000000000000000001",
+		}},
+	}
+}
+
+func TestGetLogs(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	signedTx, err := signEthereumTx(newEthereumTx(), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	sendTestTx(t, ethAPI, mockQcli, signedTx)
+	txid := mockQcli.SendRawTransactionResult.String()
+	mockQcli.SearchLogsResult = []qtypes.TransactionReceipt{
+		newTestLogReceipt(txid, 1230, testTransferTopic),
+		newTestLogReceipt(testOtherTxID, 1234, testApprovalTopic),
+	}
+
+	// defaults to the latest block
+	logs, err := ethAPI.GetLogs(rpctypes.Eth_FilterRequest{})
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, [2]int64{1234, 1234}, mockQcli.SearchLogsRange)
+	if assert.Len(t, logs, 1) {
+		// txs not sent through the proxy keep their qtum txid
+		assert.Equal(t, common.HexToHash(testOtherTxID), logs[0].TransactionHash)
+	}
+
+	from := rpc.BlockNumber(1200)
+	logs, err = ethAPI.GetLogs(rpctypes.Eth_FilterRequest{FromBlock: &from})
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, [2]int64{1200, 1234}, mockQcli.SearchLogsRange)
+	if assert.Len(t, logs, 2) {
+		// txs sent through the proxy have their eth hash
+		assert.Equal(t, signedTx.Hash(), logs[0].TransactionHash)
+		assert.Equal(t, common.HexToAddress(testContract), logs[0].Address)
+		assert.Equal(t, []common.Hash{common.HexToHash(testTransferTopic)}, logs[0].Topics)
+		assert.Equal(t, common.HexToHash(testBlockHash), logs[0].BlockHash)
+		assert.Equal(t, int64(1230), logs[0].BlockNumber.ToInt().Int64())
+		assert.Equal(t, uint64(2), uint64(logs[0].TransactionIndex))
+	}
+
+	// topic alternatives are matched by the proxy
+	logs, err = ethAPI.GetLogs(rpctypes.Eth_FilterRequest{
+		FromBlock: &from,
+		Topics:    [][]common.Hash{{common.HexToHash(testApprovalTopic), common.HexToHash("0x01")}},
+	})
+	utils.HandleFatalError(t, err)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, common.HexToHash(testOtherTxID), logs[0].TransactionHash)
+	}
+
+	// logs of other contracts are filtered out by the proxy
+	logs, err = ethAPI.GetLogs(rpctypes.Eth_FilterRequest{
+		FromBlock: &from,
+		Addresses: []common.Address{common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb")},
+	})
+	utils.HandleFatalError(t, err)
+	assert.Empty(t, logs)
+	logs, err = ethAPI.GetLogs(rpctypes.Eth_FilterRequest{
+		FromBlock: &from,
+		Addresses: []common.Address{common.HexToAddress(testContract)},
+	})
+	utils.HandleFatalError(t, err)
+	assert.Len(t, logs, 2)
+
+	// logs of a single block
+	blockHash := common.HexToHash(testBlockHash)
+	_, err = ethAPI.GetLogs(rpctypes.Eth_FilterRequest{BlockHash: &blockHash})
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, [2]int64{1234, 1234}, mockQcli.SearchLogsRange)
+
+	to := rpc.BlockNumber(1000)
+	_, err = ethAPI.GetLogs(rpctypes.Eth_FilterRequest{FromBlock: &from, ToBlock: &to})
+	assert.Error(t, err)
+}
+
+func TestGetLogsIndex(t *testing.T) {
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+
+	// two txs of the same block, the first one with two logs of another contract
+	first := newTestLogReceipt(testOtherTxID, 1234, testApprovalTopic)
+	first.TransactionIndex = 1
+	first.Log[0].Address = "71517f86711b4bff4d789ad6fee9a58d8af1c6bb"
+	first.Log = append(first.Log, first.Log[0])
+	second := newTestLogReceipt(testTxID, 1234, testTransferTopic)
+	mockQcli.SearchLogsResult = []qtypes.TransactionReceipt{second, first}
+
+	logs, err := ethAPI.GetLogs(rpctypes.Eth_FilterRequest{})
+	utils.HandleFatalError(t, err)
+	if assert.Len(t, logs, 3) {
+		assert.Equal(t, uint64(2), uint64(logs[0].LogIndex))
+		assert.Equal(t, uint64(0), uint64(logs[1].LogIndex))
+		assert.Equal(t, uint64(1), uint64(logs[2].LogIndex))
+	}
+
+	// the logs of a filtered search keep their index within the block
+	logs, err = ethAPI.GetLogs(rpctypes.Eth_FilterRequest{
+		Addresses: []common.Address{common.HexToAddress(testContract)},
+	})
+	utils.HandleFatalError(t, err)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, common.HexToHash(testTxID), logs[0].TransactionHash)
+		assert.Equal(t, uint64(2), uint64(logs[0].LogIndex))
+	}
+}
+
+func TestLogFilters(t *testing.T) {
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	mockQcli.SearchLogsResult = []qtypes.TransactionReceipt{
+		newTestLogReceipt(testOtherTxID, 1234, testTransferTopic),
+		newTestLogReceipt(testOtherTxID, 1235, testTransferTopic),
+	}
+
+	id, err := ethAPI.NewFilter(rpctypes.Eth_FilterRequest{})
+	utils.HandleFatalError(t, err)
+
+	// no new blocks since the filter was installed
+	logs, err := ethAPI.GetFilterChanges(id)
+	utils.HandleFatalError(t, err)
+	assert.Empty(t, logs)
+
+	mockQcli.BlockCountResult = 1235
+	logs, err = ethAPI.GetFilterChanges(id)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, [2]int64{1235, 1235}, mockQcli.SearchLogsRange)
+	assert.Len(t, logs, 1)
+
+	logs, err = ethAPI.GetFilterChanges(id)
+	utils.HandleFatalError(t, err)
+	assert.Empty(t, logs)
+
+	// filter logs are all the logs matching the filter
+	logs, err = ethAPI.GetFilterLogs(id)
+	utils.HandleFatalError(t, err)
+	assert.Len(t, logs, 1)
+
+	// filters not polled are expired
+	api.filters.filters[id].lastPoll = api.filters.filters[id].lastPoll.Add(-filterTimeout - 1)
+	_, err = ethAPI.GetFilterChanges(id)
+	assert.ErrorIs(t, err, errFilterNotFound)
+
+	id, err = ethAPI.NewFilter(rpctypes.Eth_FilterRequest{})
+	utils.HandleFatalError(t, err)
+	assert.True(t, ethAPI.UninstallFilter(id))
+	assert.False(t, ethAPI.UninstallFilter(id))
+}
+
+func TestUnmarshalFilterRequest(t *testing.T) {
+	var req rpctypes.Eth_FilterRequest
+	err := json.Unmarshal([]byte(`{
+		"fromBlock": "0x1",
+		"toBlock": "latest",
+		"address": "0x`+testContract+`",
+		"topics": ["0x`+testTransferTopic+`", null, ["0x`+testTransferTopic+`", "0x`+testApprovalTopic+`"]]
+	}`), &req)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, rpc.BlockNumber(1), *req.FromBlock)
+	assert.Equal(t, rpc.LatestBlockNumber, *req.ToBlock)
+	assert.Equal(t, []common.Address{common.HexToAddress(testContract)}, req.Addresses)
+	assert.Equal(t, [][]common.Hash{
+		{common.HexToHash(testTransferTopic)},
+		nil,
+		{common.HexToHash(testTransferTopic), common.HexToHash(testApprovalTopic)},
+	}, req.Topics)
+
+	err = json.Unmarshal([]byte(`{"blockHash": "0x`+testBlockHash+`", "fromBlock": "0x1"}`), &req)
+	assert.Error(t, err)
+}
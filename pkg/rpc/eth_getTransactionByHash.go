@@ -0,0 +1,245 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"math/big"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/alejoacosta74/qproxy/pkg/store"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/qtumproject/btcd/chaincfg"
+	"github.com/qtumproject/btcd/chaincfg/chainhash"
+	"github.com/qtumproject/btcd/txscript"
+	qcommon "github.com/qtumproject/qtool/lib/common"
+	qtool "github.com/qtumproject/qtool/lib/tools"
+)
+
+// GetTransactionByHash implements the eth_getTransactionByHash JSON-RPC call.
+//
+// The hash is either the hash of an ethereum transaction sent through
+// eth_sendRawTransaction or the txid of any qtum transaction. Transactions
+// sent through the proxy are returned with the values of the original signed
+// ethereum transaction, while other qtum transactions are returned with
+// the values that can be derived from their inputs and outputs.
+//
+// Transactions in the mempool are returned with null block fields,
+// and unknown transactions as null.
+func (api *EthAPI) GetTransactionByHash(hash common.Hash) (*rpctypes.Eth_GetTransactionByHashResponse, error) {
+	log.With("method", "gettxbyhash").Debugf("GetTransactionByHash called with hash: %s", hash.String())
+
+	record, signedTx, err := (*API)(api).getTxRecord(hash)
+	if err != nil {
+		log.With("method", "gettxbyhash").Debugf(err.Error())
+		return nil, err
+	}
+	if record == nil {
+		// the hash may be the qtum txid of a tx sent through the proxy
+		qtumRecord, err := api.txStore.GetTxByQtumTxID(hash.String())
+		switch {
+		case err == nil:
+			record, signedTx, err = (*API)(api).getTxRecord(common.HexToHash(qtumRecord.EthHash))
+			if err != nil {
+				log.With("method", "gettxbyhash").Debugf(err.Error())
+				return nil, err
+			}
+		case !errors.Is(err, store.ErrNotFound):
+			return nil, errors.Wrapf(err, "Error getting record of qtum tx %s", hash.String())
+		}
+	}
+	qtumTxID := qcommon.RemoveHexPrefix(hash.String())
+	if record != nil {
+		qtumTxID = record.QtumTxID
+	}
+
+	qtumTx, block, err := (*API)(api).getMinedQtumTx(qtumTxID)
+	if isTxNotFoundError(err) {
+		log.With("method", "gettxbyhash").Debugf("Transaction %s not found", hash.String())
+		return nil, nil
+	}
+	if err != nil {
+		log.With("method", "gettxbyhash").Debugf(err.Error())
+		return nil, err
+	}
+
+	response, err := (*API)(api).getTransactionResponse(qtumTx, signedTx)
+	if err != nil {
+		log.With("method", "gettxbyhash").Debugf(err.Error())
+		return nil, err
+	}
+	if block != nil {
+		txIndex, err := api.qcli.GetTransactionIndex(qtumTx.Txid, block)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error getting index of qtum tx %s in block %s", qtumTx.Txid, block.Hash)
+		}
+		setTransactionBlock(response, block, txIndex)
+	}
+	return response, nil
+}
+
+// getTransactionResponse returns the eth_getTransactionByHash response for the qtum
+// transaction, without block fields. The values are taken from the signed ethereum
+// transaction it was translated from, if any
+func (api *API) getTransactionResponse(qtumTx *btcjson.TxRawResult, signedTx *types.Transaction) (*rpctypes.Eth_GetTransactionByHashResponse, error) {
+	if signedTx != nil {
+		return newTransactionResponse(signedTx)
+	}
+	return api.newQtumTransactionResponse(qtumTx)
+}
+
+// setTransactionBlock sets the block fields of the eth_getTransactionByHash
+// response for a transaction mined in the block at the given index
+func setTransactionBlock(response *rpctypes.Eth_GetTransactionByHashResponse, block *btcjson.GetBlockVerboseResult, txIndex int) {
+	blockHash := qcommon.AddHexPrefix(block.Hash)
+	blockNumber := hexutil.EncodeUint64(uint64(block.Height))
+	transactionIndex := hexutil.EncodeUint64(uint64(txIndex))
+	response.BlockHash = &blockHash
+	response.BlockNumber = &blockNumber
+	response.TransactionIndex = &transactionIndex
+}
+
+// newTransactionResponse returns the eth_getTransactionByHash response
+// with the values of a signed ethereum transaction, without block fields
+func newTransactionResponse(signedTx *types.Transaction) (*rpctypes.Eth_GetTransactionByHashResponse, error) {
+	from, err := getFromAddress(signedTx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error getting sender of eth tx %s", signedTx.Hash().String())
+	}
+	v, r, s := signedTx.RawSignatureValues()
+	response := &rpctypes.Eth_GetTransactionByHashResponse{
+		From:     from.String(),
+		Gas:      hexutil.EncodeUint64(signedTx.Gas()),
+		GasPrice: hexutil.EncodeBig(signedTx.GasPrice()),
+		Hash:     signedTx.Hash().String(),
+		Input:    hexutil.Encode(signedTx.Data()),
+		Nonce:    hexutil.EncodeUint64(signedTx.Nonce()),
+		Value:    hexutil.EncodeBig(signedTx.Value()),
+		Type:     hexutil.EncodeUint64(uint64(signedTx.Type())),
+		V:        hexutil.EncodeBig(v),
+		R:        hexutil.EncodeBig(r),
+		S:        hexutil.EncodeBig(s),
+	}
+	if signedTx.To() != nil {
+		to := signedTx.To().String()
+		response.To = &to
+	}
+	if signedTx.Type() != types.LegacyTxType {
+		chainID := hexutil.EncodeBig(signedTx.ChainId())
+		accessList := signedTx.AccessList()
+		response.ChainId = &chainID
+		response.AccessList = &accessList
+	}
+	if signedTx.Type() == types.DynamicFeeTxType {
+		maxFeePerGas := hexutil.EncodeBig(signedTx.GasFeeCap())
+		maxPriorityFeePerGas := hexutil.EncodeBig(signedTx.GasTipCap())
+		response.MaxFeePerGas = &maxFeePerGas
+		response.MaxPriorityFeePerGas = &maxPriorityFeePerGas
+	}
+	return response, nil
+}
+
+// newQtumTransactionResponse returns the eth_getTransactionByHash response for
+// a qtum transaction not sent through the proxy, without block fields.
+//
+// The receiver and value are taken from the first output paying to an address
+// and the sender from the output spent by the first input. The values that
+// only exist in ethereum transactions (gas, nonce, signature) are set to zero
+func (api *API) newQtumTransactionResponse(qtumTx *btcjson.TxRawResult) (*rpctypes.Eth_GetTransactionByHashResponse, error) {
+	response := &rpctypes.Eth_GetTransactionByHashResponse{
+		From:     common.Address{}.String(),
+		Gas:      "0x0",
+		GasPrice: "0x0",
+		Hash:     qcommon.AddHexPrefix(qtumTx.Txid),
+		Input:    "0x",
+		Nonce:    "0x0",
+		Value:    "0x0",
+		Type:     "0x0",
+		V:        "0x0",
+		R:        "0x0",
+		S:        "0x0",
+	}
+	for _, vout := range qtumTx.Vout {
+		to, ok := scriptPubKeyHexAddress(&vout.ScriptPubKey, api.cfg)
+		if !ok {
+			continue
+		}
+		value, err := btcutil.NewAmount(vout.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error converting value of output %d of qtum tx %s", vout.N, qtumTx.Txid)
+		}
+		response.To = &to
+		response.Value = hexutil.EncodeBig(new(big.Int).Mul(big.NewInt(int64(value)), weiPerSatoshi))
+		break
+	}
+	if len(qtumTx.Vin) > 0 && !qtumTx.Vin[0].IsCoinBase() {
+		from, err := api.getSpentOutputAddress(&qtumTx.Vin[0])
+		if err != nil {
+			// the sender is informative only, so the tx is returned without it
+			log.With("module", "gettxbyhash").Debugf("Error getting sender of qtum tx %s: %v", qtumTx.Txid, err)
+		} else {
+			response.From = from
+		}
+	}
+	return response, nil
+}
+
+// getSpentOutputAddress returns the hex address of the output spent by the input
+func (api *API) getSpentOutputAddress(vin *btcjson.Vin) (string, error) {
+	prevHash, err := chainhash.NewHashFromStr(vin.Txid)
+	if err != nil {
+		return "", errors.Wrapf(err, "Invalid txid: %s", vin.Txid)
+	}
+	prevTx, err := api.qcli.GetRawTransactionVerbose(prevHash)
+	if err != nil {
+		return "", errors.Wrapf(err, "Error getting qtum tx %s", vin.Txid)
+	}
+	if int(vin.Vout) >= len(prevTx.Vout) {
+		return "", errors.Errorf("Output %d not found in qtum tx %s", vin.Vout, vin.Txid)
+	}
+	address, ok := scriptPubKeyHexAddress(&prevTx.Vout[vin.Vout].ScriptPubKey, api.cfg)
+	if !ok {
+		return "", errors.Errorf("Output %d of qtum tx %s does not pay to an address", vin.Vout, vin.Txid)
+	}
+	return address, nil
+}
+
+// scriptPubKeyHexAddress returns the 0x prefixed hex address paid by the scriptPubKey, if any.
+// The address of pay to pubkey outputs is the hash of the pubkey
+func scriptPubKeyHexAddress(scriptPubKey *btcjson.ScriptPubKeyResult, cfg *chaincfg.Params) (string, bool) {
+	address := scriptPubKey.Address
+	if address == "" && len(scriptPubKey.Addresses) > 0 {
+		address = scriptPubKey.Addresses[0]
+	}
+	if address != "" {
+		hexAddress, err := qtool.ConvertAddressBase58ToHex(address)
+		if err != nil {
+			log.With("module", "gettxbyhash").Debugf("Error converting address %s to hex: %v", address, err)
+			return "", false
+		}
+		return common.HexToAddress(hexAddress.Address).String(), true
+	}
+	script, err := hex.DecodeString(scriptPubKey.Hex)
+	if err != nil || len(script) == 0 {
+		return "", false
+	}
+	_, addresses, _, err := txscript.ExtractPkScriptAddrs(script, cfg)
+	if err != nil || len(addresses) == 0 {
+		return "", false
+	}
+	if pubKey, ok := addresses[0].(*btcutil.AddressPubKey); ok {
+		return common.BytesToAddress(pubKey.AddressPubKeyHash().ScriptAddress()).String(), true
+	}
+	return common.BytesToAddress(addresses[0].ScriptAddress()).String(), true
+}
+
+// isTxNotFoundError returns true if the error is the qtum node error
+// returned when a transaction is neither in the mempool nor in the blockchain
+func isTxNotFoundError(err error) bool {
+	var rpcErr *btcjson.RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCNoTxInfo
+}
@@ -0,0 +1,97 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTransactionByHash(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	signedTx, err := signEthereumTx(newEthereumTx(), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	sendTestTx(t, ethAPI, mockQcli, signedTx)
+	from, err := getFromAddress(signedTx)
+	utils.HandleFatalError(t, err)
+	v, r, s := signedTx.RawSignatureValues()
+
+	got, err := ethAPI.GetTransactionByHash(signedTx.Hash())
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, signedTx.Hash().String(), got.Hash)
+	assert.Equal(t, from.String(), got.From)
+	assert.Equal(t, signedTx.To().String(), *got.To)
+	assert.Equal(t, hexutil.EncodeBig(signedTx.Value()), got.Value)
+	assert.Equal(t, hexutil.EncodeUint64(signedTx.Gas()), got.Gas)
+	assert.Equal(t, hexutil.EncodeBig(signedTx.GasPrice()), got.GasPrice)
+	assert.Equal(t, "0x", got.Input)
+	assert.Equal(t, "0x0", got.Nonce)
+	assert.Equal(t, hexutil.EncodeBig(v), got.V)
+	assert.Equal(t, hexutil.EncodeBig(r), got.R)
+	assert.Equal(t, hexutil.EncodeBig(s), got.S)
+	assert.Equal(t, "0x"+testBlockHash, *got.BlockHash)
+	assert.Equal(t, "0x4d2", *got.BlockNumber)
+	assert.Equal(t, "0x2", *got.TransactionIndex)
+
+	// the qtum txid resolves to the same eth tx
+	qtumTxID := common.HexToHash(mockQcli.SendRawTransactionResult.String())
+	byTxID, err := ethAPI.GetTransactionByHash(qtumTxID)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, got, byTxID)
+
+	// pending txs have null block fields
+	mockQcli.RawTxResult.BlockHash = ""
+	got, err = ethAPI.GetTransactionByHash(signedTx.Hash())
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, signedTx.Hash().String(), got.Hash)
+	assert.Nil(t, got.BlockHash)
+	assert.Nil(t, got.BlockNumber)
+	assert.Nil(t, got.TransactionIndex)
+
+	// unknown txs are null
+	got, err = ethAPI.GetTransactionByHash(common.HexToHash("0x01"))
+	utils.HandleFatalError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestGetTransactionByHashQtumTx(t *testing.T) {
+	const txid = "a1c3b5a4d3f1e3d7a1e6b0f1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1"
+
+	mockQcli := mocks.NewMockQtumClient(&btcjson.TxRawResult{
+		Txid: txid,
+		Vin:  []btcjson.Vin{{Coinbase: "03e8030101"}},
+		Vout: []btcjson.Vout{
+			{Value: 0, N: 0, ScriptPubKey: btcjson.ScriptPubKeyResult{Type: "nulldata"}},
+			{Value: 1.5, N: 1, ScriptPubKey: btcjson.ScriptPubKeyResult{
+				Type:    "pubkeyhash",
+				Address: "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
+			}},
+		},
+	}, nil)
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+
+	got, err := ethAPI.GetTransactionByHash(common.HexToHash(txid))
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "0x"+txid, got.Hash)
+	assert.Equal(t, common.HexToAddress("0x7926223070547d2d15b2ef5e7383e541c338ffe9").String(), *got.To)
+	// 1.5 qtum in wei
+	assert.Equal(t, "0x14d1120d7b160000", got.Value)
+	// coinbase txs have no sender
+	assert.Equal(t, common.Address{}.String(), got.From)
+	assert.Nil(t, got.BlockHash)
+}
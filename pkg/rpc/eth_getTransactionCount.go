@@ -1,15 +1,87 @@
 package rpc
 
 import (
+	"fmt"
+
 	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/store"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
 )
 
 // GetTransactionCount implements the eth_getTransactionCount JSON-RPC call.
 //
-// Returns the number of transactions sent from an address to be used
-// to calculate the nonce field.
-func (api *EthAPI) GetTransactionCount(address string, block string) (string, error) {
-	log.With("method", "getTransactionCount").Debugf("GetTransactionCount called with address: %s, block: %s", address, block)
+// Returns the number of transactions sent from an address through the proxy,
+// to be used to calculate the nonce field. The "pending" tag includes the
+// transactions still in the qtum mempool and any other block parameter only
+// counts the mined transactions.
+func (api *EthAPI) GetTransactionCount(address common.Address, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+	log.With("method", "getTransactionCount").Debugf("GetTransactionCount called with address: %s, block: %v", address.String(), blockNrOrHash)
+
+	var nonce uint64
+	var err error
+	if isPendingBlock(blockNrOrHash) {
+		nonce, err = api.txStore.GetNonce(address.String())
+	} else {
+		nonce, err = (*API)(api).getLatestNonce(address)
+	}
+	if err != nil {
+		log.With("method", "getTransactionCount").Debugf(err.Error())
+		return 0, errors.Wrapf(err, "Error getting nonce of address: %s", address.String())
+	}
+	return hexutil.Uint64(nonce), nil
+}
+
+// getLatestNonce returns the next nonce of the address counting only its mined
+// transactions, i.e. one more than the nonce of its last mined transaction
+func (api *API) getLatestNonce(address common.Address) (uint64, error) {
+	pending, err := api.txStore.GetNonce(address.String())
+	if err != nil {
+		return 0, err
+	}
+	for nonce := pending; nonce > 0; nonce-- {
+		record, err := api.txStore.GetTxByNonce(address.String(), nonce-1)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		_, block, err := api.getMinedQtumTx(record.QtumTxID)
+		if err != nil && !isTxNotFoundError(err) {
+			return 0, err
+		}
+		if block != nil {
+			return nonce, nil
+		}
+	}
+	return 0, nil
+}
+
+// isPendingBlock returns true if the block parameter is the "pending" tag
+func isPendingBlock(blockNrOrHash *rpc.BlockNumberOrHash) bool {
+	if blockNrOrHash == nil {
+		return false
+	}
+	blockNr, ok := blockNrOrHash.Number()
+	return ok && blockNr == rpc.PendingBlockNumber
+}
 
-	return "0x0", nil
+// checkNonce verifies the nonce of a transaction sent by the address is its next
+// nonce. Lower nonces are replays, and higher nonces are rejected instead of queued,
+// as the qtum tx is broadcast right away and the skipped nonces could never be used
+func (api *API) checkNonce(address common.Address, nonce uint64) error {
+	next, err := api.txStore.GetNonce(address.String())
+	if err != nil {
+		return errors.Wrapf(err, "Error getting nonce of address: %s", address.String())
+	}
+	switch {
+	case nonce < next:
+		return fmt.Errorf("%w: address %s, tx: %d state: %d", errNonceTooLow, address.String(), nonce, next)
+	case nonce > next:
+		return fmt.Errorf("%w: address %s, tx: %d state: %d", errNonceTooHigh, address.String(), nonce, next)
+	}
+	return nil
 }
@@ -0,0 +1,110 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/alejoacosta74/qproxy/pkg/store"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTransactionCount(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	pending := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+
+	signedTx, err := signEthereumTx(newEthereumTx(), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	from, err := getFromAddress(signedTx)
+	utils.HandleFatalError(t, err)
+
+	count, err := ethAPI.GetTransactionCount(*from, &pending)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(0), uint64(count))
+
+	// a mined tx is counted by both tags
+	sendTestTx(t, ethAPI, mockQcli, signedTx)
+	count, err = ethAPI.GetTransactionCount(*from, &pending)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(1), uint64(count))
+	count, err = ethAPI.GetTransactionCount(*from, &latest)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(1), uint64(count))
+	count, err = ethAPI.GetTransactionCount(*from, nil)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(1), uint64(count))
+
+	// a tx in the mempool is only counted as pending
+	mockQcli.RawTxResult.BlockHash = ""
+	count, err = ethAPI.GetTransactionCount(*from, &pending)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(1), uint64(count))
+	count, err = ethAPI.GetTransactionCount(*from, &latest)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(0), uint64(count))
+
+	// other addresses have no transactions
+	count, err = ethAPI.GetTransactionCount(common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb"), &pending)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(0), uint64(count))
+
+	// store errors are not taken for missing records
+	api.SetTxStore(&failingNonceStore{TxStore: api.txStore, err: errStoreFailure})
+	_, err = ethAPI.GetTransactionCount(*from, &latest)
+	assert.ErrorIs(t, err, errStoreFailure)
+}
+
+var errStoreFailure = errors.New("store failure")
+
+// failingNonceStore is a store failing to get the transactions by nonce with err
+type failingNonceStore struct {
+	store.TxStore
+	err error
+}
+
+func (s *failingNonceStore) GetTxByNonce(address string, nonce uint64) (*store.TxRecord, error) {
+	return nil, s.err
+}
+
+func TestSendRawTxNonce(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	sendTx := func(nonce uint64) error {
+		tx := types.NewTransaction(nonce, common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb"), big.NewInt(1000000), 21000, big.NewInt(20000000000), nil)
+		signedTx, err := signEthereumTx(tx, types.HomesteadSigner{}, PRIVATEKEY)
+		utils.HandleFatalError(t, err)
+		rawTx, err := signedTx.MarshalBinary()
+		utils.HandleFatalError(t, err)
+		_, err = ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+		return err
+	}
+
+	assert.NoError(t, sendTx(0))
+	// replays are rejected
+	assert.ErrorIs(t, sendTx(0), errNonceTooLow)
+	// nonces ahead of the next one are rejected, so no nonce is skipped
+	assert.ErrorIs(t, sendTx(2), errNonceTooHigh)
+	assert.NoError(t, sendTx(1))
+	assert.ErrorIs(t, sendTx(1), errNonceTooLow)
+}
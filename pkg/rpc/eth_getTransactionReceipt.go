@@ -0,0 +1,202 @@
+package rpc
+
+import (
+	"math/big"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/alejoacosta74/qproxy/pkg/store"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/chaincfg/chainhash"
+)
+
+// GetTransactionReceipt implements the eth_getTransactionReceipt JSON-RPC call.
+//
+// Returns the receipt of a transaction broadcast by eth_sendRawTransaction,
+// built from the qtum transaction it was translated into and, for contract
+// transactions, from the qtum node's transaction receipt.
+//
+// Returns null if the transaction is unknown or not mined yet.
+func (api *EthAPI) GetTransactionReceipt(hash common.Hash) (*rpctypes.Eth_GetTransactionReceiptResponse, error) {
+	log.With("method", "gettxreceipt").Debugf("GetTransactionReceipt called with hash: %s", hash.String())
+
+	record, signedTx, err := (*API)(api).getTxRecord(hash)
+	if err != nil {
+		log.With("method", "gettxreceipt").Debugf(err.Error())
+		return nil, err
+	}
+	if record == nil {
+		log.With("method", "gettxreceipt").Debugf("Transaction %s not found", hash.String())
+		return nil, nil
+	}
+
+	qtumTx, block, err := (*API)(api).getMinedQtumTx(record.QtumTxID)
+	if isTxNotFoundError(err) {
+		log.With("method", "gettxreceipt").Debugf("Qtum tx %s not found", record.QtumTxID)
+		return nil, nil
+	}
+	if err != nil {
+		log.With("method", "gettxreceipt").Debugf(err.Error())
+		return nil, err
+	}
+	if block == nil {
+		log.With("method", "gettxreceipt").Debugf("Qtum tx %s is not mined yet", record.QtumTxID)
+		return nil, nil
+	}
+	txIndex, err := api.qcli.GetTransactionIndex(qtumTx.Txid, block)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error getting index of qtum tx %s in block %s", qtumTx.Txid, block.Hash)
+	}
+
+	from, err := getFromAddress(signedTx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error getting sender of eth tx %s", hash.String())
+	}
+	// the gas price paid as when the tx was sent. Qtum blocks have no base fee,
+	// so dynamic fee txs are priced with the current gas price of the proxy
+	baseFee, err := (*API)(api).getGasPrice()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error getting gas price")
+	}
+	ethTx := newEthTx(signedTx)
+	receipt := &rpctypes.Eth_GetTransactionReceiptResponse{
+		TransactionHash:   hash,
+		TransactionIndex:  hexutil.Uint64(txIndex),
+		BlockHash:         common.HexToHash(block.Hash),
+		BlockNumber:       (*hexutil.Big)(big.NewInt(block.Height)),
+		From:              *from,
+		To:                signedTx.To(),
+		EffectiveGasPrice: (*hexutil.Big)(ethTx.effectiveGasPrice(baseFee)),
+		Logs:              []*rpctypes.Eth_Log{},
+		LogsBloom:         types.Bloom{}.Bytes(),
+		Type:              hexutil.Uint64(signedTx.Type()),
+		Status:            hexutil.Uint64(types.ReceiptStatusSuccessful),
+	}
+
+	// Transfers do not execute any contract code, so there is no qtum receipt
+	if !ethTx.isContract() {
+		receipt.GasUsed = hexutil.Uint64(params.TxGas)
+		receipt.CumulativeGasUsed = receipt.GasUsed
+		return receipt, nil
+	}
+
+	qtumReceipts, err := api.qcli.GetTransactionReceipt(record.QtumTxID)
+	if err != nil {
+		log.With("method", "gettxreceipt").Debugf(err.Error())
+		return nil, err
+	}
+	if len(qtumReceipts) == 0 {
+		return nil, errors.Errorf("No receipt found for qtum tx %s (is the qtum node running with -logevents?)", record.QtumTxID)
+	}
+	// the contract output is always the first output of the tx
+	qtumReceipt := &qtumReceipts[0]
+	log.With("method", "gettxreceipt").Debugf("Qtum receipt for tx %s: %+v", record.QtumTxID, *qtumReceipt)
+
+	receipt.GasUsed = hexutil.Uint64(qtumReceipt.GasUsed)
+	receipt.CumulativeGasUsed = hexutil.Uint64(qtumReceipt.CumulativeGasUsed)
+	if qtumReceipt.Failed() {
+		receipt.Status = hexutil.Uint64(types.ReceiptStatusFailed)
+	}
+	if signedTx.To() == nil && qtumReceipt.ContractAddress != "" {
+		contractAddress := common.HexToAddress(qtumReceipt.ContractAddress)
+		receipt.ContractAddress = &contractAddress
+	}
+	if qtumReceipt.Bloom != "" {
+		receipt.LogsBloom = common.FromHex(qtumReceipt.Bloom)
+	}
+	position := &rpctypes.Eth_Log{
+		BlockNumber:      receipt.BlockNumber,
+		BlockHash:        receipt.BlockHash,
+		TransactionHash:  receipt.TransactionHash,
+		TransactionIndex: receipt.TransactionIndex,
+	}
+	var offset uint64
+	if len(qtumReceipt.Log) > 0 {
+		offsets, err := (*API)(api).getBlockLogOffsets(block.Height)
+		if err != nil {
+			return nil, err
+		}
+		offset = offsets[receiptKey{txid: record.QtumTxID, output: qtumReceipt.OutputIndex}]
+	}
+	for i, qtumLog := range qtumReceipt.Log {
+		receipt.Logs = append(receipt.Logs, convertQtumLog(&qtumLog, position, offset+uint64(i)))
+	}
+	return receipt, nil
+}
+
+// getTxRecord returns the record of the ethereum transaction with the given hash
+// along with the decoded transaction. Both are nil if the transaction is unknown
+func (api *API) getTxRecord(hash common.Hash) (*store.TxRecord, *types.Transaction, error) {
+	record, err := api.txStore.GetTxByEthHash(hash.String())
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Error getting record of tx %s", hash.String())
+	}
+	signedTx, err := decodeRawTx(record.EthRawTx)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Error decoding eth raw tx of record %s", hash.String())
+	}
+	return record, signedTx, nil
+}
+
+// getMinedQtumTx returns the qtum transaction with the given txid and the block
+// it was mined in. The block is nil if the transaction is still in the mempool
+func (api *API) getMinedQtumTx(txid string) (*btcjson.TxRawResult, *btcjson.GetBlockVerboseResult, error) {
+	qtumTx, err := api.getQtumTx(txid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if qtumTx.BlockHash == "" {
+		return qtumTx, nil, nil
+	}
+	blockHash, err := chainhash.NewHashFromStr(qtumTx.BlockHash)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Invalid block hash: %s", qtumTx.BlockHash)
+	}
+	block, err := api.qcli.GetBlockVerbose(blockHash)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Error getting block %s", qtumTx.BlockHash)
+	}
+	return qtumTx, block, nil
+}
+
+// getQtumTx returns the qtum transaction with the given txid
+func (api *API) getQtumTx(txid string) (*btcjson.TxRawResult, error) {
+	txHash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid qtum txid: %s", txid)
+	}
+	qtumTx, err := api.qcli.GetRawTransactionVerbose(txHash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error getting qtum tx %s", txid)
+	}
+	return qtumTx, nil
+}
+
+// convertQtumLog converts a log of a qtum transaction receipt to the ethereum
+// log format, taking the block and transaction fields from position. logIndex
+// is the position of the log within the block
+func convertQtumLog(qtumLog *qtypes.Log, position *rpctypes.Eth_Log, logIndex uint64) *rpctypes.Eth_Log {
+	topics := make([]common.Hash, 0, len(qtumLog.Topics))
+	for _, topic := range qtumLog.Topics {
+		topics = append(topics, common.HexToHash(topic))
+	}
+	return &rpctypes.Eth_Log{
+		Address:          common.HexToAddress(qtumLog.Address),
+		Topics:           topics,
+		Data:             common.FromHex(qtumLog.Data),
+		BlockNumber:      position.BlockNumber,
+		BlockHash:        position.BlockHash,
+		TransactionHash:  position.TransactionHash,
+		TransactionIndex: position.TransactionIndex,
+		LogIndex:         hexutil.Uint64(logIndex),
+	}
+}
@@ -0,0 +1,186 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/stretchr/testify/assert"
+)
+
+const testBlockHash = "3e1ea1e4e4b2d2b1fd1d1ef7ae1e4ab6a2cf2a1fa4bc1fd7d0d7cd6e4f2a1b3c"
+
+// sendTestTx broadcasts the signed tx through eth_sendRawTransaction
+// and mocks the qtum tx it was translated into as mined in a block
+func sendTestTx(t *testing.T, ethAPI *EthAPI, mockQcli *mocks.MockQcli, signedTx *types.Transaction) {
+	t.Helper()
+	rawTx, err := signedTx.MarshalBinary()
+	utils.HandleFatalError(t, err)
+	_, err = ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+	utils.HandleFatalError(t, err)
+
+	txid := mockQcli.SendRawTransactionResult.String()
+	mockQcli.RawTxResult = &btcjson.TxRawResult{Txid: txid, BlockHash: testBlockHash}
+	mockQcli.BlockResult = &btcjson.GetBlockVerboseResult{
+		Hash:   testBlockHash,
+		Height: 1234,
+		Tx:     []string{"coinbase", "coinstake", txid},
+	}
+}
+
+func TestGetTransactionReceipt(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	signedTx, err := signEthereumTx(newEthereumTx(), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	sendTestTx(t, ethAPI, mockQcli, signedTx)
+
+	receipt, err := ethAPI.GetTransactionReceipt(signedTx.Hash())
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, signedTx.Hash(), receipt.TransactionHash)
+	assert.Equal(t, common.HexToHash(testBlockHash), receipt.BlockHash)
+	assert.Equal(t, int64(1234), receipt.BlockNumber.ToInt().Int64())
+	assert.Equal(t, uint64(2), uint64(receipt.TransactionIndex))
+	assert.Equal(t, signedTx.To(), receipt.To)
+	assert.Equal(t, uint64(21000), uint64(receipt.GasUsed))
+	assert.Equal(t, uint64(types.ReceiptStatusSuccessful), uint64(receipt.Status))
+	assert.Nil(t, receipt.ContractAddress)
+	assert.Empty(t, receipt.Logs)
+
+	// a tx not mined yet has no receipt
+	mockQcli.RawTxResult.BlockHash = ""
+	receipt, err = ethAPI.GetTransactionReceipt(signedTx.Hash())
+	utils.HandleFatalError(t, err)
+	assert.Nil(t, receipt)
+
+	// nor a tx whose qtum tx is not known to the qtum node yet
+	mockQcli.RawTxResult = nil
+	receipt, err = ethAPI.GetTransactionReceipt(signedTx.Hash())
+	utils.HandleFatalError(t, err)
+	assert.Nil(t, receipt)
+
+	// neither has an unknown tx
+	receipt, err = ethAPI.GetTransactionReceipt(common.HexToHash("0x01"))
+	utils.HandleFatalError(t, err)
+	assert.Nil(t, receipt)
+}
+
+func TestGetTransactionReceiptContract(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	contract := common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb")
+	data, _ := hex.DecodeString("a9059cbb00000000000000000000000096216849c49358b10257cb55b28ea603c874b05e0000000000000000000000000000000000000000000000000000000000000001")
+	tx := types.NewTransaction(0, contract, big.NewInt(0), 250000, big.NewInt(400000000000), data)
+	signedTx, err := signEthereumTx(tx, types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	sendTestTx(t, ethAPI, mockQcli, signedTx)
+
+	transferTopic := "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	mockQcli.TransactionReceiptResult = []qtypes.TransactionReceipt{{
+		GasUsed:           51498,
+		CumulativeGasUsed: 51498,
+		ContractAddress:   "71517f86711b4bff4d789ad6fee9a58d8af1c6bb",
+		Excepted:          qtypes.ExceptedNone,
+		Log: []qtypes.Log{{
+			Address: "71517f86711b4bff4d789ad6fee9a58d8af1c6bb",
+			Topics:  []string{transferTopic},
+			Data:    "0000000000000000000000000000000000000000000000000000000000000001",
+		}},
+	}}
+
+	// an earlier tx of the block emitted two logs
+	earlier := newTestLogReceipt(testOtherTxID, 1234, testApprovalTopic)
+	earlier.TransactionIndex = 1
+	earlier.Log = append(earlier.Log, earlier.Log[0])
+	mockQcli.SearchLogsResult = []qtypes.TransactionReceipt{earlier, {
+		BlockHash:        testBlockHash,
+		BlockNumber:      1234,
+		TransactionHash:  mockQcli.SendRawTransactionResult.String(),
+		TransactionIndex: 2,
+		Log:              mockQcli.TransactionReceiptResult[0].Log,
+	}}
+
+	receipt, err := ethAPI.GetTransactionReceipt(signedTx.Hash())
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(51498), uint64(receipt.GasUsed))
+	assert.Equal(t, uint64(types.ReceiptStatusSuccessful), uint64(receipt.Status))
+	// only contract creations have a contract address
+	assert.Nil(t, receipt.ContractAddress)
+	if assert.Len(t, receipt.Logs, 1) {
+		ethLog := receipt.Logs[0]
+		assert.Equal(t, contract, ethLog.Address)
+		assert.Equal(t, []common.Hash{common.HexToHash(transferTopic)}, ethLog.Topics)
+		assert.Equal(t, big.NewInt(1).FillBytes(make([]byte, 32)), []byte(ethLog.Data))
+		assert.Equal(t, receipt.BlockHash, ethLog.BlockHash)
+		assert.Equal(t, signedTx.Hash(), ethLog.TransactionHash)
+		// logs are indexed within the block
+		assert.Equal(t, uint64(2), uint64(ethLog.LogIndex))
+	}
+
+	// reverted executions have a failed status
+	mockQcli.TransactionReceiptResult[0].Excepted = qtypes.ExceptedRevert
+	receipt, err = ethAPI.GetTransactionReceipt(signedTx.Hash())
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(types.ReceiptStatusFailed), uint64(receipt.Status))
+}
+
+func TestGetTransactionReceiptDynamicFee(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	// the base fee is the gas price of the proxy, 450 gwei
+	tests := []struct {
+		name      string
+		gasFeeCap int64
+		gasTipCap int64
+		want      int64
+	}{
+		{name: "base fee plus tip", gasFeeCap: 1000000000000, gasTipCap: 2000000000, want: 452000000000},
+		{name: "capped by max fee", gasFeeCap: 480000000000, gasTipCap: 50000000000, want: 480000000000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQcli := mocks.NewMockQCli()
+			api := NewAPI(context.Background(), mockQcli)
+			api.SetNetworkParams(cfg)
+			ethAPI := (*EthAPI)(api)
+			loadTestWallet(t, PRIVATEKEY)
+
+			to := common.HexToAddress("0x7926223070547D2D15b2eF5e7383E541c338FfE9")
+			tx := types.NewTx(&types.DynamicFeeTx{
+				ChainID:   api.chainID,
+				GasTipCap: big.NewInt(tt.gasTipCap),
+				GasFeeCap: big.NewInt(tt.gasFeeCap),
+				Gas:       21000,
+				To:        &to,
+				Value:     big.NewInt(1000000),
+			})
+			signedTx, err := signEthereumTx(tx, types.LatestSignerForChainID(api.chainID), PRIVATEKEY)
+			utils.HandleFatalError(t, err)
+			sendTestTx(t, ethAPI, mockQcli, signedTx)
+
+			// the receipt has the gas price paid, not the max fee
+			receipt, err := ethAPI.GetTransactionReceipt(signedTx.Hash())
+			utils.HandleFatalError(t, err)
+			assert.Equal(t, tt.want, receipt.EffectiveGasPrice.ToInt().Int64())
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package rpc
+
+import (
+	"math/big"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// MaxPriorityFeePerGas implements the eth_maxPriorityFeePerGas JSON-RPC call.
+//
+// Returns the priority fee per gas in wei to be included in a block.
+// Qtum has no priority fee on top of the gas price, so it is always zero.
+//
+// No params are required.
+func (api *EthAPI) MaxPriorityFeePerGas() (*hexutil.Big, error) {
+	log.With("method", "maxPriorityFeePerGas").Debugf("MaxPriorityFeePerGas called. Returning 0")
+	return (*hexutil.Big)(new(big.Int)), nil
+}
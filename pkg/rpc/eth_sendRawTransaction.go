@@ -1,14 +1,24 @@
 package rpc
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/hex"
+	"time"
 
 	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/qtum"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
 	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/alejoacosta74/qproxy/pkg/store"
 	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/qtumproject/btcd/chaincfg/chainhash"
+	"github.com/qtumproject/btcd/wire"
 
-	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/pkg/errors"
 	qcommon "github.com/qtumproject/qtool/lib/common"
 	qtool "github.com/qtumproject/qtool/lib/tools"
@@ -31,18 +41,46 @@ func (api *EthAPI) SendRawTransaction(rawtx string) (*rpctypes.Eth_SendRawTransa
 	}
 	log.With("module", "eth_sendRawTransaction").Tracef("Decoded transaction: %+v", *decodedTx)
 
+	api.sendMu.Lock()
+	defer api.sendMu.Unlock()
+	return api.sendSignedTx(decodedTx)
+}
+
+// sendSignedTx translates the signed ethereum transaction into a qtum transaction
+// signed with the stored private key of the sender, and broadcasts it.
+// Must be called with sendMu held
+func (api *EthAPI) sendSignedTx(decodedTx *types.Transaction) (*rpctypes.Eth_SendRawTransactionResponse, error) {
+	// Reject EIP-155 transactions signed for other chains, so they can not be replayed here
+	if decodedTx.Protected() && decodedTx.ChainId().Cmp(api.chainID) != 0 {
+		err := errors.Errorf("invalid chain id: got %v, want %v", decodedTx.ChainId(), api.chainID)
+		log.With("method", "sendrawtx").Debugf(err.Error())
+		return nil, err
+	}
+
 	// Load wallet for sender eth hex address
 	ws := wallet.GetWallets()
 	sender, err := getFromAddress(decodedTx)
 	if err != nil {
 		log.With("method", "sendrawtx").Debugf(err.Error())
-		return nil, errors.Wrapf(err, "Error getting sender address from raw transaction: %s", rawtx)
+		return nil, errors.Wrapf(err, "Error getting sender address from transaction: %s", decodedTx.Hash().String())
+	}
+	// Reject replayed nonces and nonces ahead of the sender's next nonce, which would leave a gap
+	err = (*API)(api).checkNonce(*sender, decodedTx.Nonce())
+	if err != nil {
+		log.With("method", "sendrawtx").Debugf(err.Error())
+		return nil, err
 	}
 	w, err := ws.SeekWallet(sender.String())
 	if err != nil {
 		log.With("method", "sendrawtx").Debugf(err.Error())
 		return nil, errors.Wrapf(err, "Error loading wallet for address: %s", sender.String())
 	}
+	// Check the tx is authorized to be signed with the wallet
+	err = api.authorizeTx(w, *sender, decodedTx)
+	if err != nil {
+		log.With("method", "sendrawtx").Debugf(err.Error())
+		return nil, err
+	}
 	// Get address in qtum/btc format
 	addr, err := w.GetQtumAddress()
 	log.With("module", "eth_sendRawTransaction").Debugf("Wallet returned address encoded: %s", addr)
@@ -53,67 +91,95 @@ func (api *EthAPI) SendRawTransaction(rawtx string) (*rpctypes.Eth_SendRawTransa
 	}
 
 	// Convert value in wei to amount in qtum
-	weiAmount := decodedTx.Value
-	amount, err := qcommon.ConvertWeiToQtum(hexutil.EncodeBig(weiAmount()))
+	ethTx := newEthTx(decodedTx)
+	amount, err := ethTx.amount()
 	if err != nil {
 		log.With("method", "sendrawtx").Debugf(err.Error())
-		return nil, errors.Wrapf(err, "Error converting amount to float: %v", decodedTx.Value().Int64())
+		return nil, err
 	}
 	log.With("method", "sendrawtx").Debugf("Amount in wei: %v,  amount in Qtum: %f", decodedTx.Value().Int64(), amount)
 
-	// ensure the address is known to the node's wallet
-	err = api.qcli.VerifyAddress(addr)
+	// ensure every address of the sender key is known to the node's wallet,
+	// so the utxos of all of them are found
+	err = api.qcli.VerifyWallet(w)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Error verifying address: %s", addr)
+		return nil, errors.Wrapf(err, "Error verifying addresses of wallet: %s", sender.String())
+	}
+
+	// A tx without receiver deploys a new contract and a tx with receiver and data
+	// calls a contract. Both pay for the gas reserved for the contract execution
+	// on top of the amount sent
+	var contract *qtypes.ContractParams
+	total := amount
+	if ethTx.isContract() {
+		// Qtum has no base fee, so dynamic fee transactions pay the proxy gas price
+		// (the base fee of eth_feeHistory) plus their priority fee. The contract gas
+		// price excludes the utxo fee included in the proxy gas price
+		baseFee, utxoFee, err := (*API)(api).getGasPrices()
+		if err != nil {
+			log.With("method", "sendrawtx").Debugf(err.Error())
+			return nil, errors.Wrap(err, "Error getting gas price")
+		}
+		contract, err = ethTx.contractParams(baseFee, utxoFee)
+		if err != nil {
+			log.With("method", "sendrawtx").Debugf(err.Error())
+			return nil, errors.Wrap(err, "Error converting contract params")
+		}
+		total += contract.GasFee()
+		log.With("method", "sendrawtx").Debugf("Contract tx (create: %t) with gas limit: %d, gas price: %d satoshis", contract.IsCreate(), contract.GasLimit, contract.GasPrice)
 	}
 
-	// Find spendable UTXO for sender address and amount
-	unspent, err := api.qcli.FindSpendableUTXO(addr)
+	// Find spendable UTXO for every address of the sender key (P2PK, P2PKH
+	// and segwit). The UTXOs to spend are selected when building the qtum
+	// transaction, which pays the change to the change address of the wallet,
+	// if set, or back to the sender address
+	addrs, err := w.GetQtumAddresses()
 	if err != nil {
 		log.With("method", "sendrawtx").Debugf(err.Error())
-		return nil, errors.Wrapf(err, "Error finding spendable UTXO for address: %s", addr)
+		return nil, errors.Wrapf(err, "Error getting qtum addresses for wallet: %s", sender.String())
 	}
-	spendable, err := getUTXOtoSpend(unspent, amount)
+	unspent, err := api.qcli.FindSpendableUTXO(addrs...)
 	if err != nil {
 		log.With("method", "sendrawtx").Debugf(err.Error())
-		return nil, errors.Wrapf(err, "Error getting UTXO to spend for address: %s", addr)
+		return nil, errors.Wrapf(err, "Error finding spendable UTXO for addresses: %v", addrs)
 	}
-	log.With("method", "sendrawtx").Debugf("Found %d utxos to spent", len(spendable))
-
-	if log.IsDebug() {
-		fmt.Printf("UTXO to spend:\n")
-		fmt.Printf("scriptPubKey: %s\n", spendable[0].ScriptPubKey)
-		fmt.Printf("redeemscript: %s\n", spendable[0].RedeemScript)
-		fmt.Printf("amount: %f\n", spendable[0].Amount)
-		fmt.Printf("address: %s\n", spendable[0].Address)
-		fmt.Printf("txid: %s\n", spendable[0].TxID)
-		fmt.Printf("vout: %d\n", spendable[0].Vout)
-		fmt.Printf("confirmations: %d\n", spendable[0].Confirmations)
-		fmt.Printf("spendable: %t\n", spendable[0].Spendable)
-
-	}
-
-	// Convert receiver address to base58
-	receiver, err := qtool.AddressHexToBase58(decodedTx.To().String(), api.cfg)
-	if err != nil {
-		log.With("method", "sendrawtx").Debugf(err.Error())
-		return nil, errors.Wrapf(err, "Error converting receiver address to base58: %s", decodedTx.To().String())
+	log.With("method", "sendrawtx").Debugf("Found %d utxos for addresses %v to pay %f Qtum", len(unspent), addrs, total)
+	change := wallet.GetChangeAddress(*sender)
+	if change == "" {
+		change = addr
 	}
 
 	// Create qtum transaction
-	log.With("method", "sendrawtx").Debugf("Receiver address: %s", receiver)
-	qtumTx, err := api.qcli.BuildUnsignedQtumTx(spendable, addr, receiver, amount)
+	var qtumTx *wire.MsgTx
+	var droppedChange btcutil.Amount
+	var receiver string
+	if contract != nil {
+		receiver = contract.Address
+		qtumTx, droppedChange, err = api.qcli.BuildUnsignedContractTx(unspent, addr, change, contract, amount)
+	} else {
+		// Convert receiver address to base58
+		receiver, err = qtool.AddressHexToBase58(decodedTx.To().String(), api.cfg)
+		if err != nil {
+			log.With("method", "sendrawtx").Debugf(err.Error())
+			return nil, errors.Wrapf(err, "Error converting receiver address to base58: %s", decodedTx.To().String())
+		}
+		log.With("method", "sendrawtx").Debugf("Receiver address: %s", receiver)
+		qtumTx, droppedChange, err = api.qcli.BuildUnsignedQtumTx(unspent, change, receiver, amount)
+	}
 	if err != nil {
 		log.With("method", "sendrawtx").Debugf(err.Error())
 		return nil, errors.Wrapf(err, "Error preparing transaction")
 	}
+	if droppedChange > 0 {
+		log.With("method", "sendrawtx").Infof("Change %v of eth tx %s is paid as fee", droppedChange, decodedTx.Hash().String())
+	}
 
 	if log.IsDebug() {
 		api.printQtumDecodedTX(qtumTx, "Decoded unsigned qtum tx")
 	}
 
 	// Sign qtum transaction
-	err = api.qcli.SignRawTX(qtumTx, spendable, w)
+	err = api.qcli.SignRawTX(qtumTx, unspent, w)
 	if err != nil {
 		log.With("method", "sendrawtx").Debugf(err.Error())
 		return nil, errors.Wrapf(err, "Error signing transaction")
@@ -123,36 +189,66 @@ func (api *EthAPI) SendRawTransaction(rawtx string) (*rpctypes.Eth_SendRawTransa
 		api.printQtumDecodedTX(qtumTx, "Decoded signed qtum tx")
 	}
 
-	// Send qtum raw transaction
+	// Record the translation before broadcasting it, so the nonce is reserved and
+	// the tx counts towards the sender limits once broadcast. The txid of the signed
+	// qtum tx is known in advance, as it does not include the witness data
+	qtumTxID := qtumTx.TxHash()
+	err = api.recordTx(decodedTx, *sender, &qtumTxID, qtumTx, unspent, addr, receiver, amount)
+	if err != nil {
+		log.With("method", "sendrawtx").Debugf(err.Error())
+		return nil, errors.Wrapf(err, "Error recording transaction")
+	}
+
+	// Send qtum raw transaction. If it is rejected, the record is deleted to release the nonce
 	qtumHash, err := api.qcli.SendRawTransaction(qtumTx, true)
 	if err != nil {
 		log.With("method", "sendrawtx").Debugf(err.Error())
+		if deleteErr := api.txStore.DeleteTx(decodedTx.Hash().String()); deleteErr != nil {
+			log.With("method", "sendrawtx").Infof("Error deleting record of rejected eth tx %s: %v", decodedTx.Hash().String(), deleteErr)
+		}
 		return nil, errors.Wrapf(err, "Error sending transaction")
 	}
 	log.With("method", "sendrawtx").Debugf("Transaction sent with txid: %s", qtumHash.String())
 
-	return &rpctypes.Eth_SendRawTransactionResponse{
-		Hash: decodedTx.Hash().String(),
-	}, nil
+	response := &rpctypes.Eth_SendRawTransactionResponse{
+		Hash:          decodedTx.Hash().String(),
+		DroppedChange: droppedChange.ToBTC(),
+	}
+	if contract != nil && contract.IsCreate() {
+		// the contract output is always the first output of the tx
+		response.ContractAddress = qcommon.AddHexPrefix(qtum.ContractAddress(qtumHash, 0))
+		log.With("method", "sendrawtx").Debugf("Contract will be created at address: %s", response.ContractAddress)
+	}
+	return response, nil
 }
 
-// getUTXOtoSpend receives a list of unspent UTXOs and returns
-// a list of UTXOs that can be used to spend the amount requested
-func getUTXOtoSpend(unspent []btcjson.ListUnspentResult, amount float64) ([]btcjson.ListUnspentResult, error) {
-	var utxos []btcjson.ListUnspentResult
-	var total float64
-	for _, utxo := range unspent {
-		if utxo.Confirmations < 6 {
-			continue
-		}
-		utxos = append(utxos, utxo)
-		total += utxo.Amount
-		if total >= amount {
-			break
-		}
+// recordTx stores in the tx store the record of the signed ethereum
+// transaction, translated into the given signed qtum transaction
+func (api *EthAPI) recordTx(signedTx *types.Transaction, from common.Address, qtumTxID *chainhash.Hash, qtumTx *wire.MsgTx, unspent []btcjson.ListUnspentResult, sender, receiver string, amount float64) error {
+	var buf bytes.Buffer
+	err := qtumTx.Serialize(&buf)
+	if err != nil {
+		return errors.Wrap(err, "Error serializing qtum tx")
 	}
-	if total < amount {
-		return nil, errors.New("Not enough funds to spend")
+	fee, err := qtum.CalculateTxFee(qtumTx, unspent)
+	if err != nil {
+		return errors.Wrap(err, "Error calculating qtum tx fee")
 	}
-	return utxos, nil
+	ethRawTx, err := signedTx.MarshalBinary()
+	if err != nil {
+		return errors.Wrap(err, "Error encoding eth tx")
+	}
+	return api.txStore.PutTx(&store.TxRecord{
+		EthHash:   signedTx.Hash().String(),
+		QtumTxID:  qtumTxID.String(),
+		Sender:    sender,
+		Receiver:  receiver,
+		Amount:    amount,
+		Fee:       fee.ToBTC(),
+		RawTx:     hex.EncodeToString(buf.Bytes()),
+		EthRawTx:  hexutil.Encode(ethRawTx),
+		From:      from.String(),
+		Nonce:     signedTx.Nonce(),
+		Timestamp: time.Now().UTC(),
+	})
 }
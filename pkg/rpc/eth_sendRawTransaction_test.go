@@ -4,15 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
-	"encoding/json"
-	"reflect"
+	"errors"
+	"math"
+	"math/big"
 	"testing"
 
 	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
 	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/alejoacosta74/qproxy/pkg/qtum"
+	"github.com/alejoacosta74/qproxy/pkg/store"
 	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/qtumproject/btcd/btcjson"
+	"github.com/stretchr/testify/assert"
 )
 
 var cfg = utils.GetNetworkParams()
@@ -29,9 +34,7 @@ func TestSendRawTx(t *testing.T) {
 	ethAPI := (*EthAPI)(api)
 
 	// create a new wallet
-	ws := wallet.GetWallets()
-	_, err := ws.NewWallet(PRIVATEKEY, cfg)
-	utils.HandleFatalError(t, err)
+	loadTestWallet(t, PRIVATEKEY)
 
 	// create a raw ethereum transaction
 	tx := newEthereumTx()
@@ -57,109 +60,206 @@ func TestSendRawTx(t *testing.T) {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 
+	// the translation is recorded in the tx store
+	record, err := api.txStore.GetTxByEthHash(want)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, mockQcli.SendRawTransactionResult.String(), record.QtumTxID)
+	assert.Equal(t, "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW", record.Sender)
+	assert.NotEmpty(t, record.RawTx)
+
+	// every address of the sender key is imported before its utxos are spent
+	assert.Equal(t, "0x6Fd56E72373a34bA39Bf4167aF82e7A411BFED47", mockQcli.VerifiedWallet.GetEthereumAddress().String())
+	// the utxos of every address of the sender key are spent
+	assert.Equal(t, []string{
+		"qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
+		"qcXuYftjg3Sv9kzHv4M5zY5DL5szbJN488",
+		"tq1q0ynzyvrs237j69djaa088ql9g8pn3llff0lv8k",
+		"mWgNzWERiF44kXr79aUci1cwnYcykR91AT",
+	}, mockQcli.SpendableAddresses)
+
+	// the change is paid back to the sender address
+	assert.Equal(t, "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW", mockQcli.ChangeAddress)
+	assert.Zero(t, got.DroppedChange)
+}
+
+func TestSendRawTxChangeAddress(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+	const CHANGEADDR = "qeVQ5JF6idPcrg1u9M3pCryXeebpj3Tbpk"
+
+	mockQcli := mocks.NewMockQCli()
+	mockQcli.DroppedChangeResult = 50000
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	w := loadTestWallet(t, PRIVATEKEY)
+	sender := *w.GetEthereumAddress()
+	utils.HandleFatalError(t, wallet.SetChangeAddress(sender, CHANGEADDR, cfg))
+	t.Cleanup(func() { wallet.SetChangeAddress(sender, "", cfg) })
+
+	signedTx, err := signEthereumTx(newEthereumTx(), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	rawTx, err := signedTx.MarshalBinary()
+	utils.HandleFatalError(t, err)
+
+	got, err := ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, CHANGEADDR, mockQcli.ChangeAddress)
+	// the change paid as fee is reported
+	assert.Equal(t, 0.0005, got.DroppedChange)
+}
+
+func TestSendRawTxContractCreation(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+	const BYTECODE = "6080604052348015600f57600080fd5b50603f80601d6000396000f3fe6080604052600080fdfea164736f6c6343000811000a"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	bytecode, _ := hex.DecodeString(BYTECODE)
+	signedTx, err := signEthereumTx(newContractCreationTx(bytecode), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	rawTx, err := signedTx.MarshalBinary()
+	utils.HandleFatalError(t, err)
+
+	got, err := ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, signedTx.Hash().Hex(), got.Hash)
+	// contract address derived from the mocked qtum txid and the first output
+	wantAddress := "0x" + qtum.ContractAddress(mockQcli.SendRawTransactionResult, 0)
+	assert.Equal(t, wantAddress, got.ContractAddress)
+	assert.True(t, mockQcli.ContractParams.IsCreate())
+	assert.Equal(t, bytecode, mockQcli.ContractParams.Data)
+
+	// a gas limit above the block gas limit is rejected before paying its gas fee
+	tx := newContractCreationTx(bytecode)
+	signedTx, err = signEthereumTx(types.NewContractCreation(tx.Nonce()+1, tx.Value(), math.MaxUint64, tx.GasPrice(), tx.Data()), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	rawTx, err = signedTx.MarshalBinary()
+	utils.HandleFatalError(t, err)
+	_, err = ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+	assert.ErrorContains(t, err, "block gas limit")
+}
+
+func TestSendRawTxContractCall(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	// transfer(address,uint256) call
+	data, _ := hex.DecodeString("a9059cbb00000000000000000000000096216849c49358b10257cb55b28ea603c874b05e0000000000000000000000000000000000000000000000000000000000000001")
+	contract := common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb")
+	tx := types.NewTransaction(0, contract, big.NewInt(0), 250000, big.NewInt(400000000000), data)
+	signedTx, err := signEthereumTx(tx, types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	rawTx, err := signedTx.MarshalBinary()
+	utils.HandleFatalError(t, err)
+
+	got, err := ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, signedTx.Hash().Hex(), got.Hash)
+	assert.Empty(t, got.ContractAddress)
+
+	// the call is translated into an OP_CALL output to the contract
+	assert.False(t, mockQcli.ContractParams.IsCreate())
+	assert.Equal(t, "71517f86711b4bff4d789ad6fee9a58d8af1c6bb", mockQcli.ContractParams.Address)
+	assert.Equal(t, data, mockQcli.ContractParams.Data)
+	assert.Equal(t, uint64(250000), mockQcli.ContractParams.GasLimit)
+	assert.Equal(t, uint64(40), mockQcli.ContractParams.GasPrice)
 }
 
-const listUnspentResponseJSON string = `[
-		{
-		  "txid": "bbe399eebaf12849cb306af8218460061223baa8cb76216358dd68429c921500",
-		  "vout": 0,
-		  "address": "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
-		  "label": "",
-		  "scriptPubKey": "76a9147926223070547d2d15b2ef5e7383e541c338ffe988ac",
-		  "amount": 20000.00000000,
-		  "confirmations": 2763,
-		  "spendable": true,
-		  "solvable": false,
-		  "safe": true
-		},
-		{
-		  "txid": "8225bd905c83553ebba2bb80887608ea5cc315a3d34e5d1283359b6ffa862e00",
-		  "vout": 0,
-		  "address": "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
-		  "label": "",
-		  "scriptPubKey": "76a9147926223070547d2d15b2ef5e7383e541c338ffe988ac",
-		  "amount": 20000.00000000,
-		  "confirmations": 2468,
-		  "spendable": true,
-		  "solvable": false,
-		  "safe": true
-		},
-		{
-		  "txid": "50db899a5e3eb817381d82719327720d408daaff6b55a9e5878786b0d44a5f00",
-		  "vout": 0,
-		  "address": "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
-		  "label": "",
-		  "scriptPubKey": "76a9147926223070547d2d15b2ef5e7383e541c338ffe988ac",
-		  "amount": 20000.00000000,
-		  "confirmations": 2213,
-		  "spendable": false,
-		  "solvable": false,
-		  "safe": true
-		}
-	      ]`
-
-var listUnspentResponse []btcjson.ListUnspentResult
-var gasSatoshis int = 10000
-var gas float64 = float64(gasSatoshis) / 100000000
-
-func TestGetUTXOtoSpend(t *testing.T) {
-
-	err := json.Unmarshal([]byte(listUnspentResponseJSON), &listUnspentResponse)
-	utils.HandleFatalError(t, err)
-
-	// Define and run tests
+func TestSendRawTxDynamicFee(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	// the base fee is the gas price of the proxy, 45 satoshis (450 gwei), of which
+	// 5 satoshis pay for the utxo tx and are not part of the contract gas price
 	tests := []struct {
-		name    string
-		address string
-		amount  float64
-		want    []btcjson.ListUnspentResult
-		wantErr bool
+		name      string
+		gasFeeCap int64
+		gasTipCap int64
+		want      uint64
 	}{
-		{
-			name:    "Test 1",
-			address: "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
-			amount:  20000,
-			want:    listUnspentResponse[0:1],
-			wantErr: false,
-		},
-		{
-			name:    "Test 2",
-			address: "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
-			amount:  40000,
-			want:    listUnspentResponse[0:2],
-			wantErr: false,
-		},
-		{
-			name:    "Test 3",
-			address: "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
-			amount:  40001,
-			want:    listUnspentResponse[0:3],
-			wantErr: false,
-		},
-		{
-			name:    "Test 4",
-			address: "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
-			amount:  80001,
-			want:    listUnspentResponse[0:3],
-			wantErr: true,
-		},
+		{name: "base fee plus tip", gasFeeCap: 1000000000000, gasTipCap: 50000000000, want: 45},
+		{name: "capped by max fee", gasFeeCap: 480000000000, gasTipCap: 50000000000, want: 43},
+		{name: "no tip", gasFeeCap: 1000000000000, gasTipCap: 0, want: 40},
 	}
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := getUTXOtoSpend(listUnspentResponse, tt.amount)
-			if err != nil {
-				if !tt.wantErr {
-					t.Errorf("qcli.FindSpendableUTXO() error = %v, wantErr %v", err, tt.wantErr)
-					return
-				} else {
-					return
-				}
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("QtumClient.FindSpendableUTXO() = \n%v\n ====>want \n%v\n", got, tt.want)
-			}
+			mockQcli := mocks.NewMockQCli()
+			api := NewAPI(context.Background(), mockQcli)
+			api.SetNetworkParams(cfg)
+			ethAPI := (*EthAPI)(api)
+			loadTestWallet(t, PRIVATEKEY)
+
+			contract := common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb")
+			tx := types.NewTx(&types.DynamicFeeTx{
+				ChainID:   api.chainID,
+				Nonce:     0,
+				GasTipCap: big.NewInt(tt.gasTipCap),
+				GasFeeCap: big.NewInt(tt.gasFeeCap),
+				Gas:       250000,
+				To:        &contract,
+				Data:      []byte{0xa9, 0x05, 0x9c, 0xbb},
+			})
+			signedTx, err := signEthereumTx(tx, types.LatestSignerForChainID(api.chainID), PRIVATEKEY)
+			utils.HandleFatalError(t, err)
+			rawTx, err := signedTx.MarshalBinary()
+			utils.HandleFatalError(t, err)
+
+			got, err := ethAPI.SendRawTransaction(hexutil.Encode(rawTx))
+			utils.HandleFatalError(t, err)
+			assert.Equal(t, signedTx.Hash().Hex(), got.Hash)
+			assert.Equal(t, tt.want, mockQcli.ContractParams.GasPrice)
+
+			// the typed tx is returned with its fee caps
+			_, recorded, err := (*API)(api).getTxRecord(signedTx.Hash())
+			utils.HandleFatalError(t, err)
+			response, err := newTransactionResponse(recorded)
+			utils.HandleFatalError(t, err)
+			assert.Equal(t, "0x2", response.Type)
+			assert.Equal(t, hexutil.EncodeBig(big.NewInt(tt.gasFeeCap)), *response.MaxFeePerGas)
+			assert.Equal(t, hexutil.EncodeBig(big.NewInt(tt.gasTipCap)), *response.MaxPriorityFeePerGas)
+			assert.Equal(t, hexutil.EncodeBig(api.chainID), *response.ChainId)
 		})
 	}
+}
+
+func TestSendRawTxRejected(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
 
+	mockQcli := mocks.NewMockQCli()
+	mockQcli.SendRawTransactionErr = errors.New("bad-txns-inputs-missingorspent")
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	loadTestWallet(t, PRIVATEKEY)
+
+	signedTx, err := signEthereumTx(newEthereumTx(), types.HomesteadSigner{}, PRIVATEKEY)
+	utils.HandleFatalError(t, err)
+	rawTx, err := signedTx.MarshalBinary()
+	utils.HandleFatalError(t, err)
+
+	// the record of a rejected tx is deleted, releasing its nonce
+	_, err = ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+	assert.Error(t, err)
+	_, err = api.txStore.GetTxByEthHash(signedTx.Hash().Hex())
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	nonce, err := api.txStore.GetNonce("0x6Fd56E72373a34bA39Bf4167aF82e7A411BFED47")
+	utils.HandleFatalError(t, err)
+	assert.Zero(t, nonce)
+
+	// so the same tx can be sent again
+	mockQcli.SendRawTransactionErr = nil
+	got, err := ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, signedTx.Hash().Hex(), got.Hash)
+	record, err := api.txStore.GetTxByEthHash(got.Hash)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, mockQcli.SendRawTransactionResult.String(), record.QtumTxID)
 }
@@ -0,0 +1,107 @@
+package rpc
+
+import (
+	"math/big"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+)
+
+// SendTransaction implements the eth_sendTransaction JSON-RPC call.
+//
+// Creates an ethereum transaction from the call object, signs it with the wallet
+// of the sender and sends it as eth_sendRawTransaction does. The nonce, gas price
+// and gas default to the next nonce of the sender, eth_gasPrice and eth_estimateGas.
+//
+// Returns the hash of the signed ethereum transaction.
+func (api *EthAPI) SendTransaction(req rpctypes.Eth_SendTransactionRequest) (common.Hash, error) {
+	log.With("method", "sendtx").Debugf("SendTransaction called with req: %+v", req)
+
+	if req.From == nil {
+		return common.Hash{}, errors.New("sender address (from) is required")
+	}
+	w, err := wallet.GetWallets().SeekWallet(req.From.String())
+	if err != nil {
+		log.With("method", "sendtx").Debugf(err.Error())
+		return common.Hash{}, errors.Wrapf(err, "Error loading wallet for address: %s", req.From.String())
+	}
+
+	api.sendMu.Lock()
+	defer api.sendMu.Unlock()
+	signedTx, err := api.signTransaction(w, &req)
+	if err != nil {
+		log.With("method", "sendtx").Debugf(err.Error())
+		return common.Hash{}, err
+	}
+	_, err = api.sendSignedTx(signedTx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	log.With("method", "sendtx").Debugf("Transaction %s sent", signedTx.Hash().String())
+	return signedTx.Hash(), nil
+}
+
+// signTransaction returns the ethereum transaction of the call object signed
+// with the wallet for the chain id of the API, filling its missing fields.
+// Must be called with sendMu held, so the nonce is not taken by another transaction
+func (api *EthAPI) signTransaction(w *wallet.QtumWallet, req *rpctypes.Eth_SendTransactionRequest) (*types.Transaction, error) {
+	tx := &types.LegacyTx{
+		To:    req.To,
+		Value: new(big.Int),
+		Data:  req.GetData(),
+	}
+	if req.Value != nil {
+		tx.Value = req.Value.ToInt()
+	}
+
+	if req.Nonce != nil {
+		tx.Nonce = uint64(*req.Nonce)
+	} else {
+		nonce, err := api.txStore.GetNonce(req.From.String())
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error getting nonce of address: %s", req.From.String())
+		}
+		tx.Nonce = nonce
+	}
+
+	if req.GasPrice != nil {
+		tx.GasPrice = req.GasPrice.ToInt()
+	} else {
+		gasPrice, err := (*API)(api).getGasPrice()
+		if err != nil {
+			return nil, err
+		}
+		tx.GasPrice = gasPrice
+	}
+
+	if req.Gas != nil {
+		tx.Gas = uint64(*req.Gas)
+	} else {
+		gas, err := api.EstimateGas(req.Eth_CallRequest, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error estimating gas")
+		}
+		tx.Gas, err = hexutil.DecodeUint64(gas)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid gas estimate: %s", gas)
+		}
+	}
+
+	signer := types.NewEIP155Signer(api.chainID)
+	unsignedTx := types.NewTx(tx)
+	hash := signer.Hash(unsignedTx)
+	signature, err := w.SignHash(hash[:])
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error signing transaction of address: %s", req.From.String())
+	}
+	signedTx, err := unsignedTx.WithSignature(signer, signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error adding signature to transaction")
+	}
+	return signedTx, nil
+}
@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendTx(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	w := loadTestWallet(t, PRIVATEKEY)
+	from := *w.GetEthereumAddress()
+	to := common.HexToAddress("0x7926223070547D2D15b2eF5e7383E541c338FfE9")
+
+	req := rpctypes.Eth_SendTransactionRequest{
+		Eth_CallRequest: rpctypes.Eth_CallRequest{
+			From:  &from,
+			To:    &to,
+			Value: (*hexutil.Big)(big.NewInt(1000)),
+		},
+	}
+	hash, err := ethAPI.SendTransaction(req)
+	utils.HandleFatalError(t, err)
+
+	// the signed transaction is recorded in the tx store
+	record, err := api.txStore.GetTxByEthHash(hash.Hex())
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, mockQcli.SendRawTransactionResult.String(), record.QtumTxID)
+
+	// the transaction is signed by the sender for the chain id of the proxy
+	_, signedTx, err := (*API)(ethAPI).getTxRecord(hash)
+	utils.HandleFatalError(t, err)
+	sender, err := types.Sender(types.NewEIP155Signer(api.chainID), signedTx)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, from, sender)
+	assert.Equal(t, big.NewInt(1000), signedTx.Value())
+	assert.Equal(t, to, *signedTx.To())
+
+	t.Run("Missing sender", func(t *testing.T) {
+		_, err := ethAPI.SendTransaction(rpctypes.Eth_SendTransactionRequest{Eth_CallRequest: rpctypes.Eth_CallRequest{To: &to}})
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown sender", func(t *testing.T) {
+		unknown := common.HexToAddress("0x1111111111111111111111111111111111111111")
+		_, err := ethAPI.SendTransaction(rpctypes.Eth_SendTransactionRequest{Eth_CallRequest: rpctypes.Eth_CallRequest{From: &unknown, To: &to}})
+		assert.Error(t, err)
+	})
+
+	t.Run("Given nonce and gas", func(t *testing.T) {
+		nonce := hexutil.Uint64(0)
+		gas := hexutil.Uint64(21000)
+		req.Nonce, req.Gas = &nonce, &gas
+		tx, err := ethAPI.signTransaction(w.(*wallet.QtumWallet), &req)
+		utils.HandleFatalError(t, err)
+		assert.Equal(t, uint64(0), tx.Nonce())
+		assert.Equal(t, uint64(21000), tx.Gas())
+	})
+}
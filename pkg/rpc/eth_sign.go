@@ -0,0 +1,43 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// RPC Method: eth_sign
+// Signs the data with the unlocked wallet of the address, prefixed with
+// "\x19Ethereum Signed Message:\n" and its length as defined in EIP-191.
+// Wallets with a policy are not allowed to sign
+//
+// Returns the ethereum signature [R || S || V], with V 27 or 28.
+func (api *EthAPI) Sign(address common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	log.With("method", "sign").Debugf("Sign called for address: %s", address)
+
+	err := authorizeSign(address, "eth_sign")
+	if err != nil {
+		return nil, err
+	}
+	w, err := wallet.GetWallets().SeekWallet(address.String())
+	if err != nil {
+		log.With("method", "sign").Debugf(err.Error())
+		return nil, errors.Wrapf(err, "Error loading wallet for address: %s", address)
+	}
+	return signEthereumHash(w, accounts.TextHash(data))
+}
+
+// signEthereumHash signs the hash with the wallet, returning the signature with
+// V 27 or 28 as expected by the ethereum ecrecover
+func signEthereumHash(w *wallet.QtumWallet, hash []byte) (hexutil.Bytes, error) {
+	signature, err := w.SignHash(hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error signing with wallet for address: %s", w.GetEthereumAddress())
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
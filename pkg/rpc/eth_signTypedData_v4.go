@@ -0,0 +1,34 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/pkg/errors"
+)
+
+// RPC Method: eth_signTypedData_v4
+// Signs the EIP-712 typed data with the unlocked wallet of the address.
+// Wallets with a policy are not allowed to sign
+//
+// Returns the ethereum signature [R || S || V], with V 27 or 28.
+func (api *EthAPI) SignTypedData_v4(address common.Address, typedData apitypes.TypedData) (hexutil.Bytes, error) {
+	log.With("method", "signtypeddata").Debugf("SignTypedData_v4 called for address: %s", address)
+
+	err := authorizeSign(address, "eth_signTypedData_v4")
+	if err != nil {
+		return nil, err
+	}
+	w, err := wallet.GetWallets().SeekWallet(address.String())
+	if err != nil {
+		log.With("method", "signtypeddata").Debugf(err.Error())
+		return nil, errors.Wrapf(err, "Error loading wallet for address: %s", address)
+	}
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error hashing typed data")
+	}
+	return signEthereumHash(w, hash)
+}
@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"}
+		],
+		"Mail": [
+			{"name": "from", "type": "address"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {"name": "Ether Mail", "version": "1", "chainId": "8890"},
+	"message": {"from": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826", "contents": "Hello, Bob!"}
+}`
+
+// recoverSigner returns the address that signed the hash with the ethereum signature
+func recoverSigner(t *testing.T, hash []byte, signature hexutil.Bytes) common.Address {
+	t.Helper()
+	assert.Len(t, signature, crypto.SignatureLength)
+	assert.Contains(t, []byte{27, 28}, signature[crypto.RecoveryIDOffset])
+	sig := append([]byte{}, signature...)
+	sig[crypto.RecoveryIDOffset] -= 27
+	pubKey, err := crypto.SigToPub(hash, sig)
+	utils.HandleFatalError(t, err)
+	return crypto.PubkeyToAddress(*pubKey)
+}
+
+func TestSign(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	api := NewAPI(context.Background(), nil)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	address := *loadTestWallet(t, PRIVATEKEY).GetEthereumAddress()
+	message := hexutil.Bytes("hello qtum")
+
+	t.Run("eth_sign", func(t *testing.T) {
+		signature, err := ethAPI.Sign(address, message)
+		utils.HandleFatalError(t, err)
+		assert.Equal(t, address, recoverSigner(t, accounts.TextHash(message), signature))
+	})
+
+	t.Run("eth_signTypedData_v4", func(t *testing.T) {
+		var typedData apitypes.TypedData
+		utils.HandleFatalError(t, json.Unmarshal([]byte(testTypedData), &typedData))
+		signature, err := ethAPI.SignTypedData_v4(address, typedData)
+		utils.HandleFatalError(t, err)
+		hash, _, err := apitypes.TypedDataAndHash(typedData)
+		utils.HandleFatalError(t, err)
+		assert.Equal(t, address, recoverSigner(t, hash, signature))
+	})
+
+	t.Run("Unknown address", func(t *testing.T) {
+		_, err := ethAPI.Sign(common.HexToAddress("0x1111111111111111111111111111111111111111"), message)
+		assert.Error(t, err)
+	})
+
+	t.Run("Wallet with policy", func(t *testing.T) {
+		wallet.SetPolicy(address, &wallet.Policy{})
+		t.Cleanup(func() { wallet.SetPolicy(address, nil) })
+		_, err := ethAPI.Sign(address, message)
+		assert.ErrorIs(t, err, wallet.ErrNotAuthorized)
+		var typedData apitypes.TypedData
+		utils.HandleFatalError(t, json.Unmarshal([]byte(testTypedData), &typedData))
+		_, err = ethAPI.SignTypedData_v4(address, typedData)
+		assert.ErrorIs(t, err, wallet.ErrNotAuthorized)
+		_, err = (*PersonalAPI)(api).Sign(message, address, "")
+		assert.ErrorIs(t, err, wallet.ErrNotAuthorized)
+	})
+}
+
+func TestPersonalSign(t *testing.T) {
+	setTestKeystore(t)
+	api := NewAPI(context.Background(), nil)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	personalAPI := (*PersonalAPI)(api)
+	message := hexutil.Bytes("hello qtum")
+
+	address, err := personalAPI.NewAccount(WALLET_PASSPHRASE)
+	utils.HandleFatalError(t, err)
+
+	// eth_sign requires the account to be unlocked
+	_, err = ethAPI.Sign(address, message)
+	assert.ErrorIs(t, err, wallet.ErrWalletLocked)
+
+	_, err = personalAPI.Sign(message, address, "wrong")
+	assert.Error(t, err)
+	signature, err := personalAPI.Sign(message, address, WALLET_PASSPHRASE)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, address, recoverSigner(t, accounts.TextHash(message), signature))
+
+	// personal_sign does not unlock the account
+	_, err = wallet.GetWallets().SeekWallet(address.String())
+	assert.ErrorIs(t, err, wallet.ErrWalletLocked)
+}
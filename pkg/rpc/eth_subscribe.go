@@ -0,0 +1,310 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+const (
+	// subscriptionPollInterval is the time between polls of the
+	// qtum node for new blocks and mempool transactions
+	subscriptionPollInterval = 2 * time.Second
+	// maxSubscriptionsPerConn is the number of subscriptions a connection can have
+	maxSubscriptionsPerConn = 16
+)
+
+var errTooManySubscriptions = errors.Errorf("too many subscriptions, the limit is %d per connection", maxSubscriptionsPerConn)
+
+type subscriptionKind int
+
+const (
+	newHeadsSubscription subscriptionKind = iota
+	logsSubscription
+	pendingTxsSubscription
+)
+
+// subscriber holds an eth_subscribe subscription and the connection it belongs to
+type subscriber struct {
+	kind     subscriptionKind
+	id       rpc.ID
+	notifier *rpc.Notifier
+	conn     string
+	criteria rpctypes.Eth_FilterRequest
+}
+
+// subscriptionManager holds the subscriptions and the state of the poller feeding
+// them: the height of the last block notified and the txids seen in the mempool.
+// epoch counts the resets of the poller state, done when the first subscriber arrives
+type subscriptionManager struct {
+	mu          sync.Mutex
+	start       sync.Once
+	subscribers map[rpc.ID]*subscriber
+	conns       map[string]int
+	head        int64
+	mempool     map[string]struct{}
+	epoch       uint64
+}
+
+func newSubscriptionManager() *subscriptionManager {
+	return &subscriptionManager{
+		subscribers: make(map[rpc.ID]*subscriber),
+		conns:       make(map[string]int),
+		mempool:     make(map[string]struct{}),
+	}
+}
+
+// NewHeads implements the eth_subscribe "newHeads" subscription.
+//
+// Notifies the header of each new qtum block in the ethereum block format.
+func (api *EthAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	log.With("method", "subscribe").Debugf("NewHeads subscription requested")
+	return (*API)(api).subscribe(ctx, &subscriber{kind: newHeadsSubscription})
+}
+
+// Logs implements the eth_subscribe "logs" subscription.
+//
+// Notifies the logs matching the filter in each new qtum block.
+// The block range of the filter is ignored.
+func (api *EthAPI) Logs(ctx context.Context, req rpctypes.Eth_FilterRequest) (*rpc.Subscription, error) {
+	log.With("method", "subscribe").Debugf("Logs subscription requested with filter: %+v", req)
+	if req.BlockHash != nil {
+		return nil, errors.New("log subscriptions by block hash are not supported")
+	}
+	return (*API)(api).subscribe(ctx, &subscriber{kind: logsSubscription, criteria: req})
+}
+
+// NewPendingTransactions implements the eth_subscribe "newPendingTransactions" subscription.
+//
+// Notifies the hash of each new transaction in the qtum mempool. The transactions
+// sent through the proxy are notified with their ethereum hash.
+func (api *EthAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	log.With("method", "subscribe").Debugf("NewPendingTransactions subscription requested")
+	return (*API)(api).subscribe(ctx, &subscriber{kind: pendingTxsSubscription})
+}
+
+// subscribe registers the subscriber on the connection of the request and starts
+// the poller. The subscriber is removed when it unsubscribes or the connection closes
+func (api *API) subscribe(ctx context.Context, sub *subscriber) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	conn := rpc.PeerInfoFromContext(ctx).RemoteAddr
+
+	// the poller is idle without subscribers, so it starts from the current chain
+	// state, fetched before taking the lock so the qtum node does not block it
+	sm := api.subscriptions
+	sm.mu.Lock()
+	idle := len(sm.subscribers) == 0
+	sm.mu.Unlock()
+	var head int64
+	var mempool map[string]struct{}
+	if idle {
+		var err error
+		head, mempool, err = api.pollerState()
+		if err != nil {
+			log.With("module", "subscriptions").Debugf(err.Error())
+			return nil, err
+		}
+	}
+
+	sm.mu.Lock()
+	if sm.conns[conn] >= maxSubscriptionsPerConn {
+		sm.mu.Unlock()
+		log.With("module", "subscriptions").Debugf("Connection %s reached the subscription limit", conn)
+		return nil, errTooManySubscriptions
+	}
+	if idle && len(sm.subscribers) == 0 {
+		sm.head, sm.mempool = head, mempool
+		sm.epoch++
+	}
+	rpcSub := notifier.CreateSubscription()
+	sub.id, sub.notifier, sub.conn = rpcSub.ID, notifier, conn
+	sm.subscribers[sub.id] = sub
+	sm.conns[conn]++
+	sm.mu.Unlock()
+
+	sm.start.Do(func() { go api.pollSubscriptions() })
+	go func() {
+		<-rpcSub.Err()
+		api.unsubscribe(sub)
+	}()
+	log.With("module", "subscriptions").Debugf("Subscription %s created for connection %s", sub.id, conn)
+	return rpcSub, nil
+}
+
+// unsubscribe removes the subscriber
+func (api *API) unsubscribe(sub *subscriber) {
+	sm := api.subscriptions
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.subscribers, sub.id)
+	sm.conns[sub.conn]--
+	if sm.conns[sub.conn] <= 0 {
+		delete(sm.conns, sub.conn)
+	}
+	log.With("module", "subscriptions").Debugf("Subscription %s removed", sub.id)
+}
+
+// pollerState returns the current block height and mempool txids of the qtum node
+func (api *API) pollerState() (int64, map[string]struct{}, error) {
+	head, err := api.qcli.GetBlockCount()
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "Error getting block count")
+	}
+	mempool, err := api.qcli.GetRawMempool()
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "Error getting mempool")
+	}
+	txids := make(map[string]struct{}, len(mempool))
+	for _, txHash := range mempool {
+		txids[txHash.String()] = struct{}{}
+	}
+	return head, txids, nil
+}
+
+// pollSubscriptions polls the qtum node for the subscriptions until the API context is done
+func (api *API) pollSubscriptions() {
+	ticker := time.NewTicker(subscriptionPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-api.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := api.pollOnce(); err != nil {
+				log.With("module", "subscriptions").Debugf("Error polling qtum node: %v", err)
+			}
+		}
+	}
+}
+
+// pollOnce notifies the subscribers of the blocks mined and the transactions added
+// to the mempool since the last poll. The subscribers and the poller state are
+// copied under the lock, and the qtum node is polled and the subscribers notified
+// with the lock released, so a slow node or client does not block the subscriptions
+func (api *API) pollOnce() error {
+	sm := api.subscriptions
+	sm.mu.Lock()
+	if len(sm.subscribers) == 0 {
+		sm.mu.Unlock()
+		return nil
+	}
+	subs := make([]*subscriber, 0, len(sm.subscribers))
+	for _, sub := range sm.subscribers {
+		subs = append(subs, sub)
+	}
+	last, known, epoch := sm.head, sm.mempool, sm.epoch
+	sm.mu.Unlock()
+
+	head, err := api.qcli.GetBlockCount()
+	if err != nil {
+		return errors.Wrap(err, "Error getting block count")
+	}
+	for height := last + 1; height <= head; height++ {
+		if err := api.notifyBlock(subs, height); err != nil {
+			return err
+		}
+		api.setPollerState(epoch, height, nil)
+	}
+	seen, err := api.notifyPendingTxs(subs, known)
+	if err != nil {
+		return err
+	}
+	api.setPollerState(epoch, head, seen)
+	return nil
+}
+
+// setPollerState records the height of the last block notified and, if not nil, the
+// txids seen in the mempool, unless the poller was reset since the state was copied
+func (api *API) setPollerState(epoch uint64, head int64, mempool map[string]struct{}) {
+	sm := api.subscriptions
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.epoch != epoch {
+		return
+	}
+	if head > sm.head {
+		sm.head = head
+	}
+	if mempool != nil {
+		sm.mempool = mempool
+	}
+}
+
+// notifyBlock notifies the subscribers of the header and logs of the block at the height
+func (api *API) notifyBlock(subs []*subscriber, height int64) error {
+	blockHash, err := api.qcli.GetBlockHash(height)
+	if err != nil {
+		return errors.Wrapf(err, "Error getting hash of block %d", height)
+	}
+	block, err := api.qcli.GetBlockVerbose(blockHash)
+	if err != nil {
+		return errors.Wrapf(err, "Error getting block %s", blockHash.String())
+	}
+	header, err := api.newBlockResponse(block, false)
+	if err != nil {
+		return err
+	}
+	api.notify(subs, newHeadsSubscription, &header.Eth_BlockHeader)
+
+	for _, sub := range subs {
+		if sub.kind != logsSubscription {
+			continue
+		}
+		logs, err := api.searchLogs(&sub.criteria, height, height)
+		if err != nil {
+			return err
+		}
+		for _, ethLog := range logs {
+			api.notifySubscriber(sub, ethLog)
+		}
+	}
+	return nil
+}
+
+// notifyPendingTxs notifies the subscribers of the hashes of the transactions added
+// to the mempool since the last poll, i.e. not known, and returns the txids seen
+func (api *API) notifyPendingTxs(subs []*subscriber, known map[string]struct{}) (map[string]struct{}, error) {
+	mempool, err := api.qcli.GetRawMempool()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error getting mempool")
+	}
+	seen := make(map[string]struct{}, len(mempool))
+	for _, txHash := range mempool {
+		txid := txHash.String()
+		seen[txid] = struct{}{}
+		if _, ok := known[txid]; ok {
+			continue
+		}
+		hash, err := api.getEthTxHash(txid)
+		if err != nil {
+			log.With("module", "subscriptions").Debugf(err.Error())
+			hash = common.HexToHash(txid)
+		}
+		api.notify(subs, pendingTxsSubscription, hash)
+	}
+	return seen, nil
+}
+
+// notify sends the data to the subscribers of the kind
+func (api *API) notify(subs []*subscriber, kind subscriptionKind, data interface{}) {
+	for _, sub := range subs {
+		if sub.kind == kind {
+			api.notifySubscriber(sub, data)
+		}
+	}
+}
+
+func (api *API) notifySubscriber(sub *subscriber, data interface{}) {
+	if err := sub.notifier.Notify(sub.id, data); err != nil {
+		log.With("module", "subscriptions").Debugf("Error notifying subscription %s: %v", sub.id, err)
+	}
+}
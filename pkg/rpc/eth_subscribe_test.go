@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/qtumproject/btcd/btcjson"
+	"github.com/qtumproject/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSubscriptionClient returns an in-process client of the eth namespace of the API
+func newTestSubscriptionClient(t *testing.T, api *API) *rpc.Client {
+	t.Helper()
+	server := rpc.NewServer()
+	utils.HandleFatalError(t, server.RegisterName("eth", (*EthAPI)(api)))
+	client := rpc.DialInProc(server)
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+	return client
+}
+
+// receive returns the next notification of the channel, failing the test after a timeout
+func receive[T any](t *testing.T, ch chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for notification")
+	}
+	var zero T
+	return zero
+}
+
+func TestSubscriptions(t *testing.T) {
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	client := newTestSubscriptionClient(t, api)
+	ctx := context.Background()
+
+	heads := make(chan rpctypes.Eth_BlockHeader)
+	headsSub, err := client.EthSubscribe(ctx, heads, "newHeads")
+	utils.HandleFatalError(t, err)
+	logs := make(chan rpctypes.Eth_Log)
+	_, err = client.EthSubscribe(ctx, logs, "logs", map[string]interface{}{
+		"address": "0x" + testContract,
+		"topics":  []string{"0x" + testTransferTopic},
+	})
+	utils.HandleFatalError(t, err)
+	pendingTxs := make(chan common.Hash)
+	_, err = client.EthSubscribe(ctx, pendingTxs, "newPendingTransactions")
+	utils.HandleFatalError(t, err)
+
+	// nothing is notified until the chain moves
+	utils.HandleFatalError(t, api.pollOnce())
+
+	mockQcli.BlockCountResult = 1235
+	mockQcli.BlockResult = &btcjson.GetBlockVerboseResult{Hash: testBlockHash, Height: 1235}
+	mockQcli.SearchLogsResult = []qtypes.TransactionReceipt{newTestLogReceipt(testOtherTxID, 1235, testTransferTopic)}
+	pendingTxID, err := chainhash.NewHashFromStr(testOtherTxID)
+	utils.HandleFatalError(t, err)
+	mockQcli.RawMempoolResult = []*chainhash.Hash{pendingTxID}
+
+	go func() {
+		if err := api.pollOnce(); err != nil {
+			t.Error(err)
+		}
+	}()
+	head := receive(t, heads)
+	assert.Equal(t, "0x4d3", head.Number)
+	assert.Equal(t, "0x"+testBlockHash, head.Hash)
+	ethLog := receive(t, logs)
+	assert.Equal(t, common.HexToAddress(testContract), ethLog.Address)
+	assert.Equal(t, common.HexToHash(testOtherTxID), ethLog.TransactionHash)
+	assert.Equal(t, common.HexToHash(testOtherTxID), receive(t, pendingTxs))
+
+	// subscriptions are removed on unsubscribe
+	headsSub.Unsubscribe()
+	assert.Eventually(t, func() bool {
+		api.subscriptions.mu.Lock()
+		defer api.subscriptions.mu.Unlock()
+		return len(api.subscriptions.subscribers) == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSubscriptionLimit(t *testing.T) {
+	api := NewAPI(context.Background(), mocks.NewMockQCli())
+	client := newTestSubscriptionClient(t, api)
+	ctx := context.Background()
+
+	for i := 0; i < maxSubscriptionsPerConn; i++ {
+		_, err := client.EthSubscribe(ctx, make(chan rpctypes.Eth_BlockHeader), "newHeads")
+		utils.HandleFatalError(t, err)
+	}
+	_, err := client.EthSubscribe(ctx, make(chan rpctypes.Eth_BlockHeader), "newHeads")
+	assert.ErrorContains(t, err, "too many subscriptions")
+
+	// subscriptions are removed when the connection closes
+	client.Close()
+	assert.Eventually(t, func() bool {
+		api.subscriptions.mu.Lock()
+		defer api.subscriptions.mu.Unlock()
+		return len(api.subscriptions.subscribers) == 0 && len(api.subscriptions.conns) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// slowQcli blocks GetBlockCount until release is closed, once blocking is set
+type slowQcli struct {
+	*mocks.MockQcli
+	blocking bool
+	release  chan struct{}
+}
+
+func (q *slowQcli) GetBlockCount() (int64, error) {
+	if q.blocking {
+		<-q.release
+	}
+	return q.MockQcli.GetBlockCount()
+}
+
+func TestSubscribeDuringSlowPoll(t *testing.T) {
+	qcli := &slowQcli{MockQcli: mocks.NewMockQCli(), release: make(chan struct{})}
+	api := NewAPI(context.Background(), qcli)
+	api.SetNetworkParams(cfg)
+	client := newTestSubscriptionClient(t, api)
+	ctx := context.Background()
+	_, err := client.EthSubscribe(ctx, make(chan rpctypes.Eth_BlockHeader), "newHeads")
+	utils.HandleFatalError(t, err)
+
+	// a poll waiting on the qtum node does not block new subscriptions
+	qcli.blocking = true
+	polled := make(chan error)
+	go func() { polled <- api.pollOnce() }()
+	subscribed := make(chan error)
+	go func() {
+		_, err := client.EthSubscribe(ctx, make(chan common.Hash), "newPendingTransactions")
+		subscribed <- err
+	}()
+	utils.HandleFatalError(t, receive(t, subscribed))
+	close(qcli.release)
+	utils.HandleFatalError(t, receive(t, polled))
+}
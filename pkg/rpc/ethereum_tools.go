@@ -1,15 +1,12 @@
 package rpc
 
 import (
-	"bytes"
 	"encoding/hex"
-	"fmt"
 
 	"github.com/alejoacosta74/qproxy/pkg/log"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/pkg/errors"
 	q "github.com/qtumproject/qtool/lib/common"
 )
@@ -21,20 +18,11 @@ func VerifyTxSignature(tx *types.Transaction, pubKeyHex string) bool {
 	//1. Get the signature and signer from the signed transaction
 	signature, signer := getSignatureAndSigner(tx)
 
-	//2. Recreate the raw transaction from the signed transaction
-	var rawTx = types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), tx.GasPrice(), tx.Data())
+	//2. Hash the transaction without its signature, as the signer
+	// hashes the legacy and typed transactions differently
+	digest := signer.Hash(tx).Bytes()
 
-	//3. RLP encode the raw transaction
-	var buf bytes.Buffer
-	if err := rlp.Encode(&buf, rawTx); err != nil {
-		fmt.Printf("error encoding raw tx: %v\n", err)
-		return false
-	}
-	//4. Hash the raw transaction
-	rawTxHashed := signer.Hash(rawTx)
-	digest := rawTxHashed.Bytes()
-
-	//6. Recover the public key from the signature and message digest
+	//3. Recover the public key from the signature and message digest
 	recoveredPubKey, err := crypto.SigToPub(digest, signature)
 	if err != nil {
 		log.With("method", "_eth_sendRawtx").Debugf("error recovering public key: %v", err)
@@ -46,15 +34,16 @@ func VerifyTxSignature(tx *types.Transaction, pubKeyHex string) bool {
 	recoveredAddress := crypto.PubkeyToAddress(*recoveredPubKey)
 	log.With("method", "_eth_sendRawtx").Debugf("recovered address: %s", recoveredAddress.String())
 
-	//7. Convert the recovered public key to a hex string
+	//4. Convert the recovered public key to a hex string
 	recoveredPubKeyHex := hex.EncodeToString(crypto.FromECDSAPub(recoveredPubKey))
 
-	//8. Compare the recovered public key hex string with the expected public key hex string
+	//5. Compare the recovered public key hex string with the expected public key hex string
 	return recoveredPubKeyHex == pubKeyHex
 }
 
-// decodeRawTX decodes a raw ethereum RLP encoded transaction
-// and returns a go-ethereum types.Transaction
+// decodeRawTX decodes a raw ethereum transaction, either a RLP encoded legacy
+// transaction or an EIP-2718 typed transaction envelope (EIP-2930 access list
+// or EIP-1559 dynamic fee), and returns a go-ethereum types.Transaction
 func decodeRawTx(rawtx string) (*types.Transaction, error) {
 	rawtx = q.RemoveHexPrefix(rawtx)
 
@@ -65,7 +54,7 @@ func decodeRawTx(rawtx string) (*types.Transaction, error) {
 
 	var tx = &types.Transaction{}
 
-	err = rlp.DecodeBytes(rawtxBytes, tx)
+	err = tx.UnmarshalBinary(rawtxBytes)
 	if err != nil {
 		return nil, err
 	}
@@ -99,10 +88,15 @@ func getSignatureAndSigner(tx *types.Transaction) (signature []byte, signer type
 	copy(signature[32-len(r.Bytes()):32], r.Bytes())
 	copy(signature[64-len(s.Bytes()):64], s.Bytes())
 
-	if tx.Protected() {
+	switch {
+	case tx.Type() != types.LegacyTxType:
+		// typed transactions hold the recovery id as V
+		signer = types.LatestSignerForChainID(tx.ChainId())
+		signature[64] = byte(v.Uint64())
+	case tx.Protected():
 		signer = types.NewEIP155Signer(tx.ChainId())
 		signature[64] = byte(v.Uint64() - 35 - 2*tx.ChainId().Uint64())
-	} else {
+	default:
 		signer = types.HomesteadSigner{}
 		signature[64] = byte(v.Uint64() - 27)
 	}
@@ -6,7 +6,9 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 var privKeyHex string = "fad9c8855b740a0b7ed4c221dbad0f33a83a49cad6b3fe8d5817ac83d38b6a19"
@@ -94,3 +96,71 @@ func TestDecodeRawTx(t *testing.T) {
 	}
 
 }
+
+func TestTypedTx(t *testing.T) {
+	chainID := big.NewInt(8890)
+	to := common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb")
+	accessList := types.AccessList{{Address: to, StorageKeys: []common.Hash{{0x01}}}}
+	typedTests := []struct {
+		name string
+		tx   *types.Transaction
+	}{
+		{
+			name: "eip2930 access list",
+			tx: types.NewTx(&types.AccessListTx{
+				ChainID: chainID, GasPrice: big.NewInt(20000000000), Gas: 21000, To: &to, Value: big.NewInt(1000000), AccessList: accessList,
+			}),
+		},
+		{
+			name: "eip1559 dynamic fee",
+			tx: types.NewTx(&types.DynamicFeeTx{
+				ChainID: chainID, GasTipCap: big.NewInt(1000000000), GasFeeCap: big.NewInt(20000000000), Gas: 21000, To: &to, Value: big.NewInt(1000000), AccessList: accessList,
+			}),
+		},
+		{
+			name: "eip1559 contract creation",
+			tx: types.NewTx(&types.DynamicFeeTx{
+				ChainID: chainID, GasTipCap: big.NewInt(1000000000), GasFeeCap: big.NewInt(20000000000), Gas: 2500000, Data: []byte{0x60, 0x80},
+			}),
+		},
+	}
+
+	privKey, err := crypto.HexToECDSA(privKeyHex)
+	if err != nil {
+		t.Fatalf("error converting private key: %v", err)
+	}
+	pubKeyHex, err := privToPubKey(privKeyHex)
+	if err != nil {
+		t.Fatalf("error converting private key to public key: %v", err)
+	}
+	for _, tt := range typedTests {
+		t.Run(tt.name, func(t *testing.T) {
+			signedTx, err := signEthereumTx(tt.tx, types.LatestSignerForChainID(chainID), privKeyHex)
+			if err != nil {
+				t.Fatalf("error signing transaction: %v", err)
+			}
+			rawTx, err := signedTx.MarshalBinary()
+			if err != nil {
+				t.Fatalf("error encoding transaction: %v", err)
+			}
+
+			decodedTx, err := decodeRawTx(hex.EncodeToString(rawTx))
+			if err != nil {
+				t.Fatalf("error decoding transaction: %v", err)
+			}
+			if decodedTx.Type() != tt.tx.Type() || decodedTx.Hash() != signedTx.Hash() {
+				t.Fatalf("decoded transaction does not match original transaction: want %s, got %s", signedTx.Hash(), decodedTx.Hash())
+			}
+			if !VerifyTxSignature(decodedTx, pubKeyHex) {
+				t.Fatalf("VerifyTxSignature() = false, want true")
+			}
+			from, err := getFromAddress(decodedTx)
+			if err != nil {
+				t.Fatalf("error getting sender: %v", err)
+			}
+			if *from != crypto.PubkeyToAddress(privKey.PublicKey) {
+				t.Fatalf("getFromAddress() = %s, want %s", from, crypto.PubkeyToAddress(privKey.PublicKey))
+			}
+		})
+	}
+}
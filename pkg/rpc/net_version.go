@@ -4,11 +4,11 @@ import "github.com/alejoacosta74/qproxy/pkg/log"
 
 // Version implements the net_version JSON-RPC call.
 //
-// Returns the current network protocol version.
+// Returns the current network id, which is the chain id
+// of the configured network.
 //
 // No params are required.
 func (api *NetAPI) Version() (string, error) {
-	// TODO: implement
-	log.With("method", "net_version").Debugf("Net_version called. Returning hardwired 8995")
-	return "8995", nil
+	log.With("method", "net_version").Debugf("Net_version called. Returning %v", api.chainID)
+	return api.chainID.String(), nil
 }
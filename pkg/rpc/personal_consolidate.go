@@ -0,0 +1,203 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/qtum"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcutil"
+)
+
+const (
+	// DefaultConsolidationMinUTXOs is the default number of unspent outputs
+	// a wallet needs to be consolidated in the background
+	DefaultConsolidationMinUTXOs = 100
+	// DefaultConsolidationMaxInputs is the default maximum number of unspent outputs
+	// merged by a consolidation tx, which keeps it below the standard tx size
+	DefaultConsolidationMaxInputs = 500
+)
+
+// ConsolidationConfig holds the values used to consolidate the unspent outputs of the wallets
+type ConsolidationConfig struct {
+	// Interval is the time between background consolidations, 0 disables them
+	Interval time.Duration
+	// MaxFeeRate is the highest fee rate (satoshis per kB) background consolidations are run at
+	MaxFeeRate btcutil.Amount
+	// MinUTXOs is the number of unspent outputs a wallet needs to be consolidated in the background
+	MinUTXOs int
+	// MaxInputs is the maximum number of unspent outputs merged by a consolidation tx
+	MaxInputs int
+}
+
+// DefaultConsolidationConfig returns the consolidation configuration used when none is set,
+// which runs no background consolidations
+func DefaultConsolidationConfig() ConsolidationConfig {
+	return ConsolidationConfig{
+		MaxFeeRate: qtum.DefaultMinFeeRate,
+		MinUTXOs:   DefaultConsolidationMinUTXOs,
+		MaxInputs:  DefaultConsolidationMaxInputs,
+	}
+}
+
+// Validate checks the consolidation values are consistent
+func (c ConsolidationConfig) Validate() error {
+	if c.Interval < 0 {
+		return errors.Errorf("consolidation interval can not be negative: %v", c.Interval)
+	}
+	if c.MaxFeeRate <= 0 {
+		return errors.Errorf("consolidation max fee rate must be positive: %v", c.MaxFeeRate)
+	}
+	if c.MinUTXOs < 0 {
+		return errors.Errorf("consolidation min utxos can not be negative: %d", c.MinUTXOs)
+	}
+	if c.MaxInputs < 2 {
+		return errors.Errorf("consolidation max inputs must be at least 2: %d", c.MaxInputs)
+	}
+	return nil
+}
+
+// SetConsolidationConfig sets the configuration used to consolidate the unspent outputs of the wallets
+func (api *API) SetConsolidationConfig(cfg ConsolidationConfig) error {
+	err := cfg.Validate()
+	if err != nil {
+		return errors.Wrap(err, "Invalid consolidation config")
+	}
+	api.consolidation = cfg
+	return nil
+}
+
+// RPC Method: personal_consolidate
+// Merges the smallest unspent outputs of the unlocked wallet of the address into
+// a single output paid to its qtum address, regardless of the fee rate. The
+// largest unspent output is left out, so the wallet can keep sending
+//
+// Returns the qtum transaction sent.
+func (api *PersonalAPI) Consolidate(address common.Address) (*rpctypes.Personal_ConsolidateResponse, error) {
+	log.With("method", "consolidate").Debugf("Consolidate called for address %s", address)
+
+	w, err := wallet.GetWallets().SeekWallet(address.String())
+	if err != nil {
+		log.With("method", "consolidate").Debugf(err.Error())
+		return nil, errors.Wrapf(err, "Error loading wallet for address: %s", address)
+	}
+	api.sendMu.Lock()
+	defer api.sendMu.Unlock()
+	response, err := (*API)(api).consolidate(w, 0)
+	if err != nil {
+		log.With("method", "consolidate").Debugf(err.Error())
+		return nil, errors.Wrapf(err, "Error consolidating wallet %s", address)
+	}
+	return response, nil
+}
+
+// consolidateWallets consolidates the unspent outputs of the wallets
+// every consolidation interval until the API context is done
+func (api *API) consolidateWallets() {
+	ticker := time.NewTicker(api.consolidation.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-api.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := api.consolidateOnce(); err != nil {
+				log.With("module", "consolidation").Debugf("Error consolidating wallets: %v", err)
+			}
+		}
+	}
+}
+
+// consolidateOnce consolidates the unspent outputs of the unlocked wallets with at
+// least the min number of unspent outputs, if the fee rate is not above the max one
+func (api *API) consolidateOnce() error {
+	feeRate, err := api.qcli.EstimateFeeRate()
+	if err != nil {
+		return err
+	}
+	if feeRate > api.consolidation.MaxFeeRate {
+		log.With("module", "consolidation").Debugf("Fee rate %v is higher than %v, skipping consolidation", feeRate, api.consolidation.MaxFeeRate)
+		return nil
+	}
+	ws := wallet.GetWallets()
+	addresses, err := ws.ListAccounts()
+	if err != nil {
+		return errors.Wrap(err, "Error listing wallets")
+	}
+	for _, address := range addresses {
+		// locked wallets are consolidated once unlocked
+		w, err := ws.SeekWallet(address.String())
+		if err != nil {
+			continue
+		}
+		api.sendMu.Lock()
+		response, err := api.consolidate(w, api.consolidation.MinUTXOs)
+		api.sendMu.Unlock()
+		if errors.Is(err, qtum.ErrNothingToConsolidate) {
+			log.With("module", "consolidation").Tracef("Wallet %s not consolidated: %v", address, err)
+			continue
+		}
+		if err != nil {
+			log.With("module", "consolidation").Infof("Error consolidating wallet %s: %v", address, err)
+			continue
+		}
+		log.With("module", "consolidation").Infof("Consolidated %d utxos of wallet %s into %v Qtum with qtum tx %s", response.Inputs, address, response.Amount, response.TxID)
+	}
+	return nil
+}
+
+// consolidate merges the unspent outputs of every address of the wallet key
+// into a single output paid to the qtum address of the wallet, if it has at
+// least minUTXOs unspent outputs. Must be called with sendMu held
+func (api *API) consolidate(w *wallet.QtumWallet, minUTXOs int) (*rpctypes.Personal_ConsolidateResponse, error) {
+	addr, err := w.GetQtumAddress()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error getting qtum address of wallet")
+	}
+	err = api.qcli.VerifyWallet(w)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error verifying addresses of wallet")
+	}
+	addrs, err := w.GetQtumAddresses()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error getting qtum addresses of wallet")
+	}
+	unspent, err := api.qcli.FindSpendableUTXO(addrs...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error finding spendable UTXO for addresses: %v", addrs)
+	}
+	if len(unspent) < minUTXOs {
+		return nil, errors.Wrapf(qtum.ErrNothingToConsolidate, "%d unspent outputs, %d needed", len(unspent), minUTXOs)
+	}
+	qtumTx, err := api.qcli.BuildConsolidationTx(unspent, addr, api.consolidation.MaxInputs)
+	if err != nil {
+		return nil, err
+	}
+	err = api.qcli.SignRawTX(qtumTx, unspent, w)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error signing transaction")
+	}
+	fee, err := qtum.CalculateTxFee(qtumTx, unspent)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error calculating qtum tx fee")
+	}
+	qtumHash, err := api.qcli.SendRawTransaction(qtumTx, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error sending transaction")
+	}
+	log.With("module", "consolidation").Debugf("Consolidation of %d utxos of %s sent with txid: %s", len(qtumTx.TxIn), addr, qtumHash.String())
+	var amount btcutil.Amount
+	for _, txOut := range qtumTx.TxOut {
+		amount += btcutil.Amount(txOut.Value)
+	}
+	return &rpctypes.Personal_ConsolidateResponse{
+		TxID:        qtumHash.String(),
+		QtumAddress: addr,
+		Inputs:      len(qtumTx.TxIn),
+		Amount:      amount.ToBTC(),
+		Fee:         fee.ToBTC(),
+	}, nil
+}
@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsolidate(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	personalAPI := (*PersonalAPI)(api)
+	w := loadTestWallet(t, PRIVATEKEY)
+	address := *w.GetEthereumAddress()
+
+	// nothing to consolidate
+	_, err := personalAPI.Consolidate(address)
+	assert.Error(t, err)
+
+	// the utxos are merged into the qtum address of the wallet
+	mockQcli.ConsolidationTxResult = mockQcli.BuildUnsignedQtumTxResult
+	got, err := personalAPI.Consolidate(address)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, mockQcli.SendRawTransactionResult.String(), got.TxID)
+	assert.Equal(t, "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW", got.QtumAddress)
+	assert.Equal(t, "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW", mockQcli.ConsolidationAddress)
+	assert.Equal(t, address, *mockQcli.VerifiedWallet.GetEthereumAddress())
+	assert.Equal(t, 2, got.Inputs)
+	assert.Equal(t, 40000.0, got.Amount+got.Fee)
+
+	// unknown wallet
+	_, err = personalAPI.Consolidate(common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb"))
+	assert.Error(t, err)
+}
+
+func TestConsolidateOnce(t *testing.T) {
+	const PRIVATEKEY = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+
+	mockQcli := mocks.NewMockQCli()
+	mockQcli.ConsolidationTxResult = mockQcli.BuildUnsignedQtumTxResult
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	loadTestWallet(t, PRIVATEKEY)
+	consolidation := DefaultConsolidationConfig()
+
+	// wallets with fewer utxos than the min are not consolidated
+	consolidation.MinUTXOs = len(mockQcli.FindSpendableUTXOResult) + 1
+	utils.HandleFatalError(t, api.SetConsolidationConfig(consolidation))
+	utils.HandleFatalError(t, api.consolidateOnce())
+	assert.Empty(t, mockQcli.ConsolidationAddress)
+
+	// no consolidation while the fee rate is above the max
+	consolidation.MinUTXOs = len(mockQcli.FindSpendableUTXOResult)
+	utils.HandleFatalError(t, api.SetConsolidationConfig(consolidation))
+	mockQcli.FeeRateResult = consolidation.MaxFeeRate + 1
+	utils.HandleFatalError(t, api.consolidateOnce())
+	assert.Empty(t, mockQcli.ConsolidationAddress)
+
+	// the unlocked wallets are consolidated, including the ones of other tests
+	mockQcli.FeeRateResult = consolidation.MaxFeeRate
+	utils.HandleFatalError(t, api.consolidateOnce())
+	assert.NotEmpty(t, mockQcli.ConsolidationAddress)
+
+	// invalid config
+	consolidation.MaxInputs = 1
+	assert.Error(t, api.SetConsolidationConfig(consolidation))
+}
@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+// RPC Method: personal_importHDWallet
+// Imports the HD wallet of a BIP39 mnemonic (e.g. a MetaMask seed phrase) or an
+// extended private key, and derives its first accounts (default 1, max 100). Every
+// account is the pair of keys derived along the ethereum (m/44'/60'/0'/0/i) and qtum
+// (m/88'/0'/i') paths, persisted in the keystore encrypted with the passphrase. The
+// master key is only kept encrypted with the passphrase, to derive the next accounts
+//
+// Returns the id of the HD wallet, used to derive its next accounts, and the derived accounts.
+func (api *PersonalAPI) ImportHDWallet(seed string, passphrase string, accounts *hexutil.Uint) (*rpctypes.Personal_ImportHDWalletResponse, error) {
+	log.With("method", "importhdwallet").Debugf("ImportHDWallet called")
+
+	count := 1
+	if accounts != nil {
+		count = int(*accounts)
+	}
+	hd, derived, err := wallet.GetWallets().ImportHDWallet(seed, passphrase, count, api.cfg)
+	if err != nil {
+		// only the cause is logged and returned, as the errors of the
+		// derived keys may contain them
+		log.With("method", "importhdwallet").Debugf("Error importing HD wallet: %v", errors.Cause(err))
+		return nil, errors.Wrap(errors.Cause(err), "Error importing HD wallet")
+	}
+	response := &rpctypes.Personal_ImportHDWalletResponse{ID: hd.ID()}
+	response.Accounts, err = newHDAccounts(derived)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// RPC Method: personal_deriveAccount
+// Derives the next account of the imported HD wallet with the id, persisted in
+// the keystore encrypted with the passphrase the HD wallet was imported with
+//
+// Returns the keys derived along the ethereum and qtum paths.
+func (api *PersonalAPI) DeriveAccount(id string, passphrase string) ([]rpctypes.Personal_HDAccount, error) {
+	log.With("method", "deriveaccount").Debugf("DeriveAccount called for HD wallet %s", id)
+
+	derived, err := wallet.GetWallets().DeriveAccount(id, passphrase, api.cfg)
+	if err != nil {
+		log.With("method", "deriveaccount").Debugf(err.Error())
+		return nil, errors.Wrapf(err, "Error deriving account of HD wallet %s", id)
+	}
+	return newHDAccounts(derived)
+}
+
+// newHDAccounts returns the response of the accounts derived from an HD wallet
+func newHDAccounts(derived []wallet.HDAccount) ([]rpctypes.Personal_HDAccount, error) {
+	accounts := make([]rpctypes.Personal_HDAccount, 0, len(derived))
+	for _, account := range derived {
+		qtumAddr, err := account.Wallet.GetQtumAddress()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error getting qtum address of account %s", account.Wallet.GetEthereumAddress())
+		}
+		accounts = append(accounts, rpctypes.Personal_HDAccount{
+			Address:     *account.Wallet.GetEthereumAddress(),
+			QtumAddress: qtumAddr,
+			Path:        account.Path,
+		})
+	}
+	return accounts, nil
+}
@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportHDWallet(t *testing.T) {
+	const mnemonic = "test test test test test test test test test test test junk"
+	rpcservice, err := getPersonalRPCService()
+	utils.HandleFatalError(t, err)
+	testserver := httptest.NewServer(rpcservice)
+	defer testserver.Close()
+	client := &http.Client{}
+	ws := wallet.GetWallets()
+
+	httpReq, err := createRPCRequest(testserver.URL, "personal_importHDWallet", mnemonic, WALLET_PASSPHRASE, "0x1")
+	utils.HandleFatalError(t, err)
+	httpResp, err := client.Do(httpReq)
+	utils.HandleFatalError(t, err)
+	var imported rpctypes.Personal_ImportHDWalletResponse
+	utils.HandleFatalError(t, utils.ReadJSONResult(httpResp, &imported))
+	assert.NotEmpty(t, imported.ID)
+	assert.Len(t, imported.Accounts, 2)
+	for _, account := range imported.Accounts {
+		account := account
+		t.Cleanup(func() { ws.DeleteWallet(account.Address.String(), "") })
+	}
+	assert.Equal(t, "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", imported.Accounts[0].Address.String())
+	assert.Equal(t, "m/44'/60'/0'/0/0", imported.Accounts[0].Path)
+	assert.Equal(t, "m/88'/0'/0'", imported.Accounts[1].Path)
+
+	// the qtum address of every account resolves to its wallet
+	for _, account := range imported.Accounts {
+		w, err := ws.SeekWallet(account.Address.String())
+		utils.HandleFatalError(t, err)
+		qtumAddr, err := w.GetQtumAddress()
+		utils.HandleFatalError(t, err)
+		assert.Equal(t, account.QtumAddress, qtumAddr)
+	}
+
+	httpReq, err = createRPCRequest(testserver.URL, "personal_deriveAccount", imported.ID, WALLET_PASSPHRASE)
+	utils.HandleFatalError(t, err)
+	httpResp, err = client.Do(httpReq)
+	utils.HandleFatalError(t, err)
+	var derived []rpctypes.Personal_HDAccount
+	utils.HandleFatalError(t, utils.ReadJSONResult(httpResp, &derived))
+	assert.Len(t, derived, 2)
+	for _, account := range derived {
+		account := account
+		t.Cleanup(func() { ws.DeleteWallet(account.Address.String(), "") })
+	}
+	assert.Equal(t, "0x70997970C51812dc3A010C7d01b50e0d17dc79C8", derived[0].Address.String())
+	assert.Equal(t, "m/88'/0'/1'", derived[1].Path)
+}
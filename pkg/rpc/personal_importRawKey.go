@@ -8,19 +8,17 @@ import (
 
 // RPC Method: personal_importRawKey
 // Imports the given unencrypted private key (hex string) into
-// the wallet
+// the wallet, persisted in the keystore encrypted with the passphrase
 //
 // Returns the ethereum address of the new account.
 func (api *PersonalAPI) ImportRawKey(keydata string, passphrase string) (string, error) {
-	log.With("method", "importrawkey").Debugf("ImportRawKey called with req.KeyData: %s, req.Passphrase: %s", keydata, passphrase)
-
-	// TODO: implement btcd wallets for persisten storage
+	log.With("method", "importrawkey").Debugf("ImportRawKey called")
 
 	ws := wallet.GetWallets()
 
-	w, err := ws.NewWallet(keydata, api.cfg)
+	w, err := ws.NewWallet(keydata, passphrase, api.cfg)
 	if err != nil {
-		return "", errors.Wrapf(err, "Error importing raw key: %s", keydata)
+		return "", errors.Wrap(err, "Error importing raw key")
 	}
 
 	return w.GetEthereumAddress().String(), nil
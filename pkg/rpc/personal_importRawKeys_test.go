@@ -46,5 +46,18 @@ func TestImportRawKey(t *testing.T) {
 		assert.NoError(err)
 		assert.NotNil(w)
 	})
+	t.Run("Verify error does not contain private key", func(t *testing.T) {
+		httpReq, err := createRPCRequest(testserver.URL, "personal_importRawKey", PRIVATEKEY_HEX, WALLET_PASSPHRASE)
+		utils.HandleFatalError(t, err)
+
+		httpResp, err := client.Do(httpReq)
+		utils.HandleFatalError(t, err)
+
+		var got string
+		err = utils.ReadJSONResult(httpResp, &got)
+		if assert.Error(err) {
+			assert.NotContains(err.Error(), PRIVATEKEY_HEX)
+		}
+	})
 
 }
@@ -0,0 +1,20 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RPC Method: personal_listAccounts
+// Returns the ethereum addresses of the wallets, locked or not
+func (api *PersonalAPI) ListAccounts() ([]common.Address, error) {
+	log.With("method", "listaccounts").Debugf("ListAccounts called")
+
+	addresses, err := wallet.GetWallets().ListAccounts()
+	if err != nil {
+		log.With("method", "listaccounts").Debugf(err.Error())
+		return nil, err
+	}
+	return addresses, nil
+}
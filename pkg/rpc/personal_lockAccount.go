@@ -0,0 +1,22 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// RPC Method: personal_lockAccount
+// Removes the private key of the keystore wallet of the address from memory
+//
+// Returns false if the address has no wallet in the keystore.
+func (api *PersonalAPI) LockAccount(address common.Address) bool {
+	log.With("method", "lockaccount").Debugf("LockAccount called with address: %s", address)
+
+	err := wallet.GetWallets().LockWallet(address.String())
+	if err != nil {
+		log.With("method", "lockaccount").Debugf(err.Error())
+		return false
+	}
+	return true
+}
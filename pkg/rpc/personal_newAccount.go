@@ -0,0 +1,25 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+// RPC Method: personal_newAccount
+// Creates a wallet for a new random private key, persisted in the
+// keystore encrypted with the passphrase
+//
+// Returns the ethereum address of the new account.
+func (api *PersonalAPI) NewAccount(passphrase string) (common.Address, error) {
+	log.With("method", "newaccount").Debugf("NewAccount called")
+
+	w, err := wallet.GetWallets().NewAccount(passphrase, api.cfg)
+	if err != nil {
+		log.With("method", "newaccount").Debugf(err.Error())
+		return common.Address{}, errors.Wrap(err, "Error creating new account")
+	}
+	log.With("method", "newaccount").Debugf("Created account %s", w.GetEthereumAddress())
+	return *w.GetEthereumAddress(), nil
+}
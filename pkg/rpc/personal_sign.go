@@ -0,0 +1,31 @@
+package rpc
+
+import (
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+// RPC Method: personal_sign
+// Signs the data as eth_sign does, with the wallet of the address decrypted
+// with the passphrase. The wallet is not unlocked, and wallets with a
+// policy are not allowed to sign
+//
+// Returns the ethereum signature [R || S || V], with V 27 or 28.
+func (api *PersonalAPI) Sign(data hexutil.Bytes, address common.Address, passphrase string) (hexutil.Bytes, error) {
+	log.With("method", "personalsign").Debugf("Sign called for address: %s", address)
+
+	err := authorizeSign(address, "personal_sign")
+	if err != nil {
+		return nil, err
+	}
+	w, err := wallet.GetWallets().OpenWallet(address.String(), passphrase, api.cfg)
+	if err != nil {
+		log.With("method", "personalsign").Debugf(err.Error())
+		return nil, errors.Wrapf(err, "Error loading wallet for address: %s", address)
+	}
+	return signEthereumHash(w, accounts.TextHash(data))
+}
@@ -0,0 +1,39 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultUnlockDuration is the unlock duration when none is given
+	defaultUnlockDuration = 300 * time.Second
+	// maxUnlockDuration is the maximum unlock duration in seconds
+	maxUnlockDuration = uint64(1<<63-1) / uint64(time.Second)
+)
+
+// RPC Method: personal_unlockAccount
+// Decrypts the keystore wallet of the address with the passphrase, so it can
+// sign transactions for the given duration in seconds (300 if not given).
+// A duration of 0 keeps the wallet unlocked until personal_lockAccount is called
+func (api *PersonalAPI) UnlockAccount(address common.Address, passphrase string, duration *uint64) (bool, error) {
+	log.With("method", "unlockaccount").Debugf("UnlockAccount called with address: %s", address)
+
+	d := defaultUnlockDuration
+	if duration != nil {
+		if *duration > maxUnlockDuration {
+			return false, errors.New("unlock duration too large")
+		}
+		d = time.Duration(*duration) * time.Second
+	}
+	err := wallet.GetWallets().UnlockWallet(address.String(), passphrase, d, api.cfg)
+	if err != nil {
+		log.With("method", "unlockaccount").Debugf(err.Error())
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/alejoacosta74/qproxy/pkg/internal/mocks"
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// setTestKeystore sets a keystore in a temporary directory for the test
+func setTestKeystore(t *testing.T) {
+	t.Helper()
+	ks, err := wallet.NewKeystore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	utils.HandleFatalError(t, err)
+	wallet.SetKeystore(ks)
+	t.Cleanup(func() { wallet.SetKeystore(nil) })
+}
+
+func TestPersonalAccounts(t *testing.T) {
+	setTestKeystore(t)
+	api := NewAPI(context.Background(), nil)
+	api.SetNetworkParams(cfg)
+	personalAPI := (*PersonalAPI)(api)
+
+	address, err := personalAPI.NewAccount(WALLET_PASSPHRASE)
+	utils.HandleFatalError(t, err)
+	accounts, err := personalAPI.ListAccounts()
+	utils.HandleFatalError(t, err)
+	assert.Contains(t, accounts, address)
+
+	// new accounts are locked
+	_, err = wallet.GetWallets().SeekWallet(address.String())
+	assert.ErrorIs(t, err, wallet.ErrWalletLocked)
+
+	_, err = personalAPI.UnlockAccount(address, "wrong", nil)
+	assert.Error(t, err)
+	ok, err := personalAPI.UnlockAccount(address, WALLET_PASSPHRASE, nil)
+	utils.HandleFatalError(t, err)
+	assert.True(t, ok)
+	_, err = wallet.GetWallets().SeekWallet(address.String())
+	assert.NoError(t, err)
+
+	assert.True(t, personalAPI.LockAccount(address))
+	_, err = wallet.GetWallets().SeekWallet(address.String())
+	assert.ErrorIs(t, err, wallet.ErrWalletLocked)
+
+	// wallets not in the keystore can not be locked
+	unknown, err := crypto.GenerateKey()
+	utils.HandleFatalError(t, err)
+	assert.False(t, personalAPI.LockAccount(crypto.PubkeyToAddress(unknown.PublicKey)))
+}
+
+func TestSendRawTxLockedWallet(t *testing.T) {
+	setTestKeystore(t)
+	mockQcli := mocks.NewMockQCli()
+	api := NewAPI(context.Background(), mockQcli)
+	api.SetNetworkParams(cfg)
+	ethAPI := (*EthAPI)(api)
+	personalAPI := (*PersonalAPI)(api)
+
+	privKey, err := crypto.GenerateKey()
+	utils.HandleFatalError(t, err)
+	privKeyHex := hex.EncodeToString(crypto.FromECDSA(privKey))
+	address, err := personalAPI.ImportRawKey(privKeyHex, WALLET_PASSPHRASE)
+	utils.HandleFatalError(t, err)
+
+	signedTx, err := signEthereumTx(newEthereumTx(), types.HomesteadSigner{}, privKeyHex)
+	utils.HandleFatalError(t, err)
+	rawTx, err := signedTx.MarshalBinary()
+	utils.HandleFatalError(t, err)
+
+	_, err = ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+	assert.ErrorIs(t, err, wallet.ErrWalletLocked)
+
+	duration := uint64(60)
+	_, err = personalAPI.UnlockAccount(crypto.PubkeyToAddress(privKey.PublicKey), WALLET_PASSPHRASE, &duration)
+	utils.HandleFatalError(t, err)
+	_, err = ethAPI.SendRawTransaction(hex.EncodeToString(rawTx))
+	assert.NoError(t, err)
+
+	assert.NoError(t, wallet.GetWallets().DeleteWallet(address, WALLET_PASSPHRASE))
+}
@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"math/big"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	rpctypes "github.com/alejoacosta74/qproxy/pkg/rpc/types"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	qcommon "github.com/qtumproject/qtool/lib/common"
+	qtool "github.com/qtumproject/qtool/lib/tools"
+)
+
+// weiPerSatoshi is the conversion factor between wei and satoshis
+// (1 qtum = 10^8 satoshis and 1 eth = 10^18 wei)
+var weiPerSatoshi = big.NewInt(10000000000)
+
+// convertGasPriceToSatoshis converts an ethereum gas price in wei to a qtum
+// gas price in satoshis. Gas prices below the qtum minimum are raised to it.
+func convertGasPriceToSatoshis(gasPrice *big.Int) (uint64, error) {
+	satoshis := new(big.Int).Quo(gasPrice, weiPerSatoshi)
+	if !satoshis.IsUint64() {
+		return 0, errors.Errorf("gas price out of range: %v", gasPrice)
+	}
+	if satoshis.Uint64() < qtypes.MinGasPrice {
+		log.With("module", "qtumtools").Debugf("Gas price %v wei is below the qtum minimum. Using %d satoshis", gasPrice, qtypes.MinGasPrice)
+		return qtypes.MinGasPrice, nil
+	}
+	return satoshis.Uint64(), nil
+}
+
+// ethTx holds the values of an ethereum transaction (or call request)
+// that are translated into a qtum transaction. The fee caps are only
+// set for dynamic fee transactions
+type ethTx struct {
+	to        *common.Address
+	value     *big.Int
+	gas       uint64
+	gasPrice  *big.Int
+	gasFeeCap *big.Int
+	gasTipCap *big.Int
+	data      []byte
+}
+
+// newEthTx returns the values to translate from a signed ethereum transaction
+func newEthTx(tx *types.Transaction) *ethTx {
+	ethTx := &ethTx{
+		to:       tx.To(),
+		value:    tx.Value(),
+		gas:      tx.Gas(),
+		gasPrice: tx.GasPrice(),
+		data:     tx.Data(),
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		ethTx.gasFeeCap = tx.GasFeeCap()
+		ethTx.gasTipCap = tx.GasTipCap()
+	}
+	return ethTx
+}
+
+// effectiveGasPrice returns the gas price in wei paid by the transaction, given the
+// gas price advertised by the proxy (the base fee). Legacy and access list transactions
+// pay their gas price, while dynamic fee transactions pay the base fee plus their
+// priority fee, capped by their max fee
+func (tx *ethTx) effectiveGasPrice(baseFee *big.Int) *big.Int {
+	if tx.gasFeeCap == nil {
+		return tx.gasPrice
+	}
+	return math.BigMin(new(big.Int).Add(baseFee, tx.gasTipCap), tx.gasFeeCap)
+}
+
+// contractGasPrice returns the gas price in wei paid by the qtum contract of the
+// transaction, given the gas price advertised by the proxy (the base fee) and the
+// part of it paying for the utxo transaction (see getGasPrices). The utxo fee is paid
+// by the qtum transaction fee, so it is taken out of the effective gas price
+func (tx *ethTx) contractGasPrice(baseFee, utxoFee *big.Int) *big.Int {
+	gasPrice := new(big.Int).Sub(tx.effectiveGasPrice(baseFee), utxoFee)
+	if gasPrice.Sign() < 0 {
+		return new(big.Int)
+	}
+	return gasPrice
+}
+
+// newEthTxFromCallRequest returns the values to translate from an eth_call like request.
+// Missing gas and gas price are left as zero values
+func newEthTxFromCallRequest(req *rpctypes.Eth_CallRequest) *ethTx {
+	tx := &ethTx{
+		to:       req.To,
+		value:    new(big.Int),
+		gasPrice: new(big.Int),
+		data:     req.GetData(),
+	}
+	if req.Value != nil {
+		tx.value = req.Value.ToInt()
+	}
+	if req.Gas != nil {
+		tx.gas = uint64(*req.Gas)
+	}
+	if req.GasPrice != nil {
+		tx.gasPrice = req.GasPrice.ToInt()
+	}
+	return tx
+}
+
+// isContract returns true if the ethereum transaction creates
+// a contract (no receiver) or calls a contract (receiver and data)
+func (tx *ethTx) isContract() bool {
+	return tx.to == nil || len(tx.data) > 0
+}
+
+// amount returns the value of the transaction in Qtum
+func (tx *ethTx) amount() (float64, error) {
+	amount, err := qcommon.ConvertWeiToQtum(hexutil.EncodeBig(tx.value))
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error converting amount to float: %v", tx.value)
+	}
+	return amount, nil
+}
+
+// contractParams returns the qtum contract params (gas limit, gas price,
+// EVM data and contract address) for the ethereum transaction, given the
+// gas price advertised by the proxy and its utxo fee (see contractGasPrice).
+// The params are validated, so their gas fee can be computed
+func (tx *ethTx) contractParams(baseFee, utxoFee *big.Int) (*qtypes.ContractParams, error) {
+	gasPrice, err := convertGasPriceToSatoshis(tx.contractGasPrice(baseFee, utxoFee))
+	if err != nil {
+		return nil, err
+	}
+	params := &qtypes.ContractParams{
+		GasLimit: tx.gas,
+		GasPrice: gasPrice,
+		Data:     tx.data,
+	}
+	if tx.to != nil {
+		params.Address = hex.EncodeToString(tx.to.Bytes())
+	}
+	err = params.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid contract params")
+	}
+	return params, nil
+}
+
+// getQtumSender returns the qtum address in base58 format used as sender for the
+// given ethereum address: the address of the proxy wallet owning it, if any, or else
+// the ethereum address converted to base58
+func (api *API) getQtumSender(from common.Address) (string, error) {
+	if w, err := wallet.GetWallets().SeekWallet(from.String()); err == nil {
+		return w.GetQtumAddress()
+	}
+	sender, err := qtool.AddressHexToBase58(from.String(), api.cfg)
+	if err != nil {
+		return "", errors.Wrapf(err, "Error converting sender address to base58: %s", from.String())
+	}
+	return sender, nil
+}
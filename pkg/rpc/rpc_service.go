@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"math/big"
+	"sync"
+	"time"
 
 	"github.com/alejoacosta74/qproxy/pkg/log"
 	"github.com/alejoacosta74/qproxy/pkg/qtum"
+	qtypes "github.com/alejoacosta74/qproxy/pkg/qtum/types"
+	"github.com/alejoacosta74/qproxy/pkg/store"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/pkg/errors"
 	"github.com/qtumproject/btcd/chaincfg"
@@ -22,7 +27,11 @@ func NewRPCService() *RPCService {
 	return &RPCService{rpc.NewServer()}
 }
 
-func NewEthereumRPCService(network string, qcli qtum.Iqcli) (*rpc.Server, error) {
+// NewEthereumRPCService creates the RPC service of the eth, personal and net namespaces
+// for the given network. The chain id of the network is used unless chainID is not zero.
+// The unspent outputs of the wallets are consolidated in the background if the
+// consolidation interval is not zero
+func NewEthereumRPCService(network string, qcli qtum.Iqcli, txStore store.TxStore, gasPriceCacheTTL time.Duration, chainID uint64, consolidation ConsolidationConfig) (*rpc.Server, error) {
 	service := rpc.NewServer()
 	api := NewAPI(context.Background(), qcli)
 	cfg, err := getNetworkConfig(network)
@@ -30,6 +39,19 @@ func NewEthereumRPCService(network string, qcli qtum.Iqcli) (*rpc.Server, error)
 		return nil, err
 	}
 	api.SetNetworkParams(cfg)
+	if chainID == 0 {
+		chainID, err = getChainID(network)
+		if err != nil {
+			return nil, err
+		}
+	}
+	api.SetChainID(chainID)
+	api.SetTxStore(txStore)
+	api.SetGasPriceCacheTTL(gasPriceCacheTTL)
+	err = api.SetConsolidationConfig(consolidation)
+	if err != nil {
+		return nil, err
+	}
 	ethAPI := (*EthAPI)(api)
 	err = service.RegisterName("eth", ethAPI)
 	if err != nil {
@@ -46,19 +68,40 @@ func NewEthereumRPCService(network string, qcli qtum.Iqcli) (*rpc.Server, error)
 	if err != nil {
 		return nil, errors.Wrap(err, "error registering net namespace")
 	}
+	if consolidation.Interval > 0 {
+		go api.consolidateWallets()
+	}
 	return service, nil
 }
 
 type API struct {
-	ctx  context.Context
-	qcli qtum.Iqcli
-	cfg  *chaincfg.Params
+	ctx     context.Context
+	qcli    qtum.Iqcli
+	cfg     *chaincfg.Params
+	txStore store.TxStore
+	chainID *big.Int
+	// sendMu serializes the transactions sent, so a nonce
+	// is checked and recorded before the next one is checked
+	sendMu        sync.Mutex
+	gasPrice      gasPriceCache
+	filters       *filterManager
+	subscriptions *subscriptionManager
+	consolidation ConsolidationConfig
 }
 
+// NewAPI creates a new API instance. Broadcast transactions are recorded
+// in memory until a persistent store is set with SetTxStore, and the
+// chain id is the regtest one until it is set with SetChainID
 func NewAPI(ctx context.Context, qcli qtum.Iqcli) *API {
 	return &API{
-		ctx:  ctx,
-		qcli: qcli,
+		ctx:           ctx,
+		qcli:          qcli,
+		txStore:       store.NewMemoryStore(),
+		chainID:       big.NewInt(RegtestChainID),
+		gasPrice:      gasPriceCache{ttl: DefaultGasPriceCacheTTL},
+		filters:       newFilterManager(),
+		subscriptions: newSubscriptionManager(),
+		consolidation: DefaultConsolidationConfig(),
 	}
 }
 
@@ -67,6 +110,24 @@ func (api *API) SetNetworkParams(cfg *chaincfg.Params) {
 	api.cfg = cfg
 }
 
+// SetTxStore sets the store where the broadcast transactions are recorded
+func (api *API) SetTxStore(txStore store.TxStore) {
+	api.txStore = txStore
+}
+
+// SetChainID sets the chain id of the transactions accepted by the API
+func (api *API) SetChainID(chainID uint64) {
+	api.chainID = new(big.Int).SetUint64(chainID)
+}
+
+// SetGasPriceCacheTTL sets the time the gas price is cached
+func (api *API) SetGasPriceCacheTTL(ttl time.Duration) {
+	api.gasPrice.mu.Lock()
+	defer api.gasPrice.mu.Unlock()
+	api.gasPrice.ttl = ttl
+	api.gasPrice.price = nil
+}
+
 type NetAPI API
 type EthAPI API
 type PersonalAPI API
@@ -95,11 +156,11 @@ func (api *EthAPI) printQtumDecodedTX(qtumTx *wire.MsgTx, msg string) {
 func getNetworkConfig(network string) (*chaincfg.Params, error) {
 	switch network {
 	case "regtest":
-		return &chaincfg.QtumTestnetParams, nil
+		return &qtypes.QtumRegtestParams, nil
 	case "testnet":
-		return &chaincfg.QtumTestnetParams, nil
+		return &qtypes.QtumTestnetParams, nil
 	case "mainnet":
-		return &chaincfg.QtumMainnetParams, nil
+		return &qtypes.QtumMainnetParams, nil
 	default:
 		return nil, errors.New("Invalid network: " + network)
 	}
@@ -8,10 +8,12 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
+	"testing"
 
 	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
 	"github.com/alejoacosta74/qproxy/pkg/log"
 	"github.com/alejoacosta74/qproxy/pkg/qtum"
+	"github.com/alejoacosta74/qproxy/pkg/wallet"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -78,6 +80,32 @@ func newEthereumTx() *types.Transaction {
 	return tx
 }
 
+// newContractCreationTx() creates a new unsigned ethereum transaction
+// deploying a contract with the given bytecode.
+func newContractCreationTx(bytecode []byte) *types.Transaction {
+	nonce := uint64(0)
+	gasPrice := big.NewInt(400000000000)
+	gasLimit := uint64(2500000)
+	amount := big.NewInt(0)
+	tx := types.NewContractCreation(nonce, amount, gasLimit, gasPrice, bytecode)
+	return tx
+}
+
+// loadTestWallet() returns the wallet for the given private key,
+// creating it if it has not been imported yet.
+func loadTestWallet(t *testing.T, privKeyHex string) wallet.IQtumWallet {
+	t.Helper()
+	ws := wallet.GetWallets()
+	address, err := wallet.PrivKeyToEthAddress(privKeyHex)
+	utils.HandleFatalError(t, err)
+	if w, err := ws.SeekWallet(address.String()); err == nil {
+		return w
+	}
+	w, err := ws.NewWallet(privKeyHex, "", utils.GetNetworkParams())
+	utils.HandleFatalError(t, err)
+	return w
+}
+
 // signEthereumTx() signs an ethereum transaction with a private key.
 func signEthereumTx(tx *types.Transaction, signer types.Signer, privKeyHex string) (*types.Transaction, error) {
 	privKey, err := crypto.HexToECDSA(privKeyHex)
@@ -5,27 +5,42 @@ import (
 	"math/big"
 
 	// local "github.com/alejoacosta74/qproxy/pkg/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
 )
 
 // This file contains the types used for the RPC calls
 
 // RPC Method: eth_getTransactionByHash
+//
+// The block fields are null while the transaction is pending
+// and the receiver is null for contract creations. The chain id and
+// access list are only set for typed transactions, and the fee caps
+// for dynamic fee transactions
 type Eth_GetTransactionByHashResponse struct {
-	BlockHash        string `json:"blockHash"`
-	BlockNumber      string `json:"blockNumber"`
-	From             string `json:"from"`
-	Gas              string `json:"gas"`
-	GasPrice         string `json:"gasPrice"`
-	Hash             string `json:"hash"`
-	Input            string `json:"input"`
-	Nonce            string `json:"nonce"`
-	To               string `json:"to"`
-	TransactionIndex string `json:"transactionIndex"`
-	Value            string `json:"value"`
-	V                string `json:"v"`
-	R                string `json:"r"`
-	S                string `json:"s"`
+	BlockHash        *string `json:"blockHash"`
+	BlockNumber      *string `json:"blockNumber"`
+	From             string  `json:"from"`
+	Gas              string  `json:"gas"`
+	GasPrice         string  `json:"gasPrice"`
+	Hash             string  `json:"hash"`
+	Input            string  `json:"input"`
+	Nonce            string  `json:"nonce"`
+	To               *string `json:"to"`
+	TransactionIndex *string `json:"transactionIndex"`
+	Value            string  `json:"value"`
+	Type             string  `json:"type"`
+	V                string  `json:"v"`
+	R                string  `json:"r"`
+	S                string  `json:"s"`
+
+	ChainId              *string           `json:"chainId,omitempty"`
+	AccessList           *types.AccessList `json:"accessList,omitempty"`
+	MaxFeePerGas         *string           `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *string           `json:"maxPriorityFeePerGas,omitempty"`
 }
 
 // RPC Method: eth_sendRawTransaction
@@ -35,6 +50,12 @@ type Eth_SendRawTransactionRequest struct {
 
 type Eth_SendRawTransactionResponse struct {
 	Hash string `json:"hash"`
+	// ContractAddress is the address of the contract to be created
+	// by the transaction, if any
+	ContractAddress string `json:"contractAddress,omitempty"`
+	// DroppedChange is the change in Qtum paid as fee because it was
+	// lower than the dust threshold or the cost of spending it, if any
+	DroppedChange float64 `json:"droppedChange,omitempty"`
 }
 
 type RawTransaction struct {
@@ -73,7 +94,7 @@ func (tx *RlpDecodedRawTransaction) Unmarshal() (*RawTransaction, error) {
 	if err != nil {
 		return nil, err
 	}
-	msg, err := tx.AsMessage(types.NewEIP155Signer(tx.ChainId()), big.NewInt(1))
+	msg, err := tx.AsMessage(types.LatestSignerForChainID(tx.ChainId()), big.NewInt(1))
 	if err != nil {
 		return nil, err
 	}
@@ -96,3 +117,199 @@ type Net_VersionResponse string
 type Eth_GetTransactionCountResponse struct {
 	Count uint64 `json:"count"`
 }
+
+// RPC Method: eth_call
+type Eth_CallRequest struct {
+	From     *common.Address `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      *hexutil.Uint64 `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Data     *hexutil.Bytes  `json:"data"`
+	Input    *hexutil.Bytes  `json:"input"`
+}
+
+// GetData returns the call data, giving precedence to the "input" field
+// over the legacy "data" field
+func (req *Eth_CallRequest) GetData() []byte {
+	if req.Input != nil {
+		return *req.Input
+	}
+	if req.Data != nil {
+		return *req.Data
+	}
+	return nil
+}
+
+// RPC Method: eth_sendTransaction
+//
+// The call object of the transaction along with its nonce
+type Eth_SendTransactionRequest struct {
+	Eth_CallRequest
+	Nonce *hexutil.Uint64 `json:"nonce"`
+}
+
+// RPC Method: eth_getTransactionReceipt
+type Eth_GetTransactionReceiptResponse struct {
+	TransactionHash   common.Hash     `json:"transactionHash"`
+	TransactionIndex  hexutil.Uint64  `json:"transactionIndex"`
+	BlockHash         common.Hash     `json:"blockHash"`
+	BlockNumber       *hexutil.Big    `json:"blockNumber"`
+	From              common.Address  `json:"from"`
+	To                *common.Address `json:"to"`
+	CumulativeGasUsed hexutil.Uint64  `json:"cumulativeGasUsed"`
+	EffectiveGasPrice *hexutil.Big    `json:"effectiveGasPrice"`
+	GasUsed           hexutil.Uint64  `json:"gasUsed"`
+	ContractAddress   *common.Address `json:"contractAddress"`
+	Logs              []*Eth_Log      `json:"logs"`
+	LogsBloom         hexutil.Bytes   `json:"logsBloom"`
+	Type              hexutil.Uint64  `json:"type"`
+	Status            hexutil.Uint64  `json:"status"`
+}
+
+// Eth_Log is a log emitted by a contract execution, as returned by
+// eth_getTransactionReceipt
+type Eth_Log struct {
+	Address          common.Address `json:"address"`
+	Topics           []common.Hash  `json:"topics"`
+	Data             hexutil.Bytes  `json:"data"`
+	BlockNumber      *hexutil.Big   `json:"blockNumber"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+	LogIndex         hexutil.Uint64 `json:"logIndex"`
+	Removed          bool           `json:"removed"`
+}
+
+// RPC Method: eth_feeHistory
+type Eth_FeeHistoryResponse struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// RPC Method: eth_getBlockByNumber and eth_getBlockByHash
+//
+// Transactions holds either the transaction hashes or the
+// Eth_GetTransactionByHashResponse of the transactions
+type Eth_BlockResponse struct {
+	Eth_BlockHeader
+	Transactions []interface{} `json:"transactions"`
+	Uncles       []string      `json:"uncles"`
+}
+
+// Subscription: newHeads
+type Eth_BlockHeader struct {
+	Number           string `json:"number"`
+	Hash             string `json:"hash"`
+	ParentHash       string `json:"parentHash"`
+	Nonce            string `json:"nonce"`
+	MixHash          string `json:"mixHash"`
+	Sha3Uncles       string `json:"sha3Uncles"`
+	LogsBloom        string `json:"logsBloom"`
+	StateRoot        string `json:"stateRoot"`
+	Miner            string `json:"miner"`
+	Difficulty       string `json:"difficulty"`
+	TotalDifficulty  string `json:"totalDifficulty"`
+	ExtraData        string `json:"extraData"`
+	Size             string `json:"size"`
+	GasLimit         string `json:"gasLimit"`
+	GasUsed          string `json:"gasUsed"`
+	Timestamp        string `json:"timestamp"`
+	TransactionsRoot string `json:"transactionsRoot"`
+	ReceiptsRoot     string `json:"receiptsRoot"`
+}
+
+// RPC Method: eth_getLogs and eth_newFilter
+//
+// The address can be a single address or a list of addresses, and each
+// topic position a single topic, a list of alternative topics or null
+type Eth_FilterRequest struct {
+	BlockHash *common.Hash
+	FromBlock *rpc.BlockNumber
+	ToBlock   *rpc.BlockNumber
+	Addresses []common.Address
+	Topics    [][]common.Hash
+}
+
+// UnmarshalJSON decodes the filter request accepting single values
+// or lists for the address and topic positions
+func (req *Eth_FilterRequest) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		BlockHash *common.Hash      `json:"blockHash"`
+		FromBlock *rpc.BlockNumber  `json:"fromBlock"`
+		ToBlock   *rpc.BlockNumber  `json:"toBlock"`
+		Address   json.RawMessage   `json:"address"`
+		Topics    []json.RawMessage `json:"topics"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.BlockHash != nil && (raw.FromBlock != nil || raw.ToBlock != nil) {
+		return errors.New("cannot specify both blockHash and fromBlock/toBlock")
+	}
+	req.BlockHash = raw.BlockHash
+	req.FromBlock = raw.FromBlock
+	req.ToBlock = raw.ToBlock
+
+	addresses, err := unmarshalOneOrMany[common.Address](raw.Address)
+	if err != nil {
+		return errors.Wrap(err, "invalid address")
+	}
+	req.Addresses = addresses
+
+	req.Topics = make([][]common.Hash, len(raw.Topics))
+	for i, rawTopic := range raw.Topics {
+		topics, err := unmarshalOneOrMany[common.Hash](rawTopic)
+		if err != nil {
+			return errors.Wrapf(err, "invalid topic %d", i)
+		}
+		req.Topics[i] = topics
+	}
+	return nil
+}
+
+// unmarshalOneOrMany decodes null, a single value or a list of values
+func unmarshalOneOrMany[T any](data json.RawMessage) ([]T, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var many []T
+	if err := json.Unmarshal(data, &many); err == nil {
+		return many, nil
+	}
+	var one T
+	if err := json.Unmarshal(data, &one); err != nil {
+		return nil, err
+	}
+	return []T{one}, nil
+}
+
+// RPC Method: personal_importHDWallet and personal_deriveAccount
+//
+// An account derived from an HD wallet along the path,
+// with the qtum address of its key
+type Personal_HDAccount struct {
+	Address     common.Address `json:"address"`
+	QtumAddress string         `json:"qtumAddress"`
+	Path        string         `json:"path"`
+}
+
+// RPC Method: personal_importHDWallet
+type Personal_ImportHDWalletResponse struct {
+	ID       string               `json:"id"`
+	Accounts []Personal_HDAccount `json:"accounts"`
+}
+
+// RPC Method: personal_consolidate
+//
+// The qtum transaction merging unspent outputs of a wallet into
+// a single output paid to its qtum address, with amounts in Qtum
+type Personal_ConsolidateResponse struct {
+	TxID        string  `json:"txid"`
+	QtumAddress string  `json:"qtumAddress"`
+	Inputs      int     `json:"inputs"`
+	Amount      float64 `json:"amount"`
+	Fee         float64 `json:"fee"`
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/alejoacosta74/qproxy/pkg/log"
 	"github.com/alejoacosta74/qproxy/pkg/qtum"
+	"github.com/alejoacosta74/qproxy/pkg/store"
 
 	"github.com/gorilla/mux"
 )
@@ -21,19 +22,23 @@ type Server struct {
 	address string
 }
 
-func NewServer(localAddress string, backendUrl string, qcli qtum.Iqcli, network string) (*Server, error) {
+func NewServer(localAddress string, backendUrl string, qcli qtum.Iqcli, network string, txStore store.TxStore, gasPriceCacheTTL time.Duration, chainID uint64, consolidation rpc.ConsolidationConfig, wsOrigins []string) (*Server, error) {
 	ctx := context.Background()
 
 	router := mux.NewRouter()
 
 	//Create new RPC service and assign /rpc the endpoint
-	rpcService, err := rpc.NewEthereumRPCService(network, qcli)
+	rpcService, err := rpc.NewEthereumRPCService(network, qcli, txStore, gasPriceCacheTTL, chainID, consolidation)
 	if err != nil {
 		return nil, err
 	}
 
 	router.Handle("/rpc", rpcService).Methods("POST")
 
+	//Serve the same RPC service over websockets on the /ws endpoint, for eth_subscribe.
+	//Browsers can only connect from the allowed origins (localhost if none)
+	router.Handle("/ws", rpcService.WebsocketHandler(wsOrigins)).Methods("GET")
+
 	//Create new proxy handler and assign /proxy the endpoint
 	proxyHandler, err := handlers.NewProxyHandler(backendUrl, ctx)
 	if err != nil {
@@ -60,6 +65,7 @@ func (s *Server) Start() error {
 	log.With("module", "server").Infof("Starting server on port: %s", s.address)
 	log.With("module", "server").Infof("proxy available on: %s ", s.address+"/proxy")
 	log.With("module", "server").Infof("eth jsonrpc server available on: %s ", s.address+"/rpc")
+	log.With("module", "server").Infof("eth websocket server available on: %s ", s.address+"/ws")
 	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// txsBucket maps eth hashes to the JSON encoded transaction records
+	txsBucket = []byte("txs")
+	// qtumTxIDsBucket maps qtum txids to eth hashes
+	qtumTxIDsBucket = []byte("qtumtxids")
+	// senderNoncesBucket maps sender addresses followed by big endian nonces to eth hashes
+	senderNoncesBucket = []byte("sendernonces")
+	// noncesBucket maps sender addresses to their big endian next nonce
+	noncesBucket = []byte("nonces")
+)
+
+// BoltStore is a TxStore backed by an embedded BoltDB file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (creating it if needed) the BoltDB store at the given path
+func NewBoltStore(path string) (*BoltStore, error) {
+	log.With("module", "store").Debugf("Opening bolt store at: %s", path)
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error opening bolt store: %s", path)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{txsBucket, qtumTxIDsBucket, senderNoncesBucket, noncesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return errors.Wrapf(err, "Error creating bucket: %s", bucket)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// PutTx implements TxStore
+func (s *BoltStore) PutTx(record *TxRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrapf(err, "Error encoding tx record: %s", record.EthHash)
+	}
+	ethHash := normalizeHash(record.EthHash)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(txsBucket).Put([]byte(ethHash), value)
+		if err != nil {
+			return errors.Wrapf(err, "Error storing tx record: %s", record.EthHash)
+		}
+		err = tx.Bucket(qtumTxIDsBucket).Put([]byte(normalizeHash(record.QtumTxID)), []byte(ethHash))
+		if err != nil {
+			return errors.Wrapf(err, "Error storing qtum txid: %s", record.QtumTxID)
+		}
+		if record.From == "" {
+			return nil
+		}
+		from := normalizeHash(record.From)
+		err = tx.Bucket(senderNoncesBucket).Put(senderNonceKey(from, record.Nonce), []byte(ethHash))
+		if err != nil {
+			return errors.Wrapf(err, "Error storing nonce %d of sender: %s", record.Nonce, record.From)
+		}
+		if record.Nonce < getNonce(tx, from) {
+			return nil
+		}
+		err = tx.Bucket(noncesBucket).Put([]byte(from), encodeNonce(record.Nonce+1))
+		if err != nil {
+			return errors.Wrapf(err, "Error storing next nonce of sender: %s", record.From)
+		}
+		return nil
+	})
+}
+
+// DeleteTx implements TxStore
+func (s *BoltStore) DeleteTx(ethHash string) error {
+	ethHash = normalizeHash(ethHash)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		record, err := getTx(tx, ethHash)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(txsBucket).Delete([]byte(ethHash))
+		if err != nil {
+			return errors.Wrapf(err, "Error deleting tx record: %s", ethHash)
+		}
+		err = tx.Bucket(qtumTxIDsBucket).Delete([]byte(normalizeHash(record.QtumTxID)))
+		if err != nil {
+			return errors.Wrapf(err, "Error deleting qtum txid: %s", record.QtumTxID)
+		}
+		if record.From == "" {
+			return nil
+		}
+		from := normalizeHash(record.From)
+		err = tx.Bucket(senderNoncesBucket).Delete(senderNonceKey(from, record.Nonce))
+		if err != nil {
+			return errors.Wrapf(err, "Error deleting nonce %d of sender: %s", record.Nonce, record.From)
+		}
+		if record.Nonce+1 != getNonce(tx, from) {
+			return nil
+		}
+		err = tx.Bucket(noncesBucket).Put([]byte(from), encodeNonce(record.Nonce))
+		if err != nil {
+			return errors.Wrapf(err, "Error storing next nonce of sender: %s", record.From)
+		}
+		return nil
+	})
+}
+
+// GetTxByEthHash implements TxStore
+func (s *BoltStore) GetTxByEthHash(ethHash string) (*TxRecord, error) {
+	var record *TxRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, err = getTx(tx, normalizeHash(ethHash))
+		return err
+	})
+	return record, err
+}
+
+// GetTxByQtumTxID implements TxStore
+func (s *BoltStore) GetTxByQtumTxID(txid string) (*TxRecord, error) {
+	var record *TxRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		ethHash := tx.Bucket(qtumTxIDsBucket).Get([]byte(normalizeHash(txid)))
+		if ethHash == nil {
+			return ErrNotFound
+		}
+		var err error
+		record, err = getTx(tx, string(ethHash))
+		return err
+	})
+	return record, err
+}
+
+// GetTxByNonce implements TxStore
+func (s *BoltStore) GetTxByNonce(address string, nonce uint64) (*TxRecord, error) {
+	var record *TxRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		ethHash := tx.Bucket(senderNoncesBucket).Get(senderNonceKey(normalizeHash(address), nonce))
+		if ethHash == nil {
+			return ErrNotFound
+		}
+		var err error
+		record, err = getTx(tx, string(ethHash))
+		return err
+	})
+	return record, err
+}
+
+// GetNonce implements TxStore
+func (s *BoltStore) GetNonce(address string) (uint64, error) {
+	var nonce uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		nonce = getNonce(tx, normalizeHash(address))
+		return nil
+	})
+	return nonce, err
+}
+
+// Close implements TxStore
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// getTx returns the record stored for the eth hash within the bolt transaction
+func getTx(tx *bolt.Tx, ethHash string) (*TxRecord, error) {
+	value := tx.Bucket(txsBucket).Get([]byte(ethHash))
+	if value == nil {
+		return nil, ErrNotFound
+	}
+	var record TxRecord
+	err := json.Unmarshal(value, &record)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error decoding tx record: %s", ethHash)
+	}
+	return &record, nil
+}
+
+// getNonce returns the next nonce stored for the address within the bolt transaction
+func getNonce(tx *bolt.Tx, address string) uint64 {
+	value := tx.Bucket(noncesBucket).Get([]byte(address))
+	if len(value) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(value)
+}
+
+// senderNonceKey returns the key of the senderNonces bucket for the address and nonce
+func senderNonceKey(address string, nonce uint64) []byte {
+	return append([]byte(address), encodeNonce(nonce)...)
+}
+
+// encodeNonce returns the big endian encoding of the nonce
+func encodeNonce(nonce uint64) []byte {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, nonce)
+	return value
+}
+
+// normalizeHash returns the hash in lowercase without 0x prefix, so
+// records can be looked up regardless of the hash format
+func normalizeHash(hash string) string {
+	return strings.TrimPrefix(strings.ToLower(hash), "0x")
+}
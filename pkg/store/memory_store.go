@@ -0,0 +1,112 @@
+package store
+
+import "sync"
+
+// MemoryStore is a non persistent TxStore that keeps the records in memory
+type MemoryStore struct {
+	mu        sync.RWMutex
+	txs       map[string]TxRecord
+	qtumTxIDs map[string]string
+	// senderNonces maps sender addresses and nonces to eth hashes
+	senderNonces map[string]map[uint64]string
+	// nonces maps sender addresses to their next nonce
+	nonces map[string]uint64
+}
+
+// NewMemoryStore returns an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		txs:          make(map[string]TxRecord),
+		qtumTxIDs:    make(map[string]string),
+		senderNonces: make(map[string]map[uint64]string),
+		nonces:       make(map[string]uint64),
+	}
+}
+
+// PutTx implements TxStore
+func (s *MemoryStore) PutTx(record *TxRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ethHash := normalizeHash(record.EthHash)
+	s.txs[ethHash] = *record
+	s.qtumTxIDs[normalizeHash(record.QtumTxID)] = ethHash
+	if record.From == "" {
+		return nil
+	}
+	from := normalizeHash(record.From)
+	if s.senderNonces[from] == nil {
+		s.senderNonces[from] = make(map[uint64]string)
+	}
+	s.senderNonces[from][record.Nonce] = ethHash
+	if record.Nonce >= s.nonces[from] {
+		s.nonces[from] = record.Nonce + 1
+	}
+	return nil
+}
+
+// DeleteTx implements TxStore
+func (s *MemoryStore) DeleteTx(ethHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ethHash = normalizeHash(ethHash)
+	record, ok := s.txs[ethHash]
+	if !ok {
+		return nil
+	}
+	delete(s.txs, ethHash)
+	delete(s.qtumTxIDs, normalizeHash(record.QtumTxID))
+	if record.From == "" {
+		return nil
+	}
+	from := normalizeHash(record.From)
+	delete(s.senderNonces[from], record.Nonce)
+	if record.Nonce+1 == s.nonces[from] {
+		s.nonces[from] = record.Nonce
+	}
+	return nil
+}
+
+// GetTxByEthHash implements TxStore
+func (s *MemoryStore) GetTxByEthHash(ethHash string) (*TxRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.txs[normalizeHash(ethHash)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+// GetTxByQtumTxID implements TxStore
+func (s *MemoryStore) GetTxByQtumTxID(txid string) (*TxRecord, error) {
+	s.mu.RLock()
+	ethHash, ok := s.qtumTxIDs[normalizeHash(txid)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.GetTxByEthHash(ethHash)
+}
+
+// GetTxByNonce implements TxStore
+func (s *MemoryStore) GetTxByNonce(address string, nonce uint64) (*TxRecord, error) {
+	s.mu.RLock()
+	ethHash, ok := s.senderNonces[normalizeHash(address)][nonce]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.GetTxByEthHash(ethHash)
+}
+
+// GetNonce implements TxStore
+func (s *MemoryStore) GetNonce(address string) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nonces[normalizeHash(address)], nil
+}
+
+// Close implements TxStore
+func (s *MemoryStore) Close() error {
+	return nil
+}
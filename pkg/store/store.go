@@ -0,0 +1,70 @@
+package store
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when a record is not found in the store
+var ErrNotFound = errors.New("record not found")
+
+// TxRecord holds the information of an ethereum transaction
+// translated and broadcast as a qtum transaction
+type TxRecord struct {
+	// EthHash is the hash of the ethereum signed transaction
+	EthHash string `json:"ethHash"`
+	// QtumTxID is the id of the qtum transaction broadcast to the network
+	QtumTxID string `json:"qtumTxId"`
+	// Sender is the qtum address (base58) of the sender
+	Sender string `json:"sender"`
+	// Receiver is the qtum address (base58) of the receiver or the hex address of the
+	// called contract. It is empty for contract creations
+	Receiver string `json:"receiver"`
+	// Amount is the amount in Qtum sent to the receiver
+	Amount float64 `json:"amount"`
+	// Fee is the fee in Qtum paid by the qtum transaction, including the contract gas
+	Fee float64 `json:"fee"`
+	// RawTx is the hex encoded signed qtum transaction
+	RawTx string `json:"rawTx"`
+	// EthRawTx is the hex encoded (0x prefixed) signed ethereum transaction
+	EthRawTx string `json:"ethRawTx"`
+	// From is the hex address of the sender of the ethereum transaction
+	From string `json:"from"`
+	// Nonce is the nonce of the ethereum transaction
+	Nonce uint64 `json:"nonce"`
+	// Timestamp is the time the qtum transaction was broadcast
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// TxStore is a persistent store of the transactions broadcast by the proxy
+type TxStore interface {
+	// PutTx stores the transaction record, replacing any record with the same eth hash.
+	// Records with a sender are also indexed by sender and nonce, and advance the
+	// next nonce of the sender past the nonce of the record
+	PutTx(record *TxRecord) error
+
+	// DeleteTx removes the record of the transaction with the given ethereum hash and
+	// its indexes. If it had the highest nonce of its sender, the next nonce of the
+	// sender moves back to the nonce of the record. Deleting a missing record is a no-op
+	DeleteTx(ethHash string) error
+
+	// GetTxByEthHash returns the record of the transaction with the given ethereum hash.
+	// ErrNotFound is returned if there is no such record
+	GetTxByEthHash(ethHash string) (*TxRecord, error)
+
+	// GetTxByQtumTxID returns the record of the transaction with the given qtum txid.
+	// ErrNotFound is returned if there is no such record
+	GetTxByQtumTxID(txid string) (*TxRecord, error)
+
+	// GetTxByNonce returns the record of the transaction sent by the given hex address
+	// with the given nonce. ErrNotFound is returned if there is no such record
+	GetTxByNonce(address string, nonce uint64) (*TxRecord, error)
+
+	// GetNonce returns the next nonce of the given hex address, i.e. one more than
+	// the highest nonce of its recorded transactions, or 0 if it has none
+	GetNonce(address string) (uint64, error)
+
+	// Close releases the resources held by the store
+	Close() error
+}
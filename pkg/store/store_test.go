@@ -0,0 +1,126 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRecord() *TxRecord {
+	return &TxRecord{
+		EthHash:   "0x3e8ec4c4b26c9a5d8fa69d8f1c1e1b8d0e0c3d3b2f5e4c9b1a0f0d3c2b1a0f9e",
+		QtumTxID:  "1dbf40139b6038d5f19b43c592b33a5ad3fe55494e6407712de55cff6b2938da",
+		Sender:    "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
+		Receiver:  "qeVQ5JF6idPcrg1u9M3pCryXeebpj3Tbpk",
+		Amount:    10.5,
+		Fee:       0.000908,
+		RawTx:     "0100000001",
+		From:      "0x96216849c49358B10257cb55b28eA603c874b05E",
+		Nonce:     2,
+		Timestamp: time.Date(2022, 11, 20, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+// testTxStore runs the tests common to all TxStore implementations
+func testTxStore(t *testing.T, s TxStore) {
+	record := newTestRecord()
+
+	_, err := s.GetTxByEthHash(record.EthHash)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.GetTxByQtumTxID(record.QtumTxID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = s.PutTx(record)
+	utils.HandleFatalError(t, err)
+
+	got, err := s.GetTxByEthHash(record.EthHash)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, record, got)
+
+	// hashes are looked up regardless of case and 0x prefix
+	got, err = s.GetTxByEthHash("3E8EC4C4B26C9A5D8FA69D8F1C1E1B8D0E0C3D3B2F5E4C9B1A0F0D3C2B1A0F9E")
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, record, got)
+
+	got, err = s.GetTxByQtumTxID("0x" + record.QtumTxID)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, record, got)
+
+	// records are indexed by sender and nonce, regardless of the address case
+	got, err = s.GetTxByNonce("0x96216849c49358b10257cb55b28ea603c874b05e", record.Nonce)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, record, got)
+	_, err = s.GetTxByNonce(record.From, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	nonce, err := s.GetNonce(record.From)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(3), nonce)
+	nonce, err = s.GetNonce("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb")
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(0), nonce)
+
+	// a lower nonce does not move the next nonce back
+	lower := newTestRecord()
+	lower.EthHash = "0x0b5f3e1c8a2d4e6f7a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f"
+	lower.QtumTxID = "5c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d"
+	lower.Nonce = 0
+	utils.HandleFatalError(t, s.PutTx(lower))
+	nonce, err = s.GetNonce(record.From)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(3), nonce)
+
+	// deleting a lower nonce does not move the next nonce back
+	utils.HandleFatalError(t, s.DeleteTx(lower.EthHash))
+	_, err = s.GetTxByEthHash(lower.EthHash)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.GetTxByQtumTxID(lower.QtumTxID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.GetTxByNonce(lower.From, lower.Nonce)
+	assert.ErrorIs(t, err, ErrNotFound)
+	nonce, err = s.GetNonce(record.From)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(3), nonce)
+
+	// deleting the highest nonce releases it, and deleting a missing record is a no-op
+	deleted := newTestRecord()
+	deleted.EthHash = "0x7d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e"
+	deleted.QtumTxID = "2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f"
+	deleted.Nonce = 3
+	utils.HandleFatalError(t, s.PutTx(deleted))
+	utils.HandleFatalError(t, s.DeleteTx(deleted.EthHash))
+	utils.HandleFatalError(t, s.DeleteTx(deleted.EthHash))
+	nonce, err = s.GetNonce(record.From)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(3), nonce)
+	got, err = s.GetTxByNonce(record.From, record.Nonce)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, record, got)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testTxStore(t, NewMemoryStore())
+}
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "qproxy.db")
+	s, err := NewBoltStore(path)
+	utils.HandleFatalError(t, err)
+	testTxStore(t, s)
+	utils.HandleFatalError(t, s.Close())
+
+	// records persist across restarts
+	s, err = NewBoltStore(path)
+	utils.HandleFatalError(t, err)
+	defer s.Close()
+	record := newTestRecord()
+	got, err := s.GetTxByQtumTxID(record.QtumTxID)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, record, got)
+	nonce, err := s.GetNonce(record.From)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, uint64(3), nonce)
+}
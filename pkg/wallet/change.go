@@ -0,0 +1,46 @@
+package wallet
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/qtumproject/btcd/chaincfg"
+)
+
+// changeAddresses holds the qtum addresses the change of the wallets
+// is paid to, by ethereum address
+var (
+	changeAddresses   = make(map[common.Address]string)
+	changeAddressesMu sync.RWMutex
+)
+
+// SetChangeAddress sets the qtum address, in base58 (or bech32) format, the change of the
+// transactions of the wallet of the address is paid to. An empty address removes it, so
+// the change is paid back to the wallet
+func SetChangeAddress(address common.Address, changeAddress string, cfg *chaincfg.Params) error {
+	changeAddressesMu.Lock()
+	defer changeAddressesMu.Unlock()
+	if changeAddress == "" {
+		delete(changeAddresses, address)
+		return nil
+	}
+	addr, err := btcutil.DecodeAddress(changeAddress, cfg)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid change address: %s", changeAddress)
+	}
+	if !addr.IsForNet(cfg) {
+		return errors.Errorf("Change address %s is not an address of the network", changeAddress)
+	}
+	changeAddresses[address] = addr.EncodeAddress()
+	return nil
+}
+
+// GetChangeAddress returns the qtum address the change of the wallet of the
+// address is paid to, or an empty string if it has none
+func GetChangeAddress(address common.Address) string {
+	changeAddressesMu.RLock()
+	defer changeAddressesMu.RUnlock()
+	return changeAddresses[address]
+}
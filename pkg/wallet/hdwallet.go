@@ -0,0 +1,221 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/pkg/errors"
+	"github.com/qtumproject/btcd/btcutil"
+	"github.com/qtumproject/btcd/btcutil/hdkeychain"
+	"github.com/qtumproject/btcd/chaincfg"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	// EthereumHDPath is the BIP44 derivation path of the ethereum accounts
+	// (e.g. MetaMask), formatted with the account index
+	EthereumHDPath = "m/44'/60'/0'/0/%d"
+	// QtumHDPath is the derivation path of the accounts of the Qtum Core
+	// wallet, formatted with the account index
+	QtumHDPath = "m/88'/0'/%d'"
+	// MaxHDAccounts is the maximum number of accounts derived when importing an HD wallet
+	MaxHDAccounts = 100
+)
+
+// HDWallet derives the accounts of the master key of a BIP39 mnemonic or an extended
+// private key. The account i is the pair of keys derived along the ethereum and
+// qtum paths with index i
+//
+// id is the fingerprint of the master key
+// master is the extended private key of the seed, encrypted with the passphrase
+// of the wallet. It is only decrypted to derive accounts and zeroed afterwards
+// next is the index of the next account to derive
+type HDWallet struct {
+	id     string
+	master keystore.CryptoJSON
+	next   uint32
+}
+
+// HDAccount is the wallet of a key derived from an HD wallet along the path
+type HDAccount struct {
+	Path   string
+	Wallet *QtumWallet
+}
+
+// hdWallets holds the imported HD wallets by id. Only their derived keys are
+// persisted in the keystore, so they are imported again after a restart
+var hdWallets = make(map[string]*HDWallet)
+
+// hdWalletsMu guards the HD wallets, accessed by concurrent RPC calls
+var hdWalletsMu sync.Mutex
+
+// ID returns the id of the HD wallet, the hex encoded fingerprint of its master key
+func (hd *HDWallet) ID() string {
+	return hd.id
+}
+
+// ImportHDWallet imports the HD wallet of the seed, either a BIP39 mnemonic or an
+// extended private key (xprv), and derives its first accounts. The derived keys are
+// added as new wallets, persisted in the keystore encrypted with the passphrase if
+// set. Importing a wallet again derives its accounts from the first one
+func (ws Wallets) ImportHDWallet(seed string, passphrase string, accounts int, cfg *chaincfg.Params) (*HDWallet, []HDAccount, error) {
+	if accounts < 1 || accounts > MaxHDAccounts {
+		return nil, nil, errors.Errorf("Invalid number of accounts to derive: %d (max %d)", accounts, MaxHDAccounts)
+	}
+	master, err := newMasterKey(seed, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer master.Zero()
+	pubKey, err := master.ECPubKey()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Error getting public key of master key")
+	}
+	hd := &HDWallet{id: hex.EncodeToString(btcutil.Hash160(pubKey.SerializeCompressed())[:4])}
+	hd.master, err = encryptMasterKey(master, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	hdWalletsMu.Lock()
+	defer hdWalletsMu.Unlock()
+	var derived []HDAccount
+	for i := 0; i < accounts; i++ {
+		account, err := ws.deriveAccount(hd, master, passphrase, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		derived = append(derived, account...)
+	}
+	hdWallets[hd.id] = hd
+	log.With("module", "wallet").Debugf("Imported HD wallet %s with %d accounts", hd.id, accounts)
+	return hd, derived, nil
+}
+
+// DeriveAccount derives the next account of the imported HD wallet with the id
+// and adds the wallets of its ethereum and qtum keys. The passphrase must be
+// the one the HD wallet was imported with
+func (ws Wallets) DeriveAccount(id string, passphrase string, cfg *chaincfg.Params) ([]HDAccount, error) {
+	hdWalletsMu.Lock()
+	defer hdWalletsMu.Unlock()
+	hd := hdWallets[id]
+	if hd == nil {
+		return nil, errors.New("HD wallet not found: " + id)
+	}
+	master, err := decryptMasterKey(hd.master, passphrase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error decrypting master key of HD wallet %s", id)
+	}
+	defer master.Zero()
+	return ws.deriveAccount(hd, master, passphrase, cfg)
+}
+
+// deriveAccount derives the keys of the next account of the HD wallet from its
+// decrypted master key along the ethereum and qtum paths and adds their wallets.
+// Keys that already have a wallet (e.g. derived before a restart) are not added again
+func (ws Wallets) deriveAccount(hd *HDWallet, master *hdkeychain.ExtendedKey, passphrase string, cfg *chaincfg.Params) ([]HDAccount, error) {
+	var derived []HDAccount
+	for _, pathFormat := range []string{EthereumHDPath, QtumHDPath} {
+		path := fmt.Sprintf(pathFormat, hd.next)
+		privKeyStr, err := derivePrivateKey(master, path)
+		if err != nil {
+			return nil, err
+		}
+		w, err := ws.NewWallet(privKeyStr, passphrase, cfg)
+		if errors.Is(err, ErrWalletExists) {
+			w, err = NewQtumWallet(privKeyStr, cfg)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error adding wallet of HD wallet %s path %s", hd.id, path)
+		}
+		log.With("module", "wallet").Debugf("Derived account %s of HD wallet %s along path %s", w.GetEthereumAddress(), hd.id, path)
+		derived = append(derived, HDAccount{Path: path, Wallet: w})
+	}
+	hd.next++
+	return derived, nil
+}
+
+// newMasterKey returns the master key of the BIP39 mnemonic or the extended private key
+func newMasterKey(seed string, cfg *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+	seed = strings.TrimSpace(seed)
+	if !strings.Contains(seed, " ") {
+		master, err := hdkeychain.NewKeyFromString(seed)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid extended key")
+		}
+		if !master.IsPrivate() {
+			return nil, errors.New("Extended key is not private")
+		}
+		return master, nil
+	}
+	mnemonic := strings.Join(strings.Fields(seed), " ")
+	seedBytes, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid mnemonic")
+	}
+	master, err := hdkeychain.NewMaster(seedBytes, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating master key of mnemonic")
+	}
+	return master, nil
+}
+
+// encryptMasterKey returns the extended private key encrypted with the passphrase,
+// with the scrypt parameters of the keystore, if set
+func encryptMasterKey(master *hdkeychain.ExtendedKey, passphrase string) (keystore.CryptoJSON, error) {
+	scryptN, scryptP := keystore.LightScryptN, keystore.LightScryptP
+	if defaultKeystore != nil {
+		scryptN, scryptP = defaultKeystore.scryptN, defaultKeystore.scryptP
+	}
+	encrypted, err := keystore.EncryptDataV3([]byte(master.String()), []byte(passphrase), scryptN, scryptP)
+	if err != nil {
+		return keystore.CryptoJSON{}, errors.Wrap(err, "Error encrypting master key")
+	}
+	return encrypted, nil
+}
+
+// decryptMasterKey returns the extended private key encrypted with the passphrase
+func decryptMasterKey(encrypted keystore.CryptoJSON, passphrase string) (*hdkeychain.ExtendedKey, error) {
+	data, err := keystore.DecryptDataV3(encrypted, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(data)
+	return hdkeychain.NewKeyFromString(string(data))
+}
+
+// zeroBytes overwrites the bytes with zeros
+func zeroBytes(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
+
+// derivePrivateKey returns the hex encoded private key derived from the extended key
+// along the path. The intermediate keys are zeroed
+func derivePrivateKey(key *hdkeychain.ExtendedKey, path string) (string, error) {
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "Invalid derivation path: %s", path)
+	}
+	for i, index := range derivationPath {
+		child, err := key.Derive(index)
+		if i > 0 {
+			key.Zero()
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "Error deriving key along path %s", path)
+		}
+		key = child
+	}
+	defer key.Zero()
+	privKey, err := key.ECPrivKey()
+	if err != nil {
+		return "", errors.Wrapf(err, "Error getting private key of path %s", path)
+	}
+	return hex.EncodeToString(privKey.Serialize()), nil
+}
@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"testing"
+
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+// test mnemonic of hardhat and anvil, whose first ethereum accounts are well known
+const testMnemonic = "test test test test test test test test test test test junk"
+
+func TestImportHDWallet(t *testing.T) {
+	ws := GetWallets()
+	deleteAccounts := func(accounts []HDAccount) {
+		for _, account := range accounts {
+			ws.DeleteWallet(account.Wallet.GetEthereumAddress().String(), "")
+		}
+	}
+
+	hd, accounts, err := ws.ImportHDWallet(testMnemonic, "", 2, cfg)
+	utils.HandleFatalError(t, err)
+	t.Cleanup(func() { deleteAccounts(accounts) })
+	assert.Len(t, accounts, 4)
+	assert.Equal(t, "m/44'/60'/0'/0/0", accounts[0].Path)
+	assert.Equal(t, "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", accounts[0].Wallet.GetEthereumAddress().String())
+	assert.Equal(t, "m/88'/0'/0'", accounts[1].Path)
+	assert.Equal(t, "m/44'/60'/0'/0/1", accounts[2].Path)
+	assert.Equal(t, "0x70997970C51812dc3A010C7d01b50e0d17dc79C8", accounts[2].Wallet.GetEthereumAddress().String())
+	assert.Equal(t, "m/88'/0'/1'", accounts[3].Path)
+
+	// the derived keys have wallets
+	for _, account := range accounts {
+		w, err := ws.SeekWallet(account.Wallet.GetEthereumAddress().String())
+		utils.HandleFatalError(t, err)
+		qtumAddr, err := w.GetQtumAddress()
+		utils.HandleFatalError(t, err)
+		wantAddr, err := account.Wallet.GetQtumAddress()
+		utils.HandleFatalError(t, err)
+		assert.Equal(t, wantAddr, qtumAddr)
+	}
+
+	// the next account is derived
+	next, err := ws.DeriveAccount(hd.ID(), "", cfg)
+	utils.HandleFatalError(t, err)
+	t.Cleanup(func() { deleteAccounts(next) })
+	assert.Len(t, next, 2)
+	assert.Equal(t, "m/44'/60'/0'/0/2", next[0].Path)
+	assert.Equal(t, "0x3C44CdDdB6a900fa2b585dd299e03d12FA4293BC", next[0].Wallet.GetEthereumAddress().String())
+	assert.Equal(t, "m/88'/0'/2'", next[1].Path)
+
+	// the extended private key of the mnemonic derives the same accounts,
+	// which already have wallets
+	master, err := newMasterKey(testMnemonic, cfg)
+	utils.HandleFatalError(t, err)
+	_, fromKey, err := ws.ImportHDWallet(master.String(), "", 1, cfg)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, accounts[0].Wallet.GetEthereumAddress(), fromKey[0].Wallet.GetEthereumAddress())
+	assert.Equal(t, accounts[1].Wallet.GetEthereumAddress(), fromKey[1].Wallet.GetEthereumAddress())
+
+	// invalid seeds and unknown HD wallets
+	_, _, err = ws.ImportHDWallet("test test test test test test test test test test test test", "", 1, cfg)
+	assert.Error(t, err)
+	_, _, err = ws.ImportHDWallet("xprvinvalid", "", 1, cfg)
+	assert.Error(t, err)
+	_, _, err = ws.ImportHDWallet(testMnemonic, "", 0, cfg)
+	assert.Error(t, err)
+	_, _, err = ws.ImportHDWallet(testMnemonic, "", MaxHDAccounts+1, cfg)
+	assert.Error(t, err)
+	_, err = ws.DeriveAccount("00000000", "", cfg)
+	assert.Error(t, err)
+
+	// the master key is kept encrypted with the passphrase
+	_, err = ws.DeriveAccount(hd.ID(), "wrong", cfg)
+	assert.Error(t, err)
+	assert.NotContains(t, string(hd.master.CipherText), master.String())
+}
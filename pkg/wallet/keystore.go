@@ -0,0 +1,169 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Keystore persists private keys in a directory as scrypt encrypted
+// JSON key files, in the format of the go-ethereum keystore
+//
+// dir is the directory of the key files
+// scryptN and scryptP are the scrypt parameters of the new key files
+type Keystore struct {
+	dir     string
+	scryptN int
+	scryptP int
+}
+
+// NewKeystore creates a keystore in the given directory, creating the directory if needed
+func NewKeystore(dir string, scryptN, scryptP int) (*Keystore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error creating keystore directory: %s", dir)
+	}
+	return &Keystore{dir: dir, scryptN: scryptN, scryptP: scryptP}, nil
+}
+
+// Store encrypts the private key with the passphrase and writes it to a new key file
+func (ks *Keystore) Store(privKey *ecdsa.PrivateKey, passphrase string) (common.Address, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return common.Address{}, errors.Wrap(err, "Error generating key id")
+	}
+	key := &keystore.Key{
+		Id:         id,
+		Address:    crypto.PubkeyToAddress(privKey.PublicKey),
+		PrivateKey: privKey,
+	}
+	keyJSON, err := keystore.EncryptKey(key, passphrase, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return common.Address{}, errors.Wrapf(err, "Error encrypting key of address %s", key.Address)
+	}
+	path := filepath.Join(ks.dir, keyFileName(key.Address))
+	err = os.WriteFile(path, keyJSON, 0600)
+	if err != nil {
+		return common.Address{}, errors.Wrapf(err, "Error writing key file %s", path)
+	}
+	return key.Address, nil
+}
+
+// Load decrypts the key file of the address with the passphrase and returns its private key
+func (ks *Keystore) Load(address common.Address, passphrase string) (*ecdsa.PrivateKey, error) {
+	path, err := ks.find(address)
+	if err != nil {
+		return nil, err
+	}
+	keyJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error reading key file %s", path)
+	}
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error decrypting key of address %s", address)
+	}
+	return key.PrivateKey, nil
+}
+
+// Delete removes the key file of the address, provided the passphrase decrypts it
+func (ks *Keystore) Delete(address common.Address, passphrase string) error {
+	if _, err := ks.Load(address, passphrase); err != nil {
+		return err
+	}
+	path, err := ks.find(address)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil {
+		return errors.Wrapf(err, "Error removing key file %s", path)
+	}
+	return nil
+}
+
+// Has returns true if there is a key file for the address
+func (ks *Keystore) Has(address common.Address) bool {
+	_, err := ks.find(address)
+	return err == nil
+}
+
+// Accounts returns the addresses of the key files, read without decrypting them
+func (ks *Keystore) Accounts() ([]common.Address, error) {
+	keyFiles, err := ks.keyFiles()
+	if err != nil {
+		return nil, err
+	}
+	// key file names start with their creation time
+	paths := make([]string, 0, len(keyFiles))
+	for path := range keyFiles {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	addresses := make([]common.Address, 0, len(paths))
+	for _, path := range paths {
+		addresses = append(addresses, keyFiles[path])
+	}
+	return addresses, nil
+}
+
+// find returns the path of the key file of the address
+func (ks *Keystore) find(address common.Address) (string, error) {
+	keyFiles, err := ks.keyFiles()
+	if err != nil {
+		return "", err
+	}
+	for path, keyAddress := range keyFiles {
+		if keyAddress == address {
+			return path, nil
+		}
+	}
+	return "", errors.New("Key file not found for address: " + address.String())
+}
+
+// keyFiles returns the addresses of the key files in the keystore directory by path.
+// Files that are not key files are skipped
+func (ks *Keystore) keyFiles() (map[string]common.Address, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error reading keystore directory: %s", ks.dir)
+	}
+	keyFiles := make(map[string]common.Address)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(ks.dir, entry.Name())
+		keyJSON, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var keyFile struct {
+			Address string `json:"address"`
+		}
+		if err := json.Unmarshal(keyJSON, &keyFile); err != nil || !common.IsHexAddress(keyFile.Address) {
+			continue
+		}
+		keyFiles[path] = common.HexToAddress(keyFile.Address)
+	}
+	return keyFiles, nil
+}
+
+// keyFileName returns the name of a new key file of the address,
+// following the go-ethereum naming UTC--<created at>--<address>
+func keyFileName(address common.Address) string {
+	now := time.Now().UTC()
+	return fmt.Sprintf("UTC--%s--%s", now.Format("2006-01-02T15-04-05.000000000Z"), hex.EncodeToString(address[:]))
+}
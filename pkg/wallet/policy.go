@@ -0,0 +1,117 @@
+package wallet
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/pkg/errors"
+)
+
+// ErrNotAuthorized is returned when a transaction is not allowed by the policy of its wallet
+var ErrNotAuthorized = errors.New("transaction not authorized")
+
+// Policy restricts the ethereum transactions that are signed with a wallet.
+// Wallets with a policy sign no messages nor typed data, as their receivers
+// and values can not be checked
+//
+// AllowedTo are the receivers (addresses or contracts) the wallet can send to.
+// If empty any receiver is allowed, otherwise contract creations are rejected
+// MaxTxValue is the max value in wei of a transaction, nil if not limited
+// MaxDailyValue is the max value in wei sent in the last 24 hours, nil if not limited
+type Policy struct {
+	AllowedTo     []common.Address
+	MaxTxValue    *big.Int
+	MaxDailyValue *big.Int
+}
+
+// policies holds the policies of the wallets by ethereum address
+var (
+	policies   = make(map[common.Address]*Policy)
+	policiesMu sync.RWMutex
+)
+
+// NewPolicy returns the policy for the hex receiver addresses and the value
+// limits in wei, as decimal or 0x prefixed hex strings. Empty limits are not limited
+func NewPolicy(allowedTo []string, maxTxValue, maxDailyValue string) (*Policy, error) {
+	policy := &Policy{}
+	for _, to := range allowedTo {
+		if !common.IsHexAddress(to) {
+			return nil, errors.New("Invalid allowed receiver address: " + to)
+		}
+		policy.AllowedTo = append(policy.AllowedTo, common.HexToAddress(to))
+	}
+	var err error
+	if policy.MaxTxValue, err = parseValueLimit(maxTxValue); err != nil {
+		return nil, errors.Wrap(err, "Invalid max tx value")
+	}
+	if policy.MaxDailyValue, err = parseValueLimit(maxDailyValue); err != nil {
+		return nil, errors.Wrap(err, "Invalid max daily value")
+	}
+	return policy, nil
+}
+
+// parseValueLimit parses a value in wei, returning nil for an empty value
+func parseValueLimit(value string) (*big.Int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	limit, ok := math.ParseBig256(value)
+	if !ok {
+		return nil, errors.New("Invalid value: " + value)
+	}
+	return limit, nil
+}
+
+// SetPolicy sets the policy of the wallet of the address. A nil policy removes it
+func SetPolicy(address common.Address, policy *Policy) {
+	policiesMu.Lock()
+	defer policiesMu.Unlock()
+	if policy == nil {
+		delete(policies, address)
+		return
+	}
+	policies[address] = policy
+}
+
+// GetPolicy returns the policy of the wallet of the address, or nil if it has none
+func GetPolicy(address common.Address) *Policy {
+	policiesMu.RLock()
+	defer policiesMu.RUnlock()
+	return policies[address]
+}
+
+// Authorize returns ErrNotAuthorized, wrapped with the reason, if the policy does
+// not allow sending the value to the receiver (nil for contract creations), given
+// the value already sent in the last 24 hours
+func (p *Policy) Authorize(to *common.Address, value *big.Int, dailySpent *big.Int) error {
+	if len(p.AllowedTo) > 0 {
+		if to == nil {
+			return errors.Wrap(ErrNotAuthorized, "contract creation not allowed")
+		}
+		if !p.isAllowed(*to) {
+			return errors.Wrapf(ErrNotAuthorized, "receiver %s not allowed", to)
+		}
+	}
+	if p.MaxTxValue != nil && value.Cmp(p.MaxTxValue) > 0 {
+		return errors.Wrapf(ErrNotAuthorized, "value %v exceeds the max tx value %v", value, p.MaxTxValue)
+	}
+	if p.MaxDailyValue != nil {
+		total := new(big.Int).Add(dailySpent, value)
+		if total.Cmp(p.MaxDailyValue) > 0 {
+			return errors.Wrapf(ErrNotAuthorized, "value %v exceeds the max daily value %v (%v already sent)", value, p.MaxDailyValue, dailySpent)
+		}
+	}
+	return nil
+}
+
+// isAllowed returns true if the receiver is in the allowlist
+func (p *Policy) isAllowed(to common.Address) bool {
+	for _, allowed := range p.AllowedTo {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
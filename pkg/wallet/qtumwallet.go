@@ -1,23 +1,29 @@
 package wallet
 
 import (
-	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"encoding/hex"
+	"time"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/pkg/errors"
 	"github.com/qtumproject/btcd/btcutil"
 	"github.com/qtumproject/btcd/chaincfg"
+	"github.com/qtumproject/btcd/txscript"
 	qtool "github.com/qtumproject/qtool/lib/tools"
 )
 
 // QtumWallet represents a wallet for the Qtum blockchain
 //
-// wif is the private key
+// signer signs with the private key, held in memory or by an external signer
 // cfg is the chain configuration
 // ethereumAddr is the ethereum address associated with the wallet
+// expires is when the unlock of the wallet expires, zero if it does not
 type QtumWallet struct {
-	wif          *btcutil.WIF
+	signer       Signer
 	cfg          *chaincfg.Params
 	ethereumAddr *common.Address
+	expires      time.Time
 }
 
 func NewQtumWallet(privKey string, cfg *chaincfg.Params) (*QtumWallet, error) {
@@ -29,21 +35,53 @@ func NewQtumWallet(privKey string, cfg *chaincfg.Params) (*QtumWallet, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to decode WIF")
 	}
-	return &QtumWallet{wif: wif, cfg: cfg}, nil
+	return NewSignerWallet(NewKeySigner(wif.PrivKey), cfg), nil
+}
+
+// NewSignerWallet returns the wallet of the key of the signer, whose
+// ethereum address is the address of the public key of the signer
+func NewSignerWallet(signer Signer, cfg *chaincfg.Params) *QtumWallet {
+	ethereumAddr := crypto.PubkeyToAddress(*signer.PublicKey().ToECDSA())
+	return &QtumWallet{signer: signer, cfg: cfg, ethereumAddr: &ethereumAddr}
 }
 
-// Returns the private key for the given address provided it
-// can be generated from the wallet's private key
-func (w *QtumWallet) GetPrivateKey(address string) (*secp256k1.PrivateKey, error) {
-	// Confirm the address can be generated from the private key
-	generatedAddr, err := btcutil.NewAddressPubKey(w.wif.PrivKey.PubKey().SerializeCompressed(), w.cfg)
+// Returns the signer of the given address provided it is
+// one of the addresses of the wallet (see GetQtumAddresses)
+func (w *QtumWallet) GetSigner(address string) (Signer, error) {
+	if w.isExpired() {
+		return nil, ErrWalletLocked
+	}
+	// Confirm the address can be generated from the public key
+	addresses, err := w.GetQtumAddresses()
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to generate address from private key")
+		return nil, err
+	}
+	for _, walletAddr := range addresses {
+		if walletAddr == address {
+			return w.signer, nil
+		}
 	}
-	if generatedAddr.EncodeAddress() != address {
-		return nil, errors.Wrapf(err, "Address mismatch: %s != %s", generatedAddr.EncodeAddress(), address)
+	return nil, errors.Errorf("Address mismatch: %s is not an address of the wallet %s", address, addresses[0])
+}
+
+// SignHash signs the 32 byte hash with the wallet's private key and returns the
+// ethereum signature [R || S || V], with V being 0 or 1
+func (w *QtumWallet) SignHash(hash []byte) ([]byte, error) {
+	if w.isExpired() {
+		return nil, ErrWalletLocked
 	}
-	return w.wif.PrivKey, nil
+	return w.signer.SignHash(hash)
+}
+
+// GetPublicKeyHex returns the hex encoded uncompressed public key of the wallet,
+// as recovered from the ethereum signatures of its private key
+func (w *QtumWallet) GetPublicKeyHex() string {
+	return hex.EncodeToString(w.signer.PublicKey().SerializeUncompressed())
+}
+
+// isExpired returns true if the unlock of the wallet has expired
+func (w *QtumWallet) isExpired() bool {
+	return !w.expires.IsZero() && time.Now().After(w.expires)
 }
 
 func (w *QtumWallet) SetEthereumAddress(address *common.Address) {
@@ -56,7 +94,7 @@ func (w *QtumWallet) GetEthereumAddress() *common.Address {
 
 /*
 func (w *QtumWallet) GetAddressPubKey() (btcutil.Address, error) {
-	addrPubKey, err := btcutil.NewAddressPubKey(w.wif.PrivKey.PubKey().SerializeCompressed(), w.cfg)
+	addrPubKey, err := btcutil.NewAddressPubKey(w.signer.PublicKey().SerializeCompressed(), w.cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to generate address pubkey from private key")
 	}
@@ -64,11 +102,48 @@ func (w *QtumWallet) GetAddressPubKey() (btcutil.Address, error) {
 }
 */
 
-// GetQtumAddress returns the address associated with the wallet's private key in base58 format
+// GetQtumAddress returns the address associated with the wallet's key in base58 format
 func (w *QtumWallet) GetQtumAddress() (string, error) {
-	addrPubKey, err := btcutil.NewAddressPubKey(w.wif.PrivKey.PubKey().SerializeCompressed(), w.cfg)
+	addrPubKey, err := btcutil.NewAddressPubKey(w.signer.PublicKey().SerializeCompressed(), w.cfg)
 	if err != nil {
-		return "", errors.Wrap(err, "Failed to generate address pubkey from private key")
+		return "", errors.Wrap(err, "Failed to generate address pubkey from public key")
 	}
 	return addrPubKey.EncodeAddress(), nil
 }
+
+// GetQtumAddresses returns the addresses of the outputs the wallet's key
+// can spend, starting with the address returned by GetQtumAddress:
+//   - P2PKH of the compressed public key, also used by its P2PK outputs
+//   - P2PKH of the uncompressed public key, also used by its P2PK outputs
+//   - P2WPKH (bech32) of the compressed public key
+//   - P2SH-P2WPKH of the compressed public key
+func (w *QtumWallet) GetQtumAddresses() ([]string, error) {
+	pubKey := w.signer.PublicKey()
+	compressedHash := btcutil.Hash160(pubKey.SerializeCompressed())
+	compressed, err := btcutil.NewAddressPubKeyHash(compressedHash, w.cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to generate P2PKH address")
+	}
+	uncompressed, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey.SerializeUncompressed()), w.cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to generate uncompressed P2PKH address")
+	}
+	witness, err := btcutil.NewAddressWitnessPubKeyHash(compressedHash, w.cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to generate P2WPKH address")
+	}
+	witnessScript, err := txscript.PayToAddrScript(witness)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to generate P2WPKH script")
+	}
+	nestedWitness, err := btcutil.NewAddressScriptHash(witnessScript, w.cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to generate P2SH-P2WPKH address")
+	}
+	return []string{
+		compressed.EncodeAddress(),
+		uncompressed.EncodeAddress(),
+		witness.EncodeAddress(),
+		nestedWitness.EncodeAddress(),
+	}, nil
+}
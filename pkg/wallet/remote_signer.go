@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+)
+
+// remoteSignerTimeout is the time an external signer has to answer a request
+const remoteSignerTimeout = 10 * time.Second
+
+// RemoteSigner is the client of an external signer, a separate process that holds the
+// private keys and signs the hashes requested through its JSON-RPC "signer" service
+// (see SignerService)
+type RemoteSigner struct {
+	client *rpc.Client
+}
+
+// DialSigner connects to the external signer listening at the endpoint, either
+// an http(s) URL or the path of a unix socket
+func DialSigner(ctx context.Context, endpoint string) (*RemoteSigner, error) {
+	client, err := rpc.DialContext(ctx, endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error connecting to external signer: %s", endpoint)
+	}
+	return &RemoteSigner{client: client}, nil
+}
+
+// Signers returns a Signer for every key of the external signer
+func (rs *RemoteSigner) Signers() ([]Signer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+	defer cancel()
+	var pubKeys []hexutil.Bytes
+	err := rs.client.CallContext(ctx, &pubKeys, "signer_publicKeys")
+	if err != nil {
+		return nil, errors.Wrap(err, "Error getting public keys of external signer")
+	}
+	signers := make([]Signer, 0, len(pubKeys))
+	for _, serialized := range pubKeys {
+		pubKey, err := secp256k1.ParsePubKey(serialized)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid public key of external signer: %s", serialized)
+		}
+		signers = append(signers, &remoteKeySigner{client: rs.client, pubKey: pubKey})
+	}
+	return signers, nil
+}
+
+// Close closes the connection to the external signer
+func (rs *RemoteSigner) Close() {
+	rs.client.Close()
+}
+
+// remoteKeySigner is the Signer of a key of an external signer
+type remoteKeySigner struct {
+	client *rpc.Client
+	pubKey *secp256k1.PublicKey
+}
+
+func (s *remoteKeySigner) PublicKey() *secp256k1.PublicKey {
+	return s.pubKey
+}
+
+// SignHash requests the signature of the hash to the external signer, and checks
+// it is a signature of the key of the signer
+func (s *remoteKeySigner) SignHash(hash []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerTimeout)
+	defer cancel()
+	var signature hexutil.Bytes
+	err := s.client.CallContext(ctx, &signature, "signer_signHash", hexutil.Bytes(s.pubKey.SerializeCompressed()), hexutil.Bytes(hash))
+	if err != nil {
+		return nil, errors.Wrap(err, "Error signing hash with external signer")
+	}
+	pubKey, err := crypto.Ecrecover(hash, signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid signature of external signer")
+	}
+	if !bytes.Equal(pubKey, s.pubKey.SerializeUncompressed()) {
+		return nil, errors.New("Invalid signature of external signer: signed by another key")
+	}
+	return signature, nil
+}
+
+// SignerService is the JSON-RPC service run by an external signer under the "signer"
+// namespace. It signs with its keys the hashes requested by the proxy
+type SignerService struct {
+	signers map[string]Signer
+	pubKeys []hexutil.Bytes
+}
+
+// NewSignerService returns the service signing with the given signers
+func NewSignerService(signers ...Signer) *SignerService {
+	service := &SignerService{signers: make(map[string]Signer, len(signers))}
+	for _, signer := range signers {
+		pubKey := hexutil.Bytes(signer.PublicKey().SerializeCompressed())
+		service.signers[pubKey.String()] = signer
+		service.pubKeys = append(service.pubKeys, pubKey)
+	}
+	return service
+}
+
+// PublicKeys returns the compressed public keys of the keys of the service
+func (s *SignerService) PublicKeys() []hexutil.Bytes {
+	return s.pubKeys
+}
+
+// SignHash signs the 32 byte hash with the key of the compressed public key
+// and returns the ethereum signature [R || S || V]
+func (s *SignerService) SignHash(pubKey hexutil.Bytes, hash hexutil.Bytes) (hexutil.Bytes, error) {
+	signer, ok := s.signers[pubKey.String()]
+	if !ok {
+		return nil, errors.Errorf("Unknown public key: %s", pubKey)
+	}
+	if len(hash) != 32 {
+		return nil, errors.Errorf("Invalid hash length: %d", len(hash))
+	}
+	log.With("module", "signer").Debugf("Signing hash %s with key %s", hash, pubKey)
+	return signer.SignHash(hash)
+}
@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSigner returns a stub external signer serving the signers over http
+func newTestSigner(t *testing.T, signers ...Signer) *RemoteSigner {
+	server := rpc.NewServer()
+	utils.HandleFatalError(t, server.RegisterName("signer", NewSignerService(signers...)))
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Stop()
+	})
+	rs, err := DialSigner(context.Background(), httpServer.URL)
+	utils.HandleFatalError(t, err)
+	t.Cleanup(rs.Close)
+	return rs
+}
+
+// wrongKeySigner signs with another key than the one of its public key
+type wrongKeySigner struct {
+	*KeySigner
+	pubKey *secp256k1.PublicKey
+}
+
+func (s *wrongKeySigner) PublicKey() *secp256k1.PublicKey {
+	return s.pubKey
+}
+
+func TestRemoteSigner(t *testing.T) {
+	const privKeyStr = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+	const otherPrivKeyStr = "85cbc7b1adfe877051d746c3996a01c2bc3e7a6988490439b1f4b4c2b465322d"
+	privKey, _ := crypto.HexToECDSA(privKeyStr)
+	otherPrivKey, _ := crypto.HexToECDSA(otherPrivKeyStr)
+	keySigner := NewKeySigner(secp256k1.PrivKeyFromBytes(crypto.FromECDSA(privKey)))
+	otherKeySigner := NewKeySigner(secp256k1.PrivKeyFromBytes(crypto.FromECDSA(otherPrivKey)))
+
+	rs := newTestSigner(t, keySigner)
+	signers, err := rs.Signers()
+	utils.HandleFatalError(t, err)
+	assert.Len(t, signers, 1)
+	signer := signers[0]
+	assert.True(t, signer.PublicKey().IsEqual(keySigner.PublicKey()))
+
+	// the signatures of the external signer are those of its key
+	hash := crypto.Keccak256([]byte("qproxy"))
+	signature, err := signer.SignHash(hash)
+	utils.HandleFatalError(t, err)
+	want, err := keySigner.SignHash(hash)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, want, signature)
+	der, err := SignDER(signer, hash)
+	utils.HandleFatalError(t, err)
+	sig, err := ecdsa.ParseDERSignature(der)
+	utils.HandleFatalError(t, err)
+	assert.True(t, sig.Verify(hash, keySigner.PublicKey()))
+
+	// hashes must be 32 bytes long
+	_, err = signer.SignHash([]byte("qproxy"))
+	assert.Error(t, err)
+
+	// the wallet of the external key has the addresses of the key
+	w := NewSignerWallet(signer, cfg)
+	assert.Equal(t, crypto.PubkeyToAddress(privKey.PublicKey), *w.GetEthereumAddress())
+	qtumAddr, err := w.GetQtumAddress()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, "qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW", qtumAddr)
+
+	// signatures by another key are rejected
+	rs = newTestSigner(t, &wrongKeySigner{KeySigner: otherKeySigner, pubKey: keySigner.PublicKey()})
+	signers, err = rs.Signers()
+	utils.HandleFatalError(t, err)
+	_, err = signers[0].SignHash(hash)
+	assert.Error(t, err)
+	_, err = SignDER(&wrongKeySigner{KeySigner: otherKeySigner, pubKey: keySigner.PublicKey()}, hash)
+	assert.Error(t, err)
+}
+
+func TestAddSigner(t *testing.T) {
+	const privKeyStr = "85cbc7b1adfe877051d746c3996a01c2bc3e7a6988490439b1f4b4c2b465322d"
+	const addressStr = "0xA6d2799a4b465805421bd10247386a708F01DB03"
+	privKey, _ := crypto.HexToECDSA(privKeyStr)
+	signer := NewKeySigner(secp256k1.PrivKeyFromBytes(crypto.FromECDSA(privKey)))
+	ws := GetWallets()
+
+	w, err := ws.AddSigner(signer, cfg)
+	utils.HandleFatalError(t, err)
+	t.Cleanup(func() { ws.DeleteWallet(addressStr, "") })
+	assert.Equal(t, addressStr, w.GetEthereumAddress().String())
+	got, err := ws.SeekWallet(addressStr)
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, w, got)
+
+	// a key can only be added once
+	_, err = ws.AddSigner(signer, cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package wallet
+
+import (
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// Signer signs hashes with a secp256k1 private key that it does not expose,
+// e.g. a key held in memory or in a separate signing process
+type Signer interface {
+	// PublicKey returns the public key of the signing key
+	PublicKey() *secp256k1.PublicKey
+	// SignHash signs the 32 byte hash and returns the ethereum
+	// signature [R || S || V], with V being 0 or 1
+	SignHash(hash []byte) ([]byte, error)
+}
+
+// KeySigner is the Signer of a private key held in memory
+type KeySigner struct {
+	privKey *secp256k1.PrivateKey
+}
+
+// NewKeySigner returns the signer of the private key
+func NewKeySigner(privKey *secp256k1.PrivateKey) *KeySigner {
+	return &KeySigner{privKey: privKey}
+}
+
+// PublicKey returns the public key of the private key
+func (s *KeySigner) PublicKey() *secp256k1.PublicKey {
+	return s.privKey.PubKey()
+}
+
+// SignHash signs the 32 byte hash with the private key
+func (s *KeySigner) SignHash(hash []byte) ([]byte, error) {
+	privKey, err := crypto.ToECDSA(s.privKey.Serialize())
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert private key to ECDSA")
+	}
+	signature, err := crypto.Sign(hash, privKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to sign hash")
+	}
+	return signature, nil
+}
+
+// SignDER signs the 32 byte hash with the signer and returns the DER encoded
+// signature with low S used by the qtum scripts, without hash type
+func SignDER(signer Signer, hash []byte) ([]byte, error) {
+	signature, err := signer.SignHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(signature) != crypto.SignatureLength {
+		return nil, errors.Errorf("Invalid signature length: %d", len(signature))
+	}
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:64]) {
+		return nil, errors.New("Invalid signature: R or S overflows the curve order")
+	}
+	sig := ecdsa.NewSignature(&r, &s)
+	if !sig.Verify(hash, signer.PublicKey()) {
+		return nil, errors.New("Invalid signature: it does not verify with the public key of the signer")
+	}
+	return sig.Serialize(), nil
+}
@@ -1,63 +1,280 @@
 package wallet
 
 import (
+	"encoding/hex"
+	"sync"
+	"time"
+
 	"github.com/alejoacosta74/qproxy/pkg/log"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/pkg/errors"
 	"github.com/qtumproject/btcd/chaincfg"
 )
 
+// Wallets holds the unlocked wallets by ethereum address. The wallets persisted
+// in the keystore are locked until they are unlocked with their passphrase
 type Wallets map[string]*QtumWallet
 
 var wallets = make(Wallets)
 
+// walletsMu guards the wallets, accessed by concurrent RPC calls
+var walletsMu sync.Mutex
+
+// ErrWalletLocked is returned when the wallet of an address is in the keystore but not unlocked
+var ErrWalletLocked = errors.New("account is locked")
+
+// ErrWalletExists is returned when adding a wallet for an address that already has one
+var ErrWalletExists = errors.New("wallet already exists")
+
+// defaultKeystore persists the wallets. If it is nil the wallets are only kept in memory
+var defaultKeystore *Keystore
+
 func GetWallets() Wallets {
 	return wallets
 }
 
+// SetKeystore sets the keystore where the new wallets are persisted
+func SetKeystore(ks *Keystore) {
+	defaultKeystore = ks
+}
+
+// GetKeystore returns the keystore where the wallets are persisted, or nil if not set
+func GetKeystore() *Keystore {
+	return defaultKeystore
+}
+
+// DeleteWallet deletes the wallet for the given address, and its key file
+// from the keystore provided the passphrase decrypts it
 func (ws Wallets) DeleteWallet(address string, passphrase string) error {
-	if ws[address] == nil {
+	walletsMu.Lock()
+	defer walletsMu.Unlock()
+	inKeystore := defaultKeystore != nil && defaultKeystore.Has(common.HexToAddress(address))
+	if ws[address] == nil && !inKeystore {
 		return errors.New("Wallet not found for address: " + address)
 	}
 
 	log.With("module", "wallet").Debugf("Deleting wallet for address: %s", address)
+	if inKeystore {
+		err := defaultKeystore.Delete(common.HexToAddress(address), passphrase)
+		if err != nil {
+			return errors.Wrapf(err, "Error deleting key file for address: %s", address)
+		}
+	}
 	delete(ws, address)
 	log.With("module", "wallet").Debugf("Succesfully deleted wallet for address %s", address)
 	return nil
 }
 
-// NewWallet creates a new wallet for the given private key
-func (ws Wallets) NewWallet(privKeyStr string, cfg *chaincfg.Params) (*QtumWallet, error) {
+// NewWallet creates a new wallet for the given private key. If a keystore is set
+// the private key is persisted in it, encrypted with the given passphrase, and
+// the wallet is locked until unlocked with UnlockWallet
+func (ws Wallets) NewWallet(privKeyStr string, passphrase string, cfg *chaincfg.Params) (*QtumWallet, error) {
+	walletsMu.Lock()
+	defer walletsMu.Unlock()
 	// verify that the wallet does not exist for the eth address
 	address, err := PrivKeyToEthAddress(privKeyStr)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Error creating wallet for private: %s", privKeyStr)
+		return nil, errors.Wrap(err, "Error getting address of private key")
+	}
+	if wallets[address.String()] != nil || (defaultKeystore != nil && defaultKeystore.Has(*address)) {
+		return nil, errors.Wrapf(ErrWalletExists, "Address: %s", address)
+	}
+
+	w, err := newWallet(privKeyStr, address, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if defaultKeystore == nil {
+		ws[address.String()] = w
+		return w, nil
+	}
+	privKey, err := crypto.HexToECDSA(privKeyStr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error converting private key of address: %s", address)
+	}
+	if _, err := defaultKeystore.Store(privKey, passphrase); err != nil {
+		return nil, errors.Wrapf(err, "Error storing wallet for address: %s", address)
+	}
+	log.With("module", "wallet").Debugf("Stored wallet for eth addr: %s in keystore", address)
+	return w, nil
+}
+
+// AddSigner adds the wallet of the key of the signer (e.g. a key of an external
+// signer). The private key is not kept by the proxy, so the wallet is neither
+// persisted in the keystore nor locked
+func (ws Wallets) AddSigner(signer Signer, cfg *chaincfg.Params) (*QtumWallet, error) {
+	walletsMu.Lock()
+	defer walletsMu.Unlock()
+	w := NewSignerWallet(signer, cfg)
+	address := w.GetEthereumAddress()
+	if ws[address.String()] != nil || (defaultKeystore != nil && defaultKeystore.Has(*address)) {
+		return nil, errors.Wrapf(ErrWalletExists, "Signer address: %s", address)
+	}
+	qtumAddr, err := w.GetQtumAddress()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error getting qtum address of signer: %s", address)
+	}
+	ws[address.String()] = w
+	log.With("module", "wallet").Debugf("Added signer wallet for eth addr: %s and qtum addr: %s", address, qtumAddr)
+	return w, nil
+}
+
+// NewAccount creates a wallet for a new random private key
+func (ws Wallets) NewAccount(passphrase string, cfg *chaincfg.Params) (*QtumWallet, error) {
+	privKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error generating private key")
+	}
+	return ws.NewWallet(hex.EncodeToString(crypto.FromECDSA(privKey)), passphrase, cfg)
+}
+
+// UnlockWallet decrypts the keystore wallet of the address with the passphrase and
+// keeps it unlocked for the given duration, or until locked if duration is zero
+func (ws Wallets) UnlockWallet(address string, passphrase string, duration time.Duration, cfg *chaincfg.Params) error {
+	walletsMu.Lock()
+	defer walletsMu.Unlock()
+	if defaultKeystore == nil {
+		return errors.New("Keystore not set")
+	}
+	ethAddress := common.HexToAddress(address)
+	privKey, err := defaultKeystore.Load(ethAddress, passphrase)
+	if err != nil {
+		return errors.Wrapf(err, "Error unlocking wallet for address: %s", address)
+	}
+	w, err := newWallet(hex.EncodeToString(crypto.FromECDSA(privKey)), &ethAddress, cfg)
+	if err != nil {
+		return err
+	}
+	if duration > 0 {
+		w.expires = time.Now().Add(duration)
+	}
+	ws[ethAddress.String()] = w
+	log.With("module", "wallet").Debugf("Unlocked wallet for address %s for %v", ethAddress, duration)
+	return nil
+}
+
+// OpenWallet returns the wallet of the address decrypting it with the passphrase,
+// without unlocking it. Wallets not in the keystore are returned if unlocked
+func (ws Wallets) OpenWallet(address string, passphrase string, cfg *chaincfg.Params) (*QtumWallet, error) {
+	ethAddress := common.HexToAddress(address)
+	if defaultKeystore == nil || !defaultKeystore.Has(ethAddress) {
+		return ws.SeekWallet(address)
+	}
+	privKey, err := defaultKeystore.Load(ethAddress, passphrase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error opening wallet for address: %s", address)
+	}
+	return newWallet(hex.EncodeToString(crypto.FromECDSA(privKey)), &ethAddress, cfg)
+}
+
+// LockWallet removes the private key of the keystore wallet of the address from
+// memory. Wallets that are not in the keystore can not be locked, as their
+// private key would be lost
+func (ws Wallets) LockWallet(address string) error {
+	walletsMu.Lock()
+	defer walletsMu.Unlock()
+	ethAddress := common.HexToAddress(address)
+	if defaultKeystore == nil || !defaultKeystore.Has(ethAddress) {
+		return errors.New("Wallet not found in keystore for address: " + address)
+	}
+	delete(ws, ethAddress.String())
+	log.With("module", "wallet").Debugf("Locked wallet for address %s", ethAddress)
+	return nil
+}
+
+// LoadKeystore loads the wallets of the keystore that are decrypted with the passphrase
+// and returns the number of wallets loaded. The key files encrypted with other
+// passphrases are skipped
+func (ws Wallets) LoadKeystore(passphrase string, cfg *chaincfg.Params) (int, error) {
+	walletsMu.Lock()
+	defer walletsMu.Unlock()
+	if defaultKeystore == nil {
+		return 0, errors.New("Keystore not set")
+	}
+	addresses, err := defaultKeystore.Accounts()
+	if err != nil {
+		return 0, err
+	}
+	loaded := 0
+	for _, address := range addresses {
+		address := address
+		if ws[address.String()] != nil {
+			continue
+		}
+		privKey, err := defaultKeystore.Load(address, passphrase)
+		if err != nil {
+			log.With("module", "wallet").Debugf("Skipping wallet for address %s: %v", address, err)
+			continue
+		}
+		w, err := newWallet(hex.EncodeToString(crypto.FromECDSA(privKey)), &address, cfg)
+		if err != nil {
+			return loaded, err
+		}
+		ws[address.String()] = w
+		loaded++
+	}
+	return loaded, nil
+}
+
+// ListAccounts returns the ethereum addresses of the wallets, including
+// those in the keystore that are not loaded
+func (ws Wallets) ListAccounts() ([]common.Address, error) {
+	walletsMu.Lock()
+	defer walletsMu.Unlock()
+	var addresses []common.Address
+	if defaultKeystore != nil {
+		var err error
+		addresses, err = defaultKeystore.Accounts()
+		if err != nil {
+			return nil, err
+		}
 	}
-	if wallets[address.String()] != nil {
-		return nil, errors.New("Wallet already exists for private key. Address: " + address.String())
+	listed := make(map[common.Address]bool, len(addresses))
+	for _, address := range addresses {
+		listed[address] = true
 	}
+	for _, w := range ws {
+		if address := w.GetEthereumAddress(); !listed[*address] {
+			addresses = append(addresses, *address)
+		}
+	}
+	return addresses, nil
+}
 
-	// Create Qtum wallet
+// newWallet creates the qtum wallet for the private key of the address
+func newWallet(privKeyStr string, address *common.Address, cfg *chaincfg.Params) (*QtumWallet, error) {
 	w, err := NewQtumWallet(privKeyStr, cfg)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Error creating wallet for private key: %s", privKeyStr)
+		return nil, errors.Wrapf(err, "Error creating wallet for address: %s", address)
 	}
 	w.SetEthereumAddress(address)
 
 	qtumAddr, err := w.GetQtumAddress()
 	if err != nil {
-		return nil, errors.Wrapf(err, "Error getting qtum address for private key: %s", privKeyStr)
+		return nil, errors.Wrapf(err, "Error getting qtum address for address: %s", address)
 	}
 	log.With("module", "wallet").Debugf("Created wallet for eth addr: %s and qtum addr: %s", address, qtumAddr)
-
-	ws[address.String()] = w
 	return w, nil
 }
 
 // SeekWallet returns the Qtum wallet associated to the given ethereum  address. If the wallet
-// is not found, an error is returned.
+// is not found, an error is returned. ErrWalletLocked is returned if the wallet
+// is in the keystore but it is not unlocked, or its unlock expired.
 func (ws Wallets) SeekWallet(address string) (*QtumWallet, error) {
-	if ws[address] == nil {
+	walletsMu.Lock()
+	defer walletsMu.Unlock()
+	w := ws[address]
+	if w != nil && w.isExpired() {
+		log.With("module", "wallet").Debugf("Unlock of wallet for address %s expired", address)
+		delete(ws, address)
+		w = nil
+	}
+	if w == nil {
+		if defaultKeystore != nil && defaultKeystore.Has(common.HexToAddress(address)) {
+			return nil, errors.Wrapf(ErrWalletLocked, "Wallet locked for address: %s", address)
+		}
 		return nil, errors.New("Wallet not found for address: " + address)
 	}
-	return wallets[address], nil
+	return w, nil
 }
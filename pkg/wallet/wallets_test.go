@@ -2,10 +2,14 @@ package wallet
 
 import (
 	"io"
+	"math/big"
 	"os"
 	"testing"
+	"time"
 
 	utils "github.com/alejoacosta74/qproxy/pkg/internal/testutils"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,7 +24,7 @@ func TestNewWallet(t *testing.T) {
 	ws := GetWallets()
 
 	t.Run("Test new wallet ", func(t *testing.T) {
-		w, err := ws.NewWallet(privKeyStr, cfg)
+		w, err := ws.NewWallet(privKeyStr, "", cfg)
 		assert.Nil(err)
 		assert.NotNil(w)
 		ethAddress := w.GetEthereumAddress().String()
@@ -31,10 +35,19 @@ func TestNewWallet(t *testing.T) {
 	})
 	t.Run("Test duplicated wallet", func(t *testing.T) {
 
-		w, err := ws.NewWallet(privKeyStr, cfg)
+		w, err := ws.NewWallet(privKeyStr, "", cfg)
 		assert.NotNil(err)
 		assert.Nil(w)
 	})
+	t.Run("Test invalid private key", func(t *testing.T) {
+		invalidKey := privKeyStr[:63] + "z"
+		w, err := ws.NewWallet(invalidKey, "", cfg)
+		if assert.Error(err) {
+			// the private key is never part of the error
+			assert.NotContains(err.Error(), invalidKey[:63])
+		}
+		assert.Nil(w)
+	})
 	t.Run("Check existing wallets", func(t *testing.T) {
 		assert.Equal(1, len(wallets))
 	})
@@ -46,6 +59,109 @@ func TestNewWallet(t *testing.T) {
 
 }
 
+func TestQtumAddresses(t *testing.T) {
+	const privKeyStr = "00821d8c8a3627adc68aa4034fea953b2f5da553fab312db3fa274240bd49f35"
+	w, err := NewQtumWallet(privKeyStr, cfg)
+	utils.HandleFatalError(t, err)
+
+	// compressed P2PKH, uncompressed P2PKH, P2WPKH and P2SH-P2WPKH
+	want := []string{
+		"qUbxboqjBRp96j3La8D1RYkyqx5uQbJPoW",
+		"qcXuYftjg3Sv9kzHv4M5zY5DL5szbJN488",
+		"tq1q0ynzyvrs237j69djaa088ql9g8pn3llff0lv8k",
+		"mWgNzWERiF44kXr79aUci1cwnYcykR91AT",
+	}
+	addresses, err := w.GetQtumAddresses()
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, want, addresses)
+
+	// the signer is returned for every address of the wallet
+	for _, address := range addresses {
+		signer, err := w.GetSigner(address)
+		assert.NoError(t, err, address)
+		assert.NotNil(t, signer)
+	}
+	_, err = w.GetSigner("qeVQ5JF6idPcrg1u9M3pCryXeebpj3Tbpk")
+	assert.Error(t, err)
+}
+
+func TestKeystore(t *testing.T) {
+	assert := assert.New(t)
+
+	const privKeyStr = "85cbc7b1adfe877051d746c3996a01c2bc3e7a6988490439b1f4b4c2b465322d"
+	const addressStr = "0xA6d2799a4b465805421bd10247386a708F01DB03"
+	const addressB58 = "qTQeBZsvBmmLevSu6cU3wGwyHeZdEp9Tkx"
+	const passphrase = "test123"
+	dir := t.TempDir()
+	ks, err := NewKeystore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	utils.HandleFatalError(t, err)
+	SetKeystore(ks)
+	defer SetKeystore(nil)
+	ws := GetWallets()
+
+	t.Run("New wallet is stored encrypted", func(t *testing.T) {
+		_, err := ws.NewWallet(privKeyStr, passphrase, cfg)
+		assert.Nil(err)
+		empty, err := IsEmpty(dir)
+		assert.Nil(err)
+		assert.False(empty)
+		accounts, err := ks.Accounts()
+		assert.Nil(err)
+		assert.Equal([]common.Address{common.HexToAddress(addressStr)}, accounts)
+	})
+	t.Run("Stored wallet is duplicated", func(t *testing.T) {
+		delete(wallets, addressStr)
+		w, err := ws.NewWallet(privKeyStr, passphrase, cfg)
+		assert.NotNil(err)
+		assert.Nil(w)
+	})
+	t.Run("Stored wallet is listed before loading", func(t *testing.T) {
+		accounts, err := ws.ListAccounts()
+		assert.Nil(err)
+		assert.Equal([]common.Address{common.HexToAddress(addressStr)}, accounts)
+	})
+	t.Run("Load keystore", func(t *testing.T) {
+		loaded, err := ws.LoadKeystore("wrong", cfg)
+		assert.Nil(err)
+		assert.Equal(0, loaded)
+		loaded, err = ws.LoadKeystore(passphrase, cfg)
+		assert.Nil(err)
+		assert.Equal(1, loaded)
+		w, err := ws.SeekWallet(addressStr)
+		assert.Nil(err)
+		assert.Equal(addressStr, w.GetEthereumAddress().String())
+	})
+	t.Run("Lock and unlock wallet", func(t *testing.T) {
+		err := ws.LockWallet(addressStr)
+		assert.Nil(err)
+		_, err = ws.SeekWallet(addressStr)
+		assert.ErrorIs(err, ErrWalletLocked)
+		err = ws.UnlockWallet(addressStr, "wrong", time.Hour, cfg)
+		assert.NotNil(err)
+		err = ws.UnlockWallet(addressStr, passphrase, time.Hour, cfg)
+		assert.Nil(err)
+		w, err := ws.SeekWallet(addressStr)
+		assert.Nil(err)
+		// the wallet is locked again when the unlock expires
+		w.expires = time.Now().Add(-time.Second)
+		_, err = w.GetSigner(addressB58)
+		assert.ErrorIs(err, ErrWalletLocked)
+		_, err = ws.SeekWallet(addressStr)
+		assert.ErrorIs(err, ErrWalletLocked)
+		assert.Equal(0, len(wallets))
+	})
+	t.Run("Delete wallet and keystore", func(t *testing.T) {
+		err := ws.DeleteWallet(addressStr, "wrong")
+		assert.NotNil(err)
+		err = ws.DeleteWallet(addressStr, passphrase)
+		assert.Nil(err)
+		assert.Equal(0, len(wallets))
+		empty, err := IsEmpty(dir)
+		assert.Nil(err)
+		assert.True(empty)
+	})
+}
+
 func IsEmpty(name string) (bool, error) {
 	f, err := os.Open(name)
 	if err != nil {
@@ -59,3 +175,46 @@ func IsEmpty(name string) (bool, error) {
 	}
 	return false, err // Either not empty or error, suits both cases
 }
+
+func TestPolicy(t *testing.T) {
+	allowed := common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb")
+	other := common.HexToAddress("0x7926223070547D2D15b2eF5e7383E541c338FfE9")
+
+	policy, err := NewPolicy([]string{allowed.Hex()}, "1000", "0x7d0")
+	utils.HandleFatalError(t, err)
+	assert.Equal(t, big.NewInt(1000), policy.MaxTxValue)
+	assert.Equal(t, big.NewInt(2000), policy.MaxDailyValue)
+
+	assert.NoError(t, policy.Authorize(&allowed, big.NewInt(1000), big.NewInt(1000)))
+	assert.ErrorIs(t, policy.Authorize(&other, big.NewInt(1), new(big.Int)), ErrNotAuthorized)
+	assert.ErrorIs(t, policy.Authorize(nil, big.NewInt(1), new(big.Int)), ErrNotAuthorized)
+	assert.ErrorIs(t, policy.Authorize(&allowed, big.NewInt(1001), new(big.Int)), ErrNotAuthorized)
+	assert.ErrorIs(t, policy.Authorize(&allowed, big.NewInt(1000), big.NewInt(1001)), ErrNotAuthorized)
+
+	// empty limits are not limited
+	policy, err = NewPolicy(nil, "", "")
+	utils.HandleFatalError(t, err)
+	assert.NoError(t, policy.Authorize(nil, big.NewInt(1000000), big.NewInt(1000000)))
+
+	_, err = NewPolicy([]string{"0x123"}, "", "")
+	assert.Error(t, err)
+	_, err = NewPolicy(nil, "ten", "")
+	assert.Error(t, err)
+}
+
+func TestChangeAddress(t *testing.T) {
+	address := common.HexToAddress("0x71517f86711b4bff4d789ad6fee9a58d8af1c6bb")
+	t.Cleanup(func() { SetChangeAddress(address, "", cfg) })
+	assert.Equal(t, "", GetChangeAddress(address))
+
+	utils.HandleFatalError(t, SetChangeAddress(address, "qcXuYftjg3Sv9kzHv4M5zY5DL5szbJN488", cfg))
+	assert.Equal(t, "qcXuYftjg3Sv9kzHv4M5zY5DL5szbJN488", GetChangeAddress(address))
+
+	// the change address must be a valid address of the network
+	assert.Error(t, SetChangeAddress(address, "qcXuYftjg3Sv9kzHv4M5zY5DL5szbJN489", cfg))
+	assert.Error(t, SetChangeAddress(address, "QLbz7JHiBTspS962RLKV8GndWFwiJNvEPz", cfg))
+	assert.Equal(t, "qcXuYftjg3Sv9kzHv4M5zY5DL5szbJN488", GetChangeAddress(address))
+
+	utils.HandleFatalError(t, SetChangeAddress(address, "", cfg))
+	assert.Equal(t, "", GetChangeAddress(address))
+}